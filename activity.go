@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 学习日历与连续学习 ---
+// 每次作答按 15 分钟时段 (UTC) 累加到用户的学习记录中。时段足够细，统计时再按配置的时区划分学习日，
+// 修改时区后历史记录也会按新时区重新划分（包括 +5:30、+5:45 这样的非整点时区）。
+
+const (
+	activitySlotLength   = 15 * time.Minute
+	activitySlotLayout   = "2006-01-02T15:04Z"
+	activityDateLayout   = "2006-01-02"
+	activityCalendarDays = 365 // 学习日历覆盖的天数（含今天）
+	maxGoalQuestions     = 1000
+	maxGoalMinutes       = 24 * 60
+)
+
+// studyLocation 划分学习日使用的时区，由配置决定 (见 applyConfig)
+var studyLocation = time.FixedZone("CST", 8*60*60)
+
+// recordStudyActivity 把一次作答记入用户的学习记录，timeSpent 为 0 表示没有用时记录。
+// course 为答题模式下题目的来源课程，同时按课程记录作答次数和答对次数（用于排行榜）；错题回顾传空字符串。
+func recordStudyActivity(userID string, at time.Time, timeSpent time.Duration, course string, wasCorrect bool) error {
+	slots := make(map[string]StudyActivitySlot)
+	if err := loadUserJSONData(userID, studyActivityFile, &slots); err != nil {
+		return &userDataError{Message: "加载用户学习记录失败", Err: err}
+	}
+	key := at.UTC().Truncate(activitySlotLength).Format(activitySlotLayout)
+	slot := slots[key]
+	slot.Answers++
+	slot.TimeMs += timeSpent.Milliseconds()
+	if course != "" {
+		if slot.Courses == nil {
+			slot.Courses = make(map[string]StudyCourseCount)
+		}
+		count := slot.Courses[course]
+		count.Answers++
+		if wasCorrect {
+			count.Correct++
+		}
+		slot.Courses[course] = count
+	}
+	slots[key] = slot
+	if err := saveUserJSONData(userID, studyActivityFile, slots); err != nil {
+		return &userDataError{Message: "保存用户学习记录失败", Err: err}
+	}
+	return nil
+}
+
+// loadStudyGoals 加载用户的每日学习目标，未设置时为零值
+func loadStudyGoals(userID string) (StudyGoals, error) {
+	var goals StudyGoals
+	if err := loadUserJSONData(userID, studyGoalsFile, &goals); err != nil {
+		return goals, &userDataError{Message: "加载用户学习目标失败", Err: err}
+	}
+	return goals, nil
+}
+
+// updateStudyGoals 修改用户的每日学习目标，未提供的项保持不变
+func updateStudyGoals(ctx context.Context, req UpdateStudyGoalsRequest) (StudyGoals, error) {
+	if req.QuestionsPerDay != nil && (*req.QuestionsPerDay < 0 || *req.QuestionsPerDay > maxGoalQuestions) {
+		return StudyGoals{}, newAppError(codeOutOfRange, "每日题数目标", 0, maxGoalQuestions)
+	}
+	if req.MinutesPerDay != nil && (*req.MinutesPerDay < 0 || *req.MinutesPerDay > maxGoalMinutes) {
+		return StudyGoals{}, newAppError(codeOutOfRange, "每日分钟数目标", 0, maxGoalMinutes)
+	}
+	goals, err := loadStudyGoals(req.UserID)
+	if err != nil {
+		return goals, err
+	}
+	if req.QuestionsPerDay != nil {
+		goals.QuestionsPerDay = *req.QuestionsPerDay
+	}
+	if req.MinutesPerDay != nil {
+		goals.MinutesPerDay = *req.MinutesPerDay
+	}
+	if err := saveUserJSONData(req.UserID, studyGoalsFile, goals); err != nil {
+		return goals, &userDataError{Message: "保存用户学习目标失败", Err: err}
+	}
+	slog.InfoContext(ctx, "修改每日目标", logKeyUserID, req.UserID, "questions_per_day", goals.QuestionsPerDay, "minutes_per_day", goals.MinutesPerDay)
+	return goals, nil
+}
+
+// goalMet 判断一天是否完成目标：设了目标时需全部达到，否则答过题即可
+func (goals StudyGoals) goalMet(day ActivityDay) bool {
+	if goals.QuestionsPerDay == 0 && goals.MinutesPerDay == 0 {
+		return day.Count > 0
+	}
+	return day.Count >= goals.QuestionsPerDay && day.Minutes >= float64(goals.MinutesPerDay)
+}
+
+// nextDate 返回日期字符串的后一天（按日历日计算，不受夏令时影响）
+func nextDate(date string) string {
+	t, err := time.Parse(activityDateLayout, date)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, 1).Format(activityDateLayout)
+}
+
+// computeStudyActivity 按时区把学习记录划分到天，计算学习日历和连续学习天数
+func computeStudyActivity(userID string, now time.Time) (StudyActivity, error) {
+	slots := make(map[string]StudyActivitySlot)
+	if err := loadUserJSONData(userID, studyActivityFile, &slots); err != nil {
+		return StudyActivity{}, &userDataError{Message: "加载用户学习记录失败", Err: err}
+	}
+	goals, err := loadStudyGoals(userID)
+	if err != nil {
+		return StudyActivity{}, err
+	}
+
+	days := make(map[string]ActivityDay)
+	for key, slot := range slots {
+		start, err := time.Parse(activitySlotLayout, key)
+		if err != nil {
+			slog.Warn("学习记录中有无效的时段，已忽略", logKeyUserID, userID, "slot", key)
+			continue
+		}
+		date := start.In(studyLocation).Format(activityDateLayout)
+		day := days[date]
+		day.Date = date
+		day.Count += slot.Answers
+		day.Minutes += float64(slot.TimeMs) / float64(time.Minute/time.Millisecond)
+		days[date] = day
+	}
+	for date, day := range days {
+		day.GoalMet = goals.goalMet(day)
+		days[date] = day
+	}
+
+	// 最长连续天数：按日期顺序扫描完成目标的天
+	metDates := make([]string, 0, len(days))
+	for date, day := range days {
+		if day.GoalMet {
+			metDates = append(metDates, date)
+		}
+	}
+	sort.Strings(metDates)
+	activity := StudyActivity{UserID: userID, Timezone: studyLocation.String(), Goals: goals, Calendar: []ActivityDay{}}
+	run := 0
+	for i, date := range metDates {
+		if i > 0 && nextDate(metDates[i-1]) == date {
+			run++
+		} else {
+			run = 1
+		}
+		activity.LongestStreak = max(activity.LongestStreak, run)
+	}
+
+	// 当前连续天数：今天完成了就从今天往前数，否则从昨天往前数（今天还有机会）
+	localNow := now.In(studyLocation)
+	today := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, time.UTC)
+	dayAt := func(offset int) ActivityDay {
+		date := today.AddDate(0, 0, offset).Format(activityDateLayout)
+		day := days[date]
+		day.Date = date
+		return day
+	}
+	activity.Today = dayAt(0)
+	offset := 0
+	if !activity.Today.GoalMet {
+		offset = -1
+	}
+	for dayAt(offset).GoalMet {
+		activity.CurrentStreak++
+		offset--
+	}
+
+	for offset := 1 - activityCalendarDays; offset <= 0; offset++ {
+		day := dayAt(offset)
+		if day.Count > 0 {
+			activity.ActiveDays++
+		}
+		activity.Calendar = append(activity.Calendar, day)
+	}
+	return activity, nil
+}
+
+// StudyActivityHandler 返回用户过去一年的学习日历、今日进度和连续学习天数
+func StudyActivityHandler(ctx context.Context, c *app.RequestContext) {
+	var req StudyActivityRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	activity, err := computeStudyActivity(req.UserID, time.Now())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, activity)
+}
+
+// StudyGoalsHandler 返回用户的每日学习目标
+func StudyGoalsHandler(ctx context.Context, c *app.RequestContext) {
+	var req StudyGoalsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	goals, err := loadStudyGoals(req.UserID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, goals)
+}
+
+// UpdateStudyGoalsHandler 修改用户的每日学习目标
+func UpdateStudyGoalsHandler(ctx context.Context, c *app.RequestContext) {
+	var req UpdateStudyGoalsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	goals, err := updateStudyGoals(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, goals)
+}
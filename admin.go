@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"os"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// requireAdmin 检查请求是否具有管理权限，没有权限时直接写入错误响应并返回 false。
+// 设置了 QUIZ_ADMIN_TOKEN 环境变量时，请求头 X-Admin-Token 必须与之相同；
+// 未设置时只允许来自本机的请求，方便单机使用。
+func requireAdmin(c *app.RequestContext) bool {
+	if message := checkAdminAccess(c); message != "" {
+		writeError(c, newAppError(codeForbidden, message))
+		return false
+	}
+	return true
+}
+
+// requireTeacher 检查请求是否具有老师权限（布置作业、查看作业完成情况），没有权限时直接写入错误响应并返回 false。
+// 请求头 X-Teacher-Token 与 QUIZ_TEACHER_TOKEN 相同，或者具有管理权限即可。
+func requireTeacher(c *app.RequestContext) bool {
+	if token := os.Getenv(teacherTokenEnv); token != "" {
+		provided := string(c.GetHeader("X-Teacher-Token"))
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return true
+		}
+	}
+	if message := checkAdminAccess(c); message != "" {
+		if os.Getenv(teacherTokenEnv) != "" {
+			message = "老师令牌无效"
+		}
+		writeError(c, newAppError(codeForbidden, message))
+		return false
+	}
+	return true
+}
+
+// checkAdminAccess 检查管理权限，有权限时返回空字符串，否则返回错误提示
+func checkAdminAccess(c *app.RequestContext) string {
+	if token := os.Getenv(adminTokenEnv); token != "" {
+		provided := string(c.GetHeader("X-Admin-Token"))
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return ""
+		}
+		return "管理令牌无效"
+	}
+
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err == nil {
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return ""
+		}
+	}
+	return "管理接口仅允许本机访问 (可设置 " + adminTokenEnv + " 以远程管理)"
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// apiOperation 一个 /api/v1 接口。同一份定义既用于注册路由，也用于生成 OpenAPI 文档，
+// 请求和响应的结构由处理函数的类型得出，因此文档与实际接口保持一致。
+type apiOperation struct {
+	Method      string
+	Path        string // Hertz 路由形式，路径参数写作 :name
+	OperationID string
+	Summary     string
+	Request     reflect.Type // GET、DELETE 的查询参数或其余方法的请求体，路径参数对应的字段除外
+	Response    reflect.Type // 为空时成功返回 204
+	Stream      bool         // 成功时返回 text/event-stream
+	Handler     app.HandlerFunc
+}
+
+// v1JSON 构造返回 JSON 的接口：绑定并检查请求，调用 fn，出错时输出统一的错误信封
+func v1JSON[Req, Resp any](method, path, operationID, summary string, fn func(context.Context, Req) (Resp, error)) apiOperation {
+	return apiOperation{
+		Method:      method,
+		Path:        path,
+		OperationID: operationID,
+		Summary:     summary,
+		Request:     reflect.TypeOf((*Req)(nil)).Elem(),
+		Response:    reflect.TypeOf((*Resp)(nil)).Elem(),
+		Handler: func(ctx context.Context, c *app.RequestContext) {
+			var req Req
+			if !bindV1Request(c, &req) {
+				return
+			}
+			resp, err := fn(ctx, req)
+			if err != nil {
+				writeAPIError(c, err)
+				return
+			}
+			c.JSON(consts.StatusOK, resp)
+		},
+	}
+}
+
+// v1NoContent 构造成功时返回 204 的接口
+func v1NoContent[Req any](method, path, operationID, summary string, fn func(context.Context, Req) error) apiOperation {
+	return apiOperation{
+		Method:      method,
+		Path:        path,
+		OperationID: operationID,
+		Summary:     summary,
+		Request:     reflect.TypeOf((*Req)(nil)).Elem(),
+		Handler: func(ctx context.Context, c *app.RequestContext) {
+			var req Req
+			if !bindV1Request(c, &req) {
+				return
+			}
+			if err := fn(ctx, req); err != nil {
+				writeAPIError(c, err)
+				return
+			}
+			c.Status(consts.StatusNoContent)
+		},
+	}
+}
+
+// apiV1Operations 列出 /api/v1 的全部接口，已关闭功能的接口不包含在内。
+// 题库维护、老师和导出接口仍只在 /api 下提供。
+func apiV1Operations(features FeatureToggles) []apiOperation {
+	ops := []apiOperation{
+		v1JSON("GET", "/courses", "listCourses", "获取课程、章节及题目数量",
+			func(context.Context, struct{}) (CoursesResponse, error) { return listCourses(), nil }),
+		v1JSON("POST", "/sessions", "initSession", "初始化用户会话", func(ctx context.Context, req InitSessionRequest) (SessionResponse, error) {
+			return initUserSession(ctx, req.UserID)
+		}),
+		v1JSON("POST", "/review/start", "startReview", "开始速刷，返回全部题目及拆解", startQuickReview),
+		v1JSON("POST", "/quiz/start", "startQuiz", "开始答题，返回全部题目", startQuiz),
+		v1JSON("POST", "/quiz/serve", "serveQuestion", "记录一道题的出题时间", func(_ context.Context, req ServeQuestionRequest) (MessageResponse, error) {
+			return MessageResponse{Message: "已记录出题时间"}, markQuestionServed(req)
+		}),
+		v1JSON("POST", "/quiz/answers", "submitAnswer", "提交答题模式的答案", submitQuizAnswer),
+		v1JSON("POST", "/incorrect_questions/review/start", "startIncorrectReview", "开始错题回顾", startIncorrectReview),
+		v1JSON("POST", "/incorrect_questions/review/answers", "submitIncorrectReviewAnswer", "提交错题回顾的答案",
+			func(ctx context.Context, req SubmitAnswerRequest) (AnswerFeedback, error) {
+				return submitIncorrectReviewAnswer(ctx, req), nil
+			}),
+		v1JSON("GET", "/questions/difficulty", "listQuestionDifficulty", "按全局难度或区分度列出题目",
+			func(_ context.Context, req QuestionDifficultyRequest) (QuestionDifficultyResponse, error) {
+				return questionDifficultyList(req)
+			}),
+		v1JSON("GET", "/users/:user_id/stats", "getUserStats", "按课程和章节汇总答题统计", func(_ context.Context, req UserStatsRequest) (UserStatsSummary, error) {
+			return computeUserStats(req.UserID)
+		}),
+		v1JSON("GET", "/users/:user_id/speed_stats", "getSpeedStats", "按题型和章节统计作答用时", func(_ context.Context, req SpeedStatsRequest) (SpeedStats, error) {
+			return computeSpeedStats(req.UserID, req.Course)
+		}),
+		v1JSON("GET", "/users/:user_id/activity", "getStudyActivity", "过去一年的学习日历和连续学习天数", func(_ context.Context, req StudyActivityRequest) (StudyActivity, error) {
+			return computeStudyActivity(req.UserID, time.Now())
+		}),
+		v1JSON("GET", "/users/:user_id/goals", "getStudyGoals", "获取每日学习目标", func(_ context.Context, req StudyGoalsRequest) (StudyGoals, error) {
+			return loadStudyGoals(req.UserID)
+		}),
+		v1JSON("PUT", "/users/:user_id/goals", "updateStudyGoals", "修改每日学习目标", updateStudyGoals),
+		v1NoContent("DELETE", "/users/:user_id/incorrect_questions/:original_chapter/:original_question_number", "deleteIncorrectQuestion", "从错题本中删除一题",
+			func(ctx context.Context, req DeleteIncorrectQuestionRequest) error {
+				course := sessionCourseOrDefault(ctx, req.UserID, req.Course)
+				found, err := deleteIncorrectQuestion(ctx, req.UserID, course, req.OriginalChapter, req.OriginalQuestionNumber)
+				if err == nil && !found {
+					return errUnknownQuestion.with(getQuestionStatKey(course, req.OriginalChapter, req.OriginalQuestionNumber))
+				}
+				return err
+			}),
+		v1JSON("DELETE", "/users/:user_id/data", "clearUserData", "清理用户的错题本和统计", func(ctx context.Context, req ClearUserDataRequest) (MessageResponse, error) {
+			return MessageResponse{Message: "用户数据（错题本和统计）已成功清理。"}, clearUserData(ctx, req.UserID)
+		}),
+	}
+	if features.Tags {
+		ops = append(ops,
+			v1JSON("GET", "/tags", "listTags", "获取知识点标签及题目数", func(_ context.Context, req TagsListRequest) (TagListResponse, error) {
+				return listTags(req.Course)
+			}),
+			v1JSON("GET", "/users/:user_id/tag_stats", "getTagStats", "按知识点标签统计正确率", func(_ context.Context, req TagStatsRequest) (TagStatsResponse, error) {
+				list, err := computeTagAccuracy(req.UserID, req.Course)
+				return TagStatsResponse{UserID: req.UserID, Tags: list}, err
+			}),
+		)
+	}
+	if features.Notes {
+		ops = append(ops,
+			v1JSON("GET", "/users/:user_id/notes", "listNotes", "获取用户的全部笔记与收藏", func(ctx context.Context, req UserNotesListRequest) (NotesResponse, error) {
+				return listUserNotes(ctx, req.UserID)
+			}),
+			v1JSON("PUT", "/users/:user_id/notes/:question_id", "upsertNote", "新增或修改一道题的笔记与收藏", func(ctx context.Context, req UpsertNoteRequest) (NoteResponse, error) {
+				note, err := upsertUserNote(ctx, req)
+				return NoteResponse{Message: "笔记已保存", Note: note}, err
+			}),
+			v1NoContent("DELETE", "/users/:user_id/notes/:question_id", "deleteNote", "删除一道题的笔记与收藏", deleteUserNote),
+		)
+	}
+	if features.Leaderboard {
+		ops = append(ops,
+			v1JSON("GET", "/leaderboard", "getLeaderboard", "获取课程本周或全部时间的排行榜", func(_ context.Context, req LeaderboardRequest) (LeaderboardResult, error) {
+				return getLeaderboard(req.Course, req.Period)
+			}),
+			v1JSON("GET", "/users/:user_id/leaderboard_settings", "getLeaderboardSettings", "获取排行榜设置", func(_ context.Context, req UserStatsRequest) (LeaderboardSettings, error) {
+				return loadLeaderboardSettings(req.UserID)
+			}),
+			v1JSON("PUT", "/users/:user_id/leaderboard_settings", "updateLeaderboardSettings", "修改是否参加排行榜、是否匿名", updateLeaderboardSettings),
+		)
+	}
+	if features.Assignments {
+		ops = append(ops,
+			v1JSON("GET", "/users/:user_id/assignments", "listStudentAssignments", "获取全部作业及自己的进度", func(_ context.Context, req StudentAssignmentsRequest) (AssignmentListResponse, error) {
+				list, err := studentAssignments(req.UserID, false)
+				return AssignmentListResponse{Assignments: list}, err
+			}),
+			v1JSON("POST", "/assignments/:assignment_id/start", "startAssignment", "以答题模式开始做作业", startAssignmentQuiz),
+		)
+	}
+	if features.Battles {
+		ops = append(ops,
+			v1JSON("POST", "/battles", "createBattle", "创建对战房间", createBattleRoom),
+			v1JSON("POST", "/battles/:code/join", "joinBattle", "凭房间码加入对战", joinBattle),
+			v1JSON("POST", "/battles/:code/start", "startBattle", "房主开始对战", func(ctx context.Context, req BattleRoomRequest) (MessageResponse, error) {
+				return MessageResponse{Message: "对战开始"}, startBattle(ctx, req)
+			}),
+			v1JSON("POST", "/battles/:code/answers", "submitBattleAnswer", "提交当前题目的答案", submitBattleAnswer),
+			apiOperation{
+				Method:      "GET",
+				Path:        "/battles/:code/events",
+				OperationID: "battleEvents",
+				Summary:     "订阅房间事件 (SSE: room, question, answer_status, question_result, finished)",
+				Request:     reflect.TypeOf(BattleEventsRequest{}),
+				Stream:      true,
+				Handler: func(ctx context.Context, c *app.RequestContext) {
+					var req BattleEventsRequest
+					if !bindV1Request(c, &req) {
+						return
+					}
+					room, events, err := subscribeBattle(req)
+					if err != nil {
+						writeAPIError(c, err)
+						return
+					}
+					streamBattleEvents(ctx, c, room, events)
+				},
+			},
+			v1JSON("GET", "/users/:user_id/battles", "listBattleHistory", "获取用户的对战记录", func(_ context.Context, req BattleHistoryRequest) (BattleHistoryResponse, error) {
+				results, err := loadBattleResults(req.UserID)
+				return BattleHistoryResponse{Total: len(results), Battles: results}, err
+			}),
+		)
+	}
+	return ops
+}
+
+// registerAPIV1Routes 注册 /api/v1 的全部接口以及由这些接口生成的 OpenAPI 文档
+func registerAPIV1Routes(v1Group *route.RouterGroup, features FeatureToggles) {
+	ops := apiV1Operations(features)
+	for _, op := range ops {
+		v1Group.Handle(op.Method, op.Path, op.Handler)
+	}
+	spec, err := json.MarshalIndent(buildOpenAPIDocument(ops), "", "  ")
+	if err != nil {
+		slog.Error("生成 OpenAPI 文档失败", "error", err)
+		os.Exit(1)
+	}
+	// GET /api/v1/openapi.json - 获取 /api/v1 的 OpenAPI 3 文档
+	v1Group.GET("/openapi.json", func(ctx context.Context, c *app.RequestContext) {
+		c.Data(consts.StatusOK, "application/json; charset=utf-8", spec)
+	})
+}
+
+// apiV1NoRoute 处理没有匹配的路由：/api/v1 下返回错误信封，其余保持默认的 404
+func apiV1NoRoute(ctx context.Context, c *app.RequestContext) {
+	path := string(c.Path())
+	if !strings.HasPrefix(path, "/api/v1/") {
+		c.String(consts.StatusNotFound, "404 page not found")
+		return
+	}
+	writeAPIError(c, newAppError(codeRouteNotFound, string(c.Method())+" "+path))
+}
+
+// bindV1Request 绑定请求体或查询参数，用路径参数填充同名字段，并检查必填字段。
+// 失败时直接写入错误信封并返回 false。
+func bindV1Request(c *app.RequestContext, req interface{}) bool {
+	if err := c.BindAndValidate(req); err != nil {
+		writeAPIError(c, invalidRequest(err))
+		return false
+	}
+	v := reflect.ValueOf(req).Elem()
+	for _, param := range c.Params {
+		if field, ok := fieldByParamName(v, param.Key); ok && field.Kind() == reflect.String {
+			field.SetString(param.Value)
+		}
+	}
+	if err := checkRequiredFields(v); err != nil {
+		writeAPIError(c, err)
+		return false
+	}
+	return true
+}
+
+// paramName 返回字段在 json 或 query 标签中的名字
+func paramName(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	return name
+}
+
+// fieldByParamName 按 json 或 query 标签查找路径参数对应的字段
+func fieldByParamName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if paramName(t.Field(i), "json") == name || paramName(t.Field(i), "query") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// isRequiredField 字段是否带有 vd:"required" 标记
+func isRequiredField(field reflect.StructField) bool {
+	return strings.Contains(field.Tag.Get("vd"), "required")
+}
+
+// checkRequiredFields 检查带有 vd:"required" 标记的字段不为空
+func checkRequiredFields(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isRequiredField(field) || !v.Field(i).IsZero() {
+			continue
+		}
+		name := paramName(field, "json")
+		if name == "" {
+			name = paramName(field, "query")
+		}
+		return &appError{Code: codeMissingField, Args: []interface{}{name}, Details: map[string]interface{}{"field": name}}
+	}
+	return nil
+}
+
+// writeAPIError 以 {"error": {"code", "message", "details"}} 的形式输出 /api/v1 的错误
+func writeAPIError(c *app.RequestContext, err error) {
+	status, apiErr := resolveError(c, err)
+	c.JSON(status, APIErrorResponse{Error: apiErr})
+}
+
+// --- OpenAPI 文档 ---
+
+// openAPISchemas 收集文档中引用的结构体定义
+type openAPISchemas map[string]interface{}
+
+// buildOpenAPIDocument 由接口定义生成 OpenAPI 3 文档，路径相对于 /api/v1
+func buildOpenAPIDocument(ops []apiOperation) utils.H {
+	schemas := openAPISchemas{}
+	errorResponse := utils.H{
+		"description": "错误信封，code 为稳定的错误码",
+		"content":     utils.H{"application/json": utils.H{"schema": schemas.schemaOf(reflect.TypeOf(APIErrorResponse{}))}},
+	}
+	schemas["APIError"].(utils.H)["properties"].(utils.H)["code"] = utils.H{"type": "string", "enum": errorCodes()}
+	paths := utils.H{}
+	for _, op := range ops {
+		openAPIPath, pathParams := openAPIPathOf(op.Path)
+		operation := utils.H{
+			"operationId": op.OperationID,
+			"summary":     op.Summary,
+			"tags":        []string{strings.Split(strings.TrimPrefix(op.Path, "/"), "/")[0]},
+		}
+
+		params := []utils.H{}
+		skip := make(map[string]bool)
+		for _, name := range pathParams {
+			params = append(params, utils.H{"name": name, "in": "path", "required": true, "schema": utils.H{"type": "string"}})
+			skip[name] = true
+		}
+		if op.Method == "GET" || op.Method == "DELETE" {
+			params = append(params, schemas.queryParams(op.Request, skip)...)
+		} else if body := schemas.objectSchema(op.Request, skip); len(body["properties"].(utils.H)) > 0 {
+			operation["requestBody"] = utils.H{"required": true, "content": utils.H{"application/json": utils.H{"schema": body}}}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		responses := utils.H{"default": errorResponse}
+		switch {
+		case op.Stream:
+			responses["200"] = utils.H{"description": "事件流", "content": utils.H{"text/event-stream": utils.H{"schema": utils.H{"type": "string"}}}}
+		case op.Response == nil:
+			responses["204"] = utils.H{"description": "成功，没有响应体"}
+		default:
+			responses["200"] = utils.H{"description": "成功", "content": utils.H{"application/json": utils.H{"schema": schemas.schemaOf(op.Response)}}}
+		}
+		operation["responses"] = responses
+
+		item, ok := paths[openAPIPath].(utils.H)
+		if !ok {
+			item = utils.H{}
+			paths[openAPIPath] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+	return utils.H{
+		"openapi": "3.0.3",
+		"info": utils.H{
+			"title":       "喵喵学习小助手 API",
+			"version":     "1.0.0",
+			"description": "出错时返回 {\"error\": {\"code\", \"message\", \"details\"}}，code 为稳定的错误码 (见 APIError.code)，message 按 Accept-Language 使用 zh-CN 或 en。",
+		},
+		"servers":    []utils.H{{"url": "/api/v1"}},
+		"paths":      paths,
+		"components": utils.H{"schemas": schemas},
+	}
+}
+
+// openAPIPathOf 把 Hertz 路由 /users/:user_id 转为 /users/{user_id}，并返回路径参数名
+func openAPIPathOf(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// queryParams 由带 query 标签的字段生成查询参数，skip 中的字段由路径提供
+func (s openAPISchemas) queryParams(t reflect.Type, skip map[string]bool) []utils.H {
+	var params []utils.H
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := paramName(field, "query")
+		if name == "" || skip[name] {
+			continue
+		}
+		params = append(params, utils.H{"name": name, "in": "query", "required": isRequiredField(field), "schema": s.schemaOf(field.Type)})
+	}
+	return params
+}
+
+// objectSchema 由结构体的 json 标签生成对象定义，skip 中的字段不列出。
+// 没有 json 名字的嵌入结构体与 encoding/json 一样把字段提升到外层，外层的同名字段优先。
+func (s openAPISchemas) objectSchema(t reflect.Type, skip map[string]bool) utils.H {
+	properties := utils.H{}
+	var required []string
+	promoted := utils.H{}
+	var promotedRequired []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := paramName(field, "json")
+		if embedded := field.Type; field.Anonymous && name == "" {
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := s.objectSchema(embedded, skip)
+				for innerName, schema := range inner["properties"].(utils.H) {
+					promoted[innerName] = schema
+				}
+				if innerRequired, ok := inner["required"].([]string); ok {
+					promotedRequired = append(promotedRequired, innerRequired...)
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "-" || skip[name] {
+			continue
+		}
+		if name == "" {
+			if field.Tag.Get("query") != "" {
+				continue // 只用于查询参数
+			}
+			name = field.Name
+		}
+		properties[name] = s.schemaOf(field.Type)
+		if isRequiredField(field) {
+			required = append(required, name)
+		}
+	}
+	shadowed := make(map[string]bool)
+	for name, schema := range promoted {
+		if _, ok := properties[name]; ok {
+			shadowed[name] = true
+			continue
+		}
+		properties[name] = schema
+	}
+	for _, name := range promotedRequired {
+		if !shadowed[name] && !slices.Contains(required, name) {
+			required = append(required, name)
+		}
+	}
+	schema := utils.H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaOf 返回类型的定义，具名结构体放入 components 并以 $ref 引用
+func (s openAPISchemas) schemaOf(t reflect.Type) utils.H {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schemaOf(t.Elem())
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return utils.H{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return s.objectSchema(t, nil)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = utils.H{} // 先占位，避免自引用时无限递归
+			s[t.Name()] = s.objectSchema(t, nil)
+		}
+		return utils.H{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return utils.H{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return utils.H{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.String:
+		return utils.H{"type": "string"}
+	case reflect.Bool:
+		return utils.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return utils.H{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return utils.H{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return utils.H{"type": "number"}
+	case reflect.Interface:
+		return utils.H{}
+	default:
+		panic(fmt.Sprintf("OpenAPI 文档不支持的类型 %s", t))
+	}
+}
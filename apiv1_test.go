@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// sampleValue 生成每个字段都非零的示例值（切片和映射各一个元素），用于检查文档是否列出了全部输出字段。
+// 超过 depth 层的字段保持零值，避免自引用的结构体无限展开。
+func sampleValue(t reflect.Type, depth int) reflect.Value {
+	v := reflect.New(t).Elem()
+	if depth <= 0 {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.Set(sampleValue(t.Elem(), depth-1).Addr())
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				field.Set(sampleValue(t.Field(i).Type, depth-1))
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), sampleValue(t.Elem(), depth-1)))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		v.SetMapIndex(sampleValue(t.Key(), depth-1), sampleValue(t.Elem(), depth-1))
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(0.5)
+	}
+	return v
+}
+
+// checkAgainstSchema 检查 JSON 值与文档中的定义一致：对象的键与 properties 相同，类型相符
+func checkAgainstSchema(t *testing.T, path string, value interface{}, schema utils.H, schemas openAPISchemas) {
+	t.Helper()
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		resolved, ok := schemas[name].(utils.H)
+		if !ok {
+			t.Errorf("%s: 引用了不存在的定义 %s", path, name)
+			return
+		}
+		schema = resolved
+	}
+	if value == nil {
+		return // 空值（如 interface{} 字段）不检查
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: 文档为对象，实际为 %T", path, value)
+			return
+		}
+		if additional, ok := schema["additionalProperties"].(utils.H); ok {
+			for key, item := range object {
+				checkAgainstSchema(t, path+"."+key, item, additional, schemas)
+			}
+			return
+		}
+		properties, _ := schema["properties"].(utils.H)
+		var documented, actual []string
+		for name := range properties {
+			documented = append(documented, name)
+		}
+		for key := range object {
+			actual = append(actual, key)
+		}
+		sort.Strings(documented)
+		sort.Strings(actual)
+		if !reflect.DeepEqual(documented, actual) {
+			t.Errorf("%s: 文档中的字段 %v 与实际输出的字段 %v 不一致", path, documented, actual)
+		}
+		for key, item := range object {
+			if property, ok := properties[key].(utils.H); ok {
+				checkAgainstSchema(t, path+"."+key, item, property, schemas)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: 文档为数组，实际为 %T", path, value)
+			return
+		}
+		for _, item := range items {
+			checkAgainstSchema(t, path+"[]", item, schema["items"].(utils.H), schemas)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: 文档为字符串，实际为 %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: 文档为布尔值，实际为 %T", path, value)
+		}
+	case "integer", "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: 文档为数字，实际为 %T", path, value)
+		}
+	}
+}
+
+func TestOpenAPIMatchesResponses(t *testing.T) {
+	features := defaultConfig().Features
+	doc := buildOpenAPIDocument(apiV1Operations(features))
+	schemas := doc["components"].(utils.H)["schemas"].(openAPISchemas)
+	checked := 0
+	for _, op := range apiV1Operations(features) {
+		if op.Response == nil || op.Stream {
+			continue
+		}
+		raw, err := json.Marshal(sampleValue(op.Response, 8).Interface())
+		if err != nil {
+			t.Fatalf("%s: 序列化示例响应失败: %v", op.OperationID, err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			t.Fatalf("%s: 解析示例响应失败: %v", op.OperationID, err)
+		}
+		path, _ := openAPIPathOf(op.Path)
+		operation := doc["paths"].(utils.H)[path].(utils.H)[strings.ToLower(op.Method)].(utils.H)
+		schema := operation["responses"].(utils.H)["200"].(utils.H)["content"].(utils.H)["application/json"].(utils.H)["schema"].(utils.H)
+		checkAgainstSchema(t, op.OperationID, value, schema, schemas)
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("没有检查任何接口")
+	}
+}
+
+func TestOpenAPIPromotesEmbeddedFields(t *testing.T) {
+	schemas := openAPISchemas{}
+	schemas.schemaOf(reflect.TypeOf(StudentAssignment{}))
+	properties := schemas["StudentAssignment"].(utils.H)["properties"].(utils.H)
+	for _, name := range []string{"id", "title", "due", "overdue", "progress"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("StudentAssignment 缺少字段 %s: %v", name, properties)
+		}
+	}
+	if _, ok := properties["Assignment"]; ok {
+		t.Error("嵌入的 Assignment 不应作为嵌套字段列出")
+	}
+}
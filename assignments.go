@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 班级作业 ---
+// 老师按课程、章节、题型和题数布置作业，题目用固定的随机种子选出并在布置时保存题目ID，所有学生拿到的题目和顺序都相同
+// （与相同参数、相同编号的打印试卷一致）。学生在登录时看到待完成的作业，以答题模式完成；
+// 作答时按题库答案判分并记入作业，老师可以查看完成情况和答错最多的题，并导出为 CSV。
+
+const (
+	assignmentStatusNotStarted = "not_started"
+	assignmentStatusInProgress = "in_progress"
+	assignmentStatusCompleted  = "completed"
+	assignmentMostMissedLimit  = 10 // 结果中最多列出的易错题数
+)
+
+var (
+	assignments   []Assignment // 全部作业（来自旁路文件）
+	assignmentsMu sync.Mutex   // 保护 assignments 及其文件
+)
+
+// errUnknownAssignment 表示作业不存在
+var errUnknownAssignment = &appError{Code: codeAssignmentNotFound}
+
+// loadAssignments 从旁路文件加载作业
+func loadAssignments() {
+	loaded := []Assignment{}
+	if err := loadOverlayJSON(assignmentsFile, &loaded); err != nil {
+		slog.Error("加载作业文件失败", "error", err)
+		loaded = []Assignment{}
+	}
+	assignmentsMu.Lock()
+	assignments = loaded
+	assignmentsMu.Unlock()
+	slog.Info("加载作业", "count", len(loaded))
+}
+
+// listAssignments 返回全部作业的副本，按截止时间排序
+func listAssignments() []Assignment {
+	assignmentsMu.Lock()
+	list := append([]Assignment{}, assignments...)
+	assignmentsMu.Unlock()
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Due.Before(list[j].Due) })
+	return list
+}
+
+// findAssignment 按 ID 查找作业
+func findAssignment(id string) (Assignment, bool) {
+	assignmentsMu.Lock()
+	defer assignmentsMu.Unlock()
+	for _, a := range assignments {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Assignment{}, false
+}
+
+// assignmentQuestions 返回作业的题目：按布置时保存的题目ID取出；没有保存题目ID的旧作业按选择和种子重新选题
+func assignmentQuestions(a Assignment) ([]Question, error) {
+	if len(a.QuestionIDs) == 0 {
+		return selectAssignmentQuestions(a)
+	}
+	questions := make([]Question, 0, len(a.QuestionIDs))
+	for _, id := range a.QuestionIDs {
+		q, ok := lookupQuestion(id)
+		if !ok {
+			slog.Warn("作业中的题目已不在题库中", "assignment_id", a.ID, "question_id", id)
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// selectAssignmentQuestions 按作业的选择选题：按题型筛选，用作业的种子打乱后取前 Count 题
+func selectAssignmentQuestions(a Assignment) ([]Question, error) {
+	questions, err := _getQuestionsForProcessing(a.Course, a.ChapterChoice, "sequential", nil)
+	if err != nil {
+		return nil, err
+	}
+	questions = filterQuestionTypes(questions, a.QuestionTypes)
+	rng, _ := newRunRNG(a.Seed)
+	rng.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+	if a.Count > 0 && a.Count < len(questions) {
+		questions = questions[:a.Count]
+	}
+	return questions, nil
+}
+
+// parseAssignmentDue 解析截止时间：只有日期时为当天结束（按学习日时区），否则按 RFC 3339 解析
+func parseAssignmentDue(value string) (time.Time, error) {
+	if day, err := time.ParseInLocation(activityDateLayout, value, studyLocation); err == nil {
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, newAppError(codeInvalidDue, value)
+	}
+	return due, nil
+}
+
+// createAssignment 校验并保存一份新作业
+func createAssignment(req CreateAssignmentRequest) (Assignment, int, error) {
+	if _, ok := courseCatalog[req.Course]; !ok {
+		return Assignment{}, 0, newAppError(codeUnknownCourse, req.Course)
+	}
+	if req.Count < 0 {
+		return Assignment{}, 0, newAppError(codeNegativeValue, "题数", req.Count)
+	}
+	due, err := parseAssignmentDue(req.Due)
+	if err != nil {
+		return Assignment{}, 0, err
+	}
+	_, seed := newRunRNG(req.Seed)
+	now := time.Now()
+	a := Assignment{
+		Title:         req.Title,
+		Course:        req.Course,
+		ChapterChoice: req.ChapterChoice,
+		QuestionTypes: req.QuestionTypes,
+		Count:         req.Count,
+		Seed:          seed,
+		Due:           due,
+		CreatedAt:     now,
+	}
+	questions, err := selectAssignmentQuestions(a)
+	if err != nil {
+		return Assignment{}, 0, err
+	}
+	if len(questions) == 0 {
+		return Assignment{}, 0, newAppError(codeEmptySelection)
+	}
+	a.QuestionIDs = make([]string, len(questions))
+	for i, q := range questions {
+		a.QuestionIDs[i] = questionIDOf(q)
+	}
+
+	assignmentsMu.Lock()
+	defer assignmentsMu.Unlock()
+	// ID 为 布置日期-序号，便于在命令行中输入
+	prefix := now.In(studyLocation).Format("20060102")
+	used := make(map[string]bool)
+	for _, existing := range assignments {
+		used[existing.ID] = true
+	}
+	for sequence := 1; a.ID == "" || used[a.ID]; sequence++ {
+		a.ID = fmt.Sprintf("%s-%d", prefix, sequence)
+	}
+	updated := append(append([]Assignment{}, assignments...), a)
+	if err := saveOverlayJSON(assignmentsFile, updated); err != nil {
+		return Assignment{}, 0, &userDataError{Message: "保存作业失败", Err: err}
+	}
+	assignments = updated
+	return a, len(questions), nil
+}
+
+// deleteAssignment 删除一份作业（学生的作答记录保留），返回是否找到
+func deleteAssignment(id string) (bool, error) {
+	assignmentsMu.Lock()
+	defer assignmentsMu.Unlock()
+	updated := make([]Assignment, 0, len(assignments))
+	for _, a := range assignments {
+		if a.ID != id {
+			updated = append(updated, a)
+		}
+	}
+	if len(updated) == len(assignments) {
+		return false, nil
+	}
+	if err := saveOverlayJSON(assignmentsFile, updated); err != nil {
+		return false, &userDataError{Message: "保存作业失败", Err: err}
+	}
+	assignments = updated
+	return true, nil
+}
+
+// loadAssignmentWork 加载学生的全部作业作答记录（作业ID -> 记录）
+func loadAssignmentWork(userID string) (map[string]AssignmentSubmission, error) {
+	work := make(map[string]AssignmentSubmission)
+	if err := loadUserJSONData(userID, assignmentWorkFile, &work); err != nil {
+		return nil, &userDataError{Message: "加载作业记录失败", Err: err}
+	}
+	return work, nil
+}
+
+// assignmentProgress 计算学生在一份作业上的进度
+func assignmentProgress(a Assignment, total int, submission AssignmentSubmission) AssignmentProgress {
+	progress := AssignmentProgress{Answered: len(submission.Answers), Total: total}
+	for _, answer := range submission.Answers {
+		if answer.Correct {
+			progress.Correct++
+		}
+	}
+	if !submission.CompletedAt.IsZero() {
+		progress.Completed = true
+		progress.Late = submission.CompletedAt.After(a.Due)
+	}
+	return progress
+}
+
+// studentAssignments 返回学生看到的作业及进度，pendingOnly 时只返回未完成的作业
+func studentAssignments(userID string, pendingOnly bool) ([]StudentAssignment, error) {
+	work, err := loadAssignmentWork(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := []StudentAssignment{}
+	for _, a := range listAssignments() {
+		questions, err := assignmentQuestions(a)
+		if err != nil {
+			slog.Warn("作业选题失败", "assignment_id", a.ID, "error", err)
+			continue
+		}
+		progress := assignmentProgress(a, len(questions), work[a.ID])
+		if pendingOnly && progress.Completed {
+			continue
+		}
+		list = append(list, StudentAssignment{Assignment: a, Overdue: now.After(a.Due), Progress: progress})
+	}
+	return list, nil
+}
+
+// startAssignment 记录学生开始做作业，返回作业的题目
+func startAssignment(userID, id string) (Assignment, []Question, error) {
+	a, ok := findAssignment(id)
+	if !ok {
+		return a, nil, errUnknownAssignment.with(id)
+	}
+	questions, err := assignmentQuestions(a)
+	if err != nil {
+		return a, nil, err
+	}
+	work, err := loadAssignmentWork(userID)
+	if err != nil {
+		return a, nil, err
+	}
+	if submission := work[id]; submission.StartedAt.IsZero() {
+		submission.StartedAt = time.Now()
+		submission.Answers = make(map[string]AssignmentAnswer)
+		work[id] = submission
+		if err := saveUserJSONData(userID, assignmentWorkFile, work); err != nil {
+			return a, nil, &userDataError{Message: "保存作业记录失败", Err: err}
+		}
+	}
+	return a, questions, nil
+}
+
+// recordAssignmentAnswer 把答题模式中的一次作答记入作业。按题库答案判分，同一道题只记第一次作答；
+// 答完全部题目时记录完成时间。
+func recordAssignmentAnswer(userID, id string, q Question, userAnswer string) (AssignmentProgress, error) {
+	a, ok := findAssignment(id)
+	if !ok {
+		return AssignmentProgress{}, errUnknownAssignment.with(id)
+	}
+	questions, err := assignmentQuestions(a)
+	if err != nil {
+		return AssignmentProgress{}, err
+	}
+	questionID := questionIDOf(q)
+	inAssignment := false
+	for _, aq := range questions {
+		if questionIDOf(aq) == questionID {
+			inAssignment = true
+			break
+		}
+	}
+	if !inAssignment {
+		return AssignmentProgress{}, newAppError(codeNotInAssignment, questionID, id)
+	}
+
+	work, err := loadAssignmentWork(userID)
+	if err != nil {
+		return AssignmentProgress{}, err
+	}
+	submission := work[id]
+	now := time.Now()
+	if submission.StartedAt.IsZero() {
+		submission.StartedAt = now
+	}
+	if submission.Answers == nil {
+		submission.Answers = make(map[string]AssignmentAnswer)
+	}
+	if _, answered := submission.Answers[questionID]; !answered {
+		submission.Answers[questionID] = AssignmentAnswer{
+			Answer:     normalizeAnswer(userAnswer),
+			Correct:    normalizeAnswer(userAnswer) == normalizeAnswer(q.CorrectAnswer),
+			AnsweredAt: now,
+		}
+	}
+	if submission.CompletedAt.IsZero() && len(submission.Answers) >= len(questions) {
+		submission.CompletedAt = now
+	}
+	work[id] = submission
+	if err := saveUserJSONData(userID, assignmentWorkFile, work); err != nil {
+		return AssignmentProgress{}, &userDataError{Message: "保存作业记录失败", Err: err}
+	}
+	return assignmentProgress(a, len(questions), submission), nil
+}
+
+// computeAssignmentResults 汇总 user_data 下所有学生在一份作业上的完成情况和答错最多的题
+func computeAssignmentResults(id string) (AssignmentResults, error) {
+	a, ok := findAssignment(id)
+	if !ok {
+		return AssignmentResults{}, errUnknownAssignment.with(id)
+	}
+	questions, err := assignmentQuestions(a)
+	if err != nil {
+		return AssignmentResults{}, err
+	}
+	results := AssignmentResults{Assignment: a, TotalQuestions: len(questions), Students: []AssignmentStudentResult{}, MostMissed: []AssignmentMissedQuestion{}}
+
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return results, &userDataError{Message: "读取用户数据目录失败", Err: err}
+	}
+	answered := make(map[string]int)
+	missed := make(map[string]int)
+	wrongAnswers := make(map[string]map[string]int) // 题目ID -> 错误答案 -> 人数
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		work, err := loadAssignmentWork(userID)
+		if err != nil {
+			slog.Warn("读取作业记录失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		submission, started := work[id]
+		progress := assignmentProgress(a, len(questions), submission)
+		student := AssignmentStudentResult{UserID: userID, Status: assignmentStatusNotStarted, Answered: progress.Answered,
+			Correct: progress.Correct, CompletedAt: submission.CompletedAt, Late: progress.Late}
+		if len(questions) > 0 {
+			student.Score = float64(progress.Correct) * 100 / float64(len(questions))
+		}
+		switch {
+		case progress.Completed:
+			student.Status = assignmentStatusCompleted
+			results.Completed++
+		case started:
+			student.Status = assignmentStatusInProgress
+		}
+		results.Students = append(results.Students, student)
+
+		for questionID, answer := range submission.Answers {
+			answered[questionID]++
+			if !answer.Correct {
+				missed[questionID]++
+				if wrongAnswers[questionID] == nil {
+					wrongAnswers[questionID] = make(map[string]int)
+				}
+				wrongAnswers[questionID][answer.Answer]++
+			}
+		}
+	}
+	sort.SliceStable(results.Students, func(i, j int) bool { return results.Students[i].UserID < results.Students[j].UserID })
+
+	for _, q := range questions {
+		questionID := questionIDOf(q)
+		if missed[questionID] == 0 {
+			continue
+		}
+		item := AssignmentMissedQuestion{QuestionID: questionID, QuestionText: q.QuestionText, Answered: answered[questionID], Missed: missed[questionID]}
+		for answer, count := range wrongAnswers[questionID] {
+			if top := wrongAnswers[questionID][item.TopWrong]; count > top || (count == top && answer < item.TopWrong) {
+				item.TopWrong = answer
+			}
+		}
+		results.MostMissed = append(results.MostMissed, item)
+	}
+	sort.SliceStable(results.MostMissed, func(i, j int) bool { return results.MostMissed[i].Missed > results.MostMissed[j].Missed })
+	if len(results.MostMissed) > assignmentMostMissedLimit {
+		results.MostMissed = results.MostMissed[:assignmentMostMissedLimit]
+	}
+	return results, nil
+}
+
+// assignmentStatusNames 作业状态的中文名称，用于 CSV 和命令行输出
+var assignmentStatusNames = map[string]string{
+	assignmentStatusNotStarted: "未开始",
+	assignmentStatusInProgress: "进行中",
+	assignmentStatusCompleted:  "已完成",
+}
+
+// writeAssignmentResultsCSV 把作业结果写成 CSV（带 BOM，Excel 可以直接打开）。
+// table 为 "students"（默认）时每名学生一行，为 "missed" 时每道易错题一行。
+func writeAssignmentResultsCSV(w io.Writer, results AssignmentResults, table string) error {
+	var rows [][]string
+	switch table {
+	case "", "students":
+		rows = append(rows, []string{"用户", "状态", "已答", "答对", "总题数", "得分", "完成时间", "迟交"})
+		for _, s := range results.Students {
+			completedAt, late := "", ""
+			if !s.CompletedAt.IsZero() {
+				completedAt = s.CompletedAt.In(studyLocation).Format("2006-01-02 15:04")
+			}
+			if s.Late {
+				late = "是"
+			}
+			rows = append(rows, []string{s.UserID, assignmentStatusNames[s.Status], strconv.Itoa(s.Answered), strconv.Itoa(s.Correct),
+				strconv.Itoa(results.TotalQuestions), strconv.FormatFloat(s.Score, 'f', 1, 64), completedAt, late})
+		}
+	case "missed":
+		rows = append(rows, []string{"题目ID", "题干", "作答人数", "答错人数", "最多人选的错误答案"})
+		for _, m := range results.MostMissed {
+			rows = append(rows, []string{m.QuestionID, m.QuestionText, strconv.Itoa(m.Answered), strconv.Itoa(m.Missed), m.TopWrong})
+		}
+	default:
+		return newOptionError("table", table, "students", "missed")
+	}
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// CreateAssignmentHandler 布置作业（需要老师权限）
+func CreateAssignmentHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireTeacher(c) {
+		return
+	}
+	var req CreateAssignmentRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	a, total, err := createAssignment(req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	slog.InfoContext(ctx, "布置作业", "assignment_id", a.ID, "title", a.Title, "course", a.Course, "chapters", a.ChapterChoice, "count", total, "due", a.Due.Format(time.RFC3339))
+	c.JSON(consts.StatusOK, utils.H{"assignment": a, "total_questions": total})
+}
+
+// TeacherAssignmentsListHandler 列出全部作业及完成人数（需要老师权限）
+func TeacherAssignmentsListHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireTeacher(c) {
+		return
+	}
+	list := []utils.H{}
+	for _, a := range listAssignments() {
+		results, err := computeAssignmentResults(a.ID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		list = append(list, utils.H{"assignment": a, "total_questions": results.TotalQuestions, "completed": results.Completed})
+	}
+	c.JSON(consts.StatusOK, utils.H{"assignments": list})
+}
+
+// DeleteAssignmentHandler 删除作业（需要老师权限）
+func DeleteAssignmentHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireTeacher(c) {
+		return
+	}
+	var req DeleteAssignmentRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	found, err := deleteAssignment(req.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if !found {
+		writeError(c, errUnknownAssignment.with(req.ID))
+		return
+	}
+	slog.InfoContext(ctx, "作业已删除", "assignment_id", req.ID)
+	c.Status(consts.StatusNoContent)
+}
+
+// AssignmentResultsHandler 返回作业的完成情况，format=csv 时导出 CSV（需要老师权限）
+func AssignmentResultsHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireTeacher(c) {
+		return
+	}
+	var req AssignmentResultsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	results, err := computeAssignmentResults(req.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	switch req.Format {
+	case "", "json":
+		c.JSON(consts.StatusOK, results)
+	case "csv":
+		var buf bytes.Buffer
+		if err := writeAssignmentResultsCSV(&buf, results, req.Table); err != nil {
+			writeError(c, err)
+			return
+		}
+		table := req.Table
+		if table == "" {
+			table = "students"
+		}
+		c.Header("Content-Disposition", `attachment; filename="assignment_`+results.Assignment.ID+`_`+table+`.csv"`)
+		c.Data(consts.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	default:
+		writeError(c, newOptionError("format", req.Format, "json", "csv"))
+	}
+}
+
+// StudentAssignmentsHandler 返回学生的全部作业及进度
+func StudentAssignmentsHandler(ctx context.Context, c *app.RequestContext) {
+	var req StudentAssignmentsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	list, err := studentAssignments(req.UserID, false)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, AssignmentListResponse{Assignments: list})
+}
+
+// StartAssignmentHandler 以答题模式开始做作业，返回作业的全部题目（带作业ID，提交答案时带上）
+func StartAssignmentHandler(ctx context.Context, c *app.RequestContext) {
+	var req StartAssignmentRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := startAssignmentQuiz(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// startAssignmentQuiz 把会话切换到答题模式并返回作业的全部题目
+func startAssignmentQuiz(ctx context.Context, req StartAssignmentRequest) (AssignmentStartResponse, error) {
+	session := getOrCreateUserSession(req.UserID)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	a, questions, err := startAssignment(req.UserID, req.AssignmentID)
+	if err != nil {
+		return AssignmentStartResponse{}, err
+	}
+	outputQuestions := withUserNotes(req.UserID, convertQuestionsToOutput(questions, 0))
+	for i := range outputQuestions {
+		outputQuestions[i].AssignmentID = a.ID
+	}
+	session.CurrentMode = "quiz"
+	session.CurrentCourse = a.Course
+	session.ServedAt = make(map[string]time.Time)
+
+	slog.InfoContext(ctx, "开始作业", logKeyUserID, req.UserID, "assignment_id", a.ID, "title", a.Title, "count", len(outputQuestions))
+	return AssignmentStartResponse{
+		Message:        "作业开始",
+		Assignment:     a,
+		TotalQuestions: len(outputQuestions),
+		Questions:      outputQuestions,
+	}, nil
+}
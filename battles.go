@@ -0,0 +1,696 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+)
+
+// --- 实时对战 ---
+// 一名用户按课程、章节、题数和随机种子创建房间，其他人凭房间码加入，房主开始后所有人同时作答同一组题。
+// 服务端通过 SSE 推送事件：room（房间状态）、question（揭晓题目，不含答案）、answer_status（某人已作答）、
+// question_result（公布答案、各人作答和计分板）、finished（最终排名）。答案由服务端判分，
+// 每题在所有人作答或限时结束后公布；对战结束后每名参加者的结果保存到各自的用户数据中。
+// 房间只保存在内存中，重启服务后进行中的对战会丢失。
+
+const (
+	battleStateWaiting     = "waiting"
+	battleStatePlaying     = "playing"
+	battleStateFinished    = "finished"
+	battleDefaultCount     = 20
+	battleMaxCount         = 100
+	battleDefaultSeconds   = 30
+	battleMinSeconds       = 5
+	battleMaxSeconds       = 120
+	battleMaxPlayers       = 20
+	battleCorrectPoints    = 100              // 答对的基础分
+	battleSpeedPoints      = 100              // 按剩余时间比例加的最高分
+	battleResultPause      = 3 * time.Second  // 公布答案后到下一题的间隔
+	battleKeepAlive        = 15 * time.Second // 事件流的保活间隔，同时用来发现断开的连接
+	battleRoomTTL          = 2 * time.Hour    // 房间最后一次活动后多久清理
+	battleEventBuffer      = 32               // 每个订阅者缓冲的事件数，来不及接收时断开该订阅者
+	battleHistoryRetention = 100              // 每名用户最多保存的对战结果数
+
+	battleCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // 房间码字符，去掉了容易混淆的 I、O、0、1
+	battleCodeLength   = 6
+)
+
+var (
+	battleRooms   = make(map[string]*battleRoom) // 房间码 -> 房间
+	battleRoomsMu sync.Mutex
+)
+
+// errUnknownBattle 表示房间不存在（或已被清理）
+var errUnknownBattle = &appError{Code: codeBattleNotFound}
+
+// battleEvent 推送给订阅者的一条事件，数据已序列化为 JSON
+type battleEvent struct {
+	id   string
+	name string
+	data []byte
+}
+
+// battleRoom 一个对战房间，mu 保护其余字段
+type battleRoom struct {
+	mu              sync.Mutex
+	code            string
+	host            string
+	course          string
+	chapterChoice   []string
+	seed            int64
+	questionSeconds int
+	questions       []Question
+	state           string
+	current         int       // 当前题目序号，未开始时为 -1
+	questionOpen    bool      // 当前题目是否还在接受作答
+	revealedAt      time.Time // 当前题目的揭晓时间
+	timer           *time.Timer
+	players         []string // 按加入顺序
+	scores          map[string]*BattlePlayerScore
+	answers         map[string]BattlePlayerAnswer // 当前题目的作答
+	subscribers     map[chan battleEvent]string   // 事件通道 -> 用户
+	seq             int                           // 事件序号
+	updatedAt       time.Time
+}
+
+// newBattleCode 生成一个未被占用的房间码，调用方需持有 battleRoomsMu
+func newBattleCode() (string, error) {
+	for {
+		var b strings.Builder
+		for i := 0; i < battleCodeLength; i++ {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(battleCodeAlphabet))))
+			if err != nil {
+				return "", fmt.Errorf("生成房间码失败: %w", err)
+			}
+			b.WriteByte(battleCodeAlphabet[n.Int64()])
+		}
+		if _, taken := battleRooms[b.String()]; !taken {
+			return b.String(), nil
+		}
+	}
+}
+
+// sweepBattleRooms 清理长时间没有活动的房间，调用方需持有 battleRoomsMu
+func sweepBattleRooms(now time.Time) {
+	for code, room := range battleRooms {
+		room.mu.Lock()
+		idle := now.Sub(room.updatedAt) > battleRoomTTL && room.state != battleStatePlaying
+		room.mu.Unlock()
+		if idle {
+			delete(battleRooms, code)
+			slog.Info("对战房间长时间无活动，已清理", "code", code)
+		}
+	}
+}
+
+// createBattle 创建房间，题目在创建时按种子选好，创建者为房主并自动加入
+func createBattle(req CreateBattleRequest) (*battleRoom, error) {
+	count := req.Count
+	if count == 0 {
+		count = battleDefaultCount
+	}
+	if count < 1 || count > battleMaxCount {
+		return nil, newAppError(codeOutOfRange, "题数", 1, battleMaxCount)
+	}
+	seconds := req.QuestionSeconds
+	if seconds == 0 {
+		seconds = battleDefaultSeconds
+	}
+	if seconds < battleMinSeconds || seconds > battleMaxSeconds {
+		return nil, newAppError(codeOutOfRange, "每题限时（秒）", battleMinSeconds, battleMaxSeconds)
+	}
+	rng, seed := newRunRNG(req.Seed)
+	questions, err := _getQuestionsForProcessing(req.Course, req.ChapterChoice, "random", rng)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, newAppError(codeEmptySelection)
+	}
+	if len(questions) > count {
+		questions = questions[:count]
+	}
+
+	battleRoomsMu.Lock()
+	defer battleRoomsMu.Unlock()
+	now := time.Now()
+	sweepBattleRooms(now)
+	code, err := newBattleCode()
+	if err != nil {
+		return nil, err
+	}
+	room := &battleRoom{
+		code:            code,
+		host:            req.UserID,
+		course:          req.Course,
+		chapterChoice:   req.ChapterChoice,
+		seed:            seed,
+		questionSeconds: seconds,
+		questions:       questions,
+		state:           battleStateWaiting,
+		current:         -1,
+		players:         []string{req.UserID},
+		scores:          map[string]*BattlePlayerScore{req.UserID: {UserID: req.UserID}},
+		answers:         make(map[string]BattlePlayerAnswer),
+		subscribers:     make(map[chan battleEvent]string),
+		updatedAt:       now,
+	}
+	battleRooms[code] = room
+	return room, nil
+}
+
+// findBattle 按房间码查找房间，房间码不区分大小写
+func findBattle(code string) (*battleRoom, error) {
+	battleRoomsMu.Lock()
+	defer battleRoomsMu.Unlock()
+	room, ok := battleRooms[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return nil, errUnknownBattle.with(code)
+	}
+	return room, nil
+}
+
+// scoreboard 按得分、答对题数、用时排出计分板，得分相同的名次相同，调用方需持有 r.mu
+func (r *battleRoom) scoreboard() []BattlePlayerScore {
+	connected := make(map[string]bool)
+	for _, userID := range r.subscribers {
+		connected[userID] = true
+	}
+	board := make([]BattlePlayerScore, 0, len(r.players))
+	for _, userID := range r.players {
+		score := *r.scores[userID]
+		score.Connected = connected[userID]
+		board = append(board, score)
+	}
+	sort.SliceStable(board, func(i, j int) bool {
+		if board[i].Score != board[j].Score {
+			return board[i].Score > board[j].Score
+		}
+		if board[i].Correct != board[j].Correct {
+			return board[i].Correct > board[j].Correct
+		}
+		return board[i].TimeMs < board[j].TimeMs
+	})
+	for i := range board {
+		if i > 0 && board[i].Score == board[i-1].Score {
+			board[i].Rank = board[i-1].Rank
+		} else {
+			board[i].Rank = i + 1
+		}
+	}
+	return board
+}
+
+// snapshot 返回房间当前状态，调用方需持有 r.mu
+func (r *battleRoom) snapshot() BattleRoom {
+	return BattleRoom{
+		Code:            r.code,
+		Host:            r.host,
+		Course:          r.course,
+		ChapterChoice:   r.chapterChoice,
+		Seed:            r.seed,
+		QuestionSeconds: r.questionSeconds,
+		State:           r.state,
+		CurrentIndex:    r.current,
+		Total:           len(r.questions),
+		Players:         r.scoreboard(),
+	}
+}
+
+// currentQuestion 返回当前题目（不含答案），调用方需持有 r.mu
+func (r *battleRoom) currentQuestion() BattleQuestion {
+	output := convertQuestionsToOutput(r.questions[r.current:r.current+1], r.current)[0]
+	output.QuizQuestionID = fmt.Sprintf("battle_%s_%d", r.code, r.current)
+	output.CorrectAnswer = "" // 由服务端判分，公布答案前不下发
+	return BattleQuestion{
+		Index:    r.current,
+		Total:    len(r.questions),
+		Deadline: r.revealedAt.Add(time.Duration(r.questionSeconds) * time.Second),
+		Question: output,
+	}
+}
+
+// newEvent 序列化一条事件并分配序号，调用方需持有 r.mu
+func (r *battleRoom) newEvent(name string, data interface{}) battleEvent {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("序列化对战事件失败", "code", r.code, "event", name, "error", err)
+		payload = []byte("{}")
+	}
+	r.seq++
+	return battleEvent{id: strconv.Itoa(r.seq), name: name, data: payload}
+}
+
+// broadcast 向所有订阅者推送事件。订阅者的缓冲已满时（接收太慢）断开它，由客户端重新连接。调用方需持有 r.mu
+func (r *battleRoom) broadcast(name string, data interface{}) {
+	event := r.newEvent(name, data)
+	r.updatedAt = time.Now()
+	for ch, userID := range r.subscribers {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("对战事件接收太慢，已断开", "code", r.code, logKeyUserID, userID)
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// join 加入房间，已在房间中时直接返回房间状态
+func (r *battleRoom) join(userID string) (BattleRoom, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, joined := r.scores[userID]; joined {
+		return r.snapshot(), nil
+	}
+	if r.state != battleStateWaiting {
+		return BattleRoom{}, newAppError(codeBattleStarted)
+	}
+	if len(r.players) >= battleMaxPlayers {
+		return BattleRoom{}, newAppError(codeBattleFull, battleMaxPlayers)
+	}
+	r.players = append(r.players, userID)
+	r.scores[userID] = &BattlePlayerScore{UserID: userID}
+	snapshot := r.snapshot()
+	r.broadcast("room", snapshot)
+	return snapshot, nil
+}
+
+// start 由房主开始对战，揭晓第一题
+func (r *battleRoom) start(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if userID != r.host {
+		return newAppError(codeNotBattleHost)
+	}
+	if r.state != battleStateWaiting {
+		return newAppError(codeBattleStarted)
+	}
+	r.state = battleStatePlaying
+	r.broadcast("room", r.snapshot())
+	r.reveal(0)
+	return nil
+}
+
+// reveal 揭晓第 index 题并开始计时，调用方需持有 r.mu
+func (r *battleRoom) reveal(index int) {
+	r.current = index
+	r.questionOpen = true
+	r.revealedAt = time.Now()
+	r.answers = make(map[string]BattlePlayerAnswer)
+	r.broadcast("question", r.currentQuestion())
+	r.timer = time.AfterFunc(time.Duration(r.questionSeconds)*time.Second, func() { r.closeQuestion(index) })
+}
+
+// answer 记录玩家对当前题目的作答，每题只接受第一次作答。所有人都作答后立即公布答案。
+func (r *battleRoom) answer(userID string, index int, userAnswer string) (Question, bool, time.Duration, error) {
+	r.mu.Lock()
+	score, joined := r.scores[userID]
+	switch {
+	case !joined:
+		r.mu.Unlock()
+		return Question{}, false, 0, newAppError(codeNotBattlePlayer)
+	case r.state != battleStatePlaying:
+		r.mu.Unlock()
+		return Question{}, false, 0, newAppError(codeBattleNotPlaying)
+	case index != r.current || !r.questionOpen:
+		r.mu.Unlock()
+		return Question{}, false, 0, newAppError(codeQuestionClosed, index+1)
+	}
+	if _, answered := r.answers[userID]; answered {
+		r.mu.Unlock()
+		return Question{}, false, 0, newAppError(codeAlreadyAnswered)
+	}
+
+	q := r.questions[index]
+	limit := time.Duration(r.questionSeconds) * time.Second
+	elapsed := min(time.Since(r.revealedAt), limit)
+	correct := normalizeAnswer(userAnswer) == normalizeAnswer(q.CorrectAnswer)
+	points := 0
+	if correct {
+		points = battleCorrectPoints + int(int64(battleSpeedPoints)*int64(limit-elapsed)/int64(limit))
+		score.Correct++
+	}
+	score.Score += points
+	score.Answered++
+	score.TimeMs += elapsed.Milliseconds()
+	r.answers[userID] = BattlePlayerAnswer{UserID: userID, Answer: normalizeAnswer(userAnswer), Correct: correct, Points: points, TimeMs: elapsed.Milliseconds()}
+	r.broadcast("answer_status", BattleAnswerStatus{Index: index, UserID: userID, Answered: len(r.answers), Players: len(r.players)})
+	allAnswered := len(r.answers) == len(r.players)
+	r.mu.Unlock()
+
+	if allAnswered {
+		r.closeQuestion(index)
+	}
+	return q, correct, elapsed, nil
+}
+
+// closeQuestion 结束第 index 题：公布答案和计分板，然后进入下一题或结束对战。
+// 限时到了和所有人都作答都会调用，只有第一次生效。
+func (r *battleRoom) closeQuestion(index int) {
+	r.mu.Lock()
+	if r.state != battleStatePlaying || r.current != index || !r.questionOpen {
+		r.mu.Unlock()
+		return
+	}
+	r.questionOpen = false
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	result := BattleQuestionResult{Index: index, CorrectAnswer: r.questions[index].CorrectAnswer, Answers: []BattlePlayerAnswer{}}
+	for _, userID := range r.players {
+		answer, ok := r.answers[userID]
+		if !ok {
+			answer = BattlePlayerAnswer{UserID: userID}
+		}
+		result.Answers = append(result.Answers, answer)
+	}
+	result.Scoreboard = r.scoreboard()
+	r.broadcast("question_result", result)
+
+	if index+1 < len(r.questions) {
+		r.timer = time.AfterFunc(battleResultPause, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.state == battleStatePlaying && r.current == index {
+				r.reveal(index + 1)
+			}
+		})
+		r.mu.Unlock()
+		return
+	}
+
+	r.state = battleStateFinished
+	finishedAt := time.Now()
+	board := r.scoreboard()
+	r.broadcast("finished", r.snapshot())
+	for ch := range r.subscribers { // 对战结束，关闭所有事件流
+		delete(r.subscribers, ch)
+		close(ch)
+	}
+	r.mu.Unlock()
+
+	slog.Info("对战结束", "code", r.code, "players", len(board), "questions", len(r.questions))
+	for _, player := range board {
+		result := BattleResult{
+			Code:       r.code,
+			Course:     r.course,
+			Seed:       r.seed,
+			Total:      len(r.questions),
+			Rank:       player.Rank,
+			Score:      player.Score,
+			Correct:    player.Correct,
+			FinishedAt: finishedAt,
+			Scoreboard: board,
+		}
+		if err := saveBattleResult(player.UserID, result); err != nil {
+			slog.Error("保存对战结果失败", "code", r.code, logKeyUserID, player.UserID, "error", err)
+		}
+	}
+}
+
+// subscribe 订阅房间事件，先放入当前房间状态（进行中时还有当前题目），并通知其他人连接状态的变化
+func (r *battleRoom) subscribe(userID string) (chan battleEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, joined := r.scores[userID]; !joined {
+		return nil, newAppError(codeNotBattlePlayer)
+	}
+	ch := make(chan battleEvent, battleEventBuffer)
+	if r.state == battleStateFinished {
+		ch <- r.newEvent("finished", r.snapshot())
+		close(ch)
+		return ch, nil
+	}
+	r.subscribers[ch] = userID
+	r.broadcast("room", r.snapshot())
+	if r.state == battleStatePlaying && r.questionOpen {
+		ch <- r.newEvent("question", r.currentQuestion())
+	}
+	return ch, nil
+}
+
+// unsubscribe 取消订阅（连接断开时），通道已被关闭时不做任何事
+func (r *battleRoom) unsubscribe(ch chan battleEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subscribers[ch]; !ok {
+		return
+	}
+	delete(r.subscribers, ch)
+	close(ch)
+	r.broadcast("room", r.snapshot())
+}
+
+// shutdownBattles 服务退出时通知所有订阅者并关闭事件流，让长连接尽快结束。
+// 进行中的对战停止计时，不再产生对战结果。返回被中断的对战数和关闭的事件流数。
+func shutdownBattles() (interrupted, streams int) {
+	battleRoomsMu.Lock()
+	defer battleRoomsMu.Unlock()
+	for _, room := range battleRooms {
+		room.mu.Lock()
+		if room.state == battleStatePlaying {
+			interrupted++
+			room.questionOpen = false
+			if room.timer != nil {
+				room.timer.Stop()
+			}
+		}
+		room.broadcast("shutdown", MessageResponse{Message: "服务器正在重启，对战已中断"})
+		for ch := range room.subscribers {
+			delete(room.subscribers, ch)
+			close(ch)
+			streams++
+		}
+		room.mu.Unlock()
+	}
+	return interrupted, streams
+}
+
+// loadBattleResults 加载用户的对战记录，最近的在前
+func loadBattleResults(userID string) ([]BattleResult, error) {
+	results := []BattleResult{}
+	if err := loadUserJSONData(userID, battleResultsFile, &results); err != nil {
+		return nil, &userDataError{Message: "加载对战记录失败", Err: err}
+	}
+	return results, nil
+}
+
+// saveBattleResult 把一场对战的结果加到用户的对战记录最前面
+func saveBattleResult(userID string, result BattleResult) error {
+	results, err := loadBattleResults(userID)
+	if err != nil {
+		return err
+	}
+	results = append([]BattleResult{result}, results...)
+	if len(results) > battleHistoryRetention {
+		results = results[:battleHistoryRetention]
+	}
+	if err := saveUserJSONData(userID, battleResultsFile, results); err != nil {
+		return &userDataError{Message: "保存对战记录失败", Err: err}
+	}
+	return nil
+}
+
+// CreateBattleHandler 创建对战房间，返回房间码
+func CreateBattleHandler(ctx context.Context, c *app.RequestContext) {
+	var req CreateBattleRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	snapshot, err := createBattleRoom(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, snapshot)
+}
+
+// createBattleRoom 创建对战房间并返回房间状态
+func createBattleRoom(ctx context.Context, req CreateBattleRequest) (BattleRoom, error) {
+	room, err := createBattle(req)
+	if err != nil {
+		return BattleRoom{}, err
+	}
+	room.mu.Lock()
+	snapshot := room.snapshot()
+	room.mu.Unlock()
+	slog.InfoContext(ctx, "创建对战房间", logKeyUserID, req.UserID, "code", snapshot.Code, "course", req.Course, "questions", snapshot.Total, "question_seconds", snapshot.QuestionSeconds, "seed", snapshot.Seed)
+	return snapshot, nil
+}
+
+// JoinBattleHandler 凭房间码加入对战
+func JoinBattleHandler(ctx context.Context, c *app.RequestContext) {
+	var req BattleRoomRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	snapshot, err := joinBattle(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, snapshot)
+}
+
+// joinBattle 凭房间码加入对战，返回加入后的房间状态
+func joinBattle(ctx context.Context, req BattleRoomRequest) (BattleRoom, error) {
+	room, err := findBattle(req.Code)
+	if err != nil {
+		return BattleRoom{}, err
+	}
+	snapshot, err := room.join(req.UserID)
+	if err != nil {
+		return BattleRoom{}, err
+	}
+	slog.InfoContext(ctx, "加入对战房间", logKeyUserID, req.UserID, "code", snapshot.Code, "players", len(snapshot.Players))
+	return snapshot, nil
+}
+
+// StartBattleHandler 房主开始对战
+func StartBattleHandler(ctx context.Context, c *app.RequestContext) {
+	var req BattleRoomRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	if err := startBattle(ctx, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, MessageResponse{Message: "对战开始"})
+}
+
+// startBattle 由房主开始对战
+func startBattle(ctx context.Context, req BattleRoomRequest) error {
+	room, err := findBattle(req.Code)
+	if err != nil {
+		return err
+	}
+	if err := room.start(req.UserID); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "对战开始", "code", room.code, logKeyUserID, req.UserID)
+	return nil
+}
+
+// BattleAnswerHandler 提交对战中当前题目的答案。对错在本题结束时随 question_result 事件公布，
+// 作答同时计入用户的答题统计和错题本。
+func BattleAnswerHandler(ctx context.Context, c *app.RequestContext) {
+	var req BattleAnswerRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := submitBattleAnswer(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// submitBattleAnswer 记录对战中的一次作答；计入答题统计失败不影响对战
+func submitBattleAnswer(ctx context.Context, req BattleAnswerRequest) (BattleAnswerResponse, error) {
+	room, err := findBattle(req.Code)
+	if err != nil {
+		return BattleAnswerResponse{}, err
+	}
+	q, correct, elapsed, err := room.answer(req.UserID, req.QuestionIndex, req.UserAnswer)
+	if err != nil {
+		return BattleAnswerResponse{}, err
+	}
+
+	session := getOrCreateUserSession(req.UserID)
+	session.mu.Lock()
+	_, err = recordQuizAnswer(ctx, req.UserID, q, req.UserAnswer, correct, elapsed)
+	session.mu.Unlock()
+	if err != nil {
+		slog.WarnContext(ctx, "对战作答未能计入答题统计", "code", req.Code, logKeyUserID, req.UserID, "error", err)
+	}
+	return BattleAnswerResponse{Message: "已作答", QuestionIndex: req.QuestionIndex}, nil
+}
+
+// BattleEventsHandler 以 SSE 推送房间事件，直到对战结束或连接断开
+func BattleEventsHandler(ctx context.Context, c *app.RequestContext) {
+	var req BattleEventsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	room, events, err := subscribeBattle(req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	streamBattleEvents(ctx, c, room, events)
+}
+
+// subscribeBattle 订阅房间事件，调用方负责在结束时取消订阅
+func subscribeBattle(req BattleEventsRequest) (*battleRoom, chan battleEvent, error) {
+	room, err := findBattle(req.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := room.subscribe(req.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, events, nil
+}
+
+// streamBattleEvents 把订阅到的事件写成 SSE，直到对战结束或连接断开
+func streamBattleEvents(ctx context.Context, c *app.RequestContext, room *battleRoom, events chan battleEvent) {
+	defer room.unsubscribe(events)
+
+	w := sse.NewWriter(c)
+	defer w.Close()
+	keepAlive := time.NewTicker(battleKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := w.WriteEvent(event.id, event.name, event.data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := w.WriteKeepAlive(); err != nil {
+				return // 连接已断开
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// BattleHistoryHandler 返回用户参加过的对战的最终结果
+func BattleHistoryHandler(ctx context.Context, c *app.RequestContext) {
+	var req BattleHistoryRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	results, err := loadBattleResults(req.UserID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, BattleHistoryResponse{Total: len(results), Battles: results})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// allChaptersChoice 章节选择中表示“课程的全部章节”的保留键
+const allChaptersChoice = "all"
+
+// virtualChapterSeparator 分隔虚拟课程章节键中的课程与章节，例如 "xigai_yang:3"
+const virtualChapterSeparator = ":"
+
+// courseOrder 课程在目录中的展示顺序（实体课程在前，虚拟课程在后）
+var courseOrder = []string{"maogai", "xigai_li", "xigai_yang", "xigai_all"}
+
+// courseTitles 课程显示名称
+var courseTitles = map[string]string{
+	"maogai":     "2025上毛概康老师",
+	"xigai_li":   "2024下习概李老师",
+	"xigai_yang": "2025下习概杨老师",
+	"xigai_all":  "习概合并(李+杨)",
+}
+
+// courseShortTitles 虚拟课程章节标题中用于区分来源课程的简称
+var courseShortTitles = map[string]string{
+	"maogai":     "毛概",
+	"xigai_li":   "李",
+	"xigai_yang": "杨",
+}
+
+// chapterTitles 实体课程的章节标题，下标即章节键
+var chapterTitles = map[string][]string{
+	"maogai": {
+		"导论",
+		"毛泽东思想及其历史地位",
+		"新民主主义革命理论",
+		"社会主义改造理论",
+		"社会主义建设道路初步探索的理论成果",
+		"中国特色社会主义理论体系的形成发展",
+		"邓小平理论",
+		"“三个代表”重要思想",
+		"科学发展观",
+	},
+	"xigai_li": {
+		"期末复习",
+	},
+	"xigai_yang": {
+		"导论",
+		"新时代坚持和发展中国特色社会主义",
+		"以中国式现代化全面推进中华民族伟大复兴",
+		"坚持党的全面领导",
+		"坚持以人民为中心",
+		"全面深化改革开放",
+		"推动高质量发展",
+		"社会主义现代化建设的教育、科技、人才战略",
+		"发展全过程人民民主",
+		"全面依法治国",
+		"建设社会主义文化强国",
+		"以保障和改善民生为重点加强社会建设",
+		"建设社会主义生态文明",
+		"维护和塑造国家安全",
+		"建设巩固国防和强大人民军队",
+		"坚持“一国两制”和推进祖国完全统一",
+		"中国特色大国外交和推动构建人类命运共同体",
+		"全面从严治党",
+	},
+}
+
+var courseCatalog map[string]*CourseMeta // 课程键 -> 课程元数据，题库加载完成后构建，修正题目后整体替换
+
+// getChapterTitle 返回实体课程章节的标题，没有配置时回退为 "第N章"
+func getChapterTitle(course, chapterKey string) string {
+	titles := chapterTitles[course]
+	if idx, err := strconv.Atoi(chapterKey); err == nil && idx >= 0 && idx < len(titles) {
+		return titles[idx]
+	}
+	return "第" + chapterKey + "章"
+}
+
+// newChapterMeta 根据实体课程的章节题目统计章节元数据
+func newChapterMeta(key, title string, order int, course, chapterKey string) ChapterMeta {
+	questionsByChapter, _, _ := getCourseQuestionBank(course)
+	meta := ChapterMeta{
+		Key:        key,
+		Title:      title,
+		Order:      order,
+		Course:     course,
+		ChapterKey: chapterKey,
+		TypeCounts: make(map[string]int),
+	}
+	for _, q := range questionsByChapter[chapterKey] {
+		meta.QuestionCount++
+		meta.TypeCounts[getCorrectedQuestion(q).QuestionType]++
+	}
+	return meta
+}
+
+// buildCourseCatalog 根据已加载的题库构建课程与章节目录
+func buildCourseCatalog() {
+	courseCatalog = newCourseCatalog()
+	for _, course := range courseOrder {
+		meta := courseCatalog[course]
+		slog.Info("课程目录", "course", course, "title", meta.Title, "chapters", len(meta.Chapters), "questions", meta.QuestionCount)
+	}
+}
+
+// refreshCourseCatalog 重新统计课程目录，用于修正题型后更新各题型的题数。
+// 新目录构建完成后整体替换，不修改正在被读取的旧目录。
+func refreshCourseCatalog() {
+	courseCatalog = newCourseCatalog()
+}
+
+// newCourseCatalog 按已加载的题库和当前的修正生成课程与章节目录
+func newCourseCatalog() map[string]*CourseMeta {
+	catalog := make(map[string]*CourseMeta)
+	for _, course := range courseOrder {
+		meta := &CourseMeta{
+			Key:        course,
+			Title:      courseTitles[course],
+			Virtual:    isVirtualCourse(course),
+			TypeCounts: make(map[string]int),
+		}
+		for _, member := range resolveCourseMembers(course) {
+			if _, _, ok := getCourseQuestionBank(member.Course); !ok {
+				slog.Warn("课程引用了不存在的课程，已跳过", "course", course, "member", member.Course)
+				continue
+			}
+			for _, chapterKey := range memberChapterKeys(member) {
+				key, title := chapterKey, getChapterTitle(member.Course, chapterKey)
+				if meta.Virtual {
+					key = member.Course + virtualChapterSeparator + chapterKey
+					title = courseShortTitles[member.Course] + "·" + title
+				}
+				chapter := newChapterMeta(key, title, len(meta.Chapters), member.Course, chapterKey)
+				meta.QuestionCount += chapter.QuestionCount
+				for questionType, count := range chapter.TypeCounts {
+					meta.TypeCounts[questionType] += count
+				}
+				meta.Chapters = append(meta.Chapters, chapter)
+			}
+		}
+		catalog[course] = meta
+	}
+	return catalog
+}
+
+// resolveChapterChoices 将章节选择解析为课程目录中的章节列表（按目录顺序）。
+// 章节选择必须是 /api/courses 中列出的章节键，或表示全部章节的 "all"；未知的键会返回错误。
+func resolveChapterChoices(course string, chapterChoices []string) ([]ChapterMeta, error) {
+	meta, ok := courseCatalog[course]
+	if !ok {
+		return nil, newAppError(codeUnknownCourse, course)
+	}
+
+	selected := make(map[string]bool)
+	var unknown []string
+	for _, choice := range chapterChoices {
+		if choice == allChaptersChoice {
+			return meta.Chapters, nil
+		}
+		found := false
+		for _, chapter := range meta.Chapters {
+			if chapter.Key == choice {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, choice)
+			continue
+		}
+		selected[choice] = true
+	}
+	if len(unknown) > 0 {
+		return nil, newAppError(codeUnknownChapter, course, strings.Join(unknown, ", "))
+	}
+
+	var chapters []ChapterMeta
+	for _, chapter := range meta.Chapters {
+		if selected[chapter.Key] {
+			chapters = append(chapters, chapter)
+		}
+	}
+	return chapters, nil
+}
+
+// CoursesListHandler 返回所有课程、章节及题目数量
+func CoursesListHandler(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, listCourses())
+}
+
+// listCourses 按课程顺序列出所有课程、章节及题目数量
+func listCourses() CoursesResponse {
+	courses := make([]*CourseMeta, 0, len(courseOrder))
+	for _, course := range courseOrder {
+		if meta, ok := courseCatalog[course]; ok {
+			courses = append(courses, meta)
+		}
+	}
+	return CoursesResponse{Courses: courses, AllChaptersKey: allChaptersChoice}
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// cliCommand 一个命令行子命令
+type cliCommand struct {
+	usage   string // 用法，如 "stats <用户> [参数]"
+	summary string
+	run     func(args []string) error
+}
+
+// cliCommands 全部子命令，不带子命令时运行 serve
+var cliCommands = map[string]cliCommand{
+	"serve":     {"serve [参数]", "启动网页服务 (默认)", func(args []string) error { runServe(args); return nil }},
+	"tui":       {"tui [参数]", "在终端中刷题", func(args []string) error { runTUI(args); return nil }},
+	"stats":     {"stats <用户> [-json] [-tags] [-speed] [-activity]", "查看用户按课程和章节汇总的答题统计", runStatsCommand},
+	"wrongbook": {"wrongbook list|export|clear <用户> [参数]", "查看、导出或清空用户的错题本", runWrongbookCommand},
+	"users":     {"users list [-json]", "列出数据目录中的用户", runUsersCommand},
+	"bank":      {"bank validate|difficulty|export|import [参数]", "校验题库，查看全局难度，导出 (已应用修正的) 题库，或从 CSV / TSV 导入题库", runBankCommand},
+	"export":    {"export anki|worksheet [参数]", "导出题目或错题本 (Anki 笔记、可打印的 HTML 试卷)", runExportCommand},
+	"import":    {"import <试卷> [-o 章节.json]", "把文本 / Markdown 试卷解析为章节题库并生成审核报告", runImportCommand},
+}
+
+// runCLI 根据第一个参数分派子命令；第一个参数是参数 (以 "-" 开头) 或为空时运行 serve
+func runCLI(args []string) {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printCLIUsage(os.Stdout)
+		return
+	}
+	cmd, ok := cliCommands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", name)
+		printCLIUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "喵呜！%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printCLIUsage 打印子命令列表
+func printCLIUsage(w io.Writer) {
+	fmt.Fprintf(w, "用法: %s <子命令> [参数]\n\n子命令:\n", os.Args[0])
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", cliCommands[name].usage, cliCommands[name].summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n每个子命令都接受配置参数 (-config, -data-dir, -bank-dir 等)，用 \"<子命令> -h\" 查看详情。\n")
+}
+
+// newCommandFlags 创建子命令的参数集，并注册通用的配置参数
+func newCommandFlags(name string) (*flag.FlagSet, *configFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, registerConfigFlags(fs)
+}
+
+// setupCommand 解析子命令参数（参数可以写在位置参数之后），加载配置并初始化题库，返回位置参数。
+// 除非显式指定日志级别，子命令只输出警告和错误，避免加载日志干扰输出。
+func setupCommand(fs *flag.FlagSet, cf *configFlags, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	cfg, err := cf.loadConfig(fs)
+	if err != nil {
+		slog.Error("加载配置失败", "error", err)
+		os.Exit(1)
+	}
+	logLevelSet := os.Getenv(envLogLevel) != ""
+	fs.Visit(func(f *flag.Flag) { logLevelSet = logLevelSet || f.Name == "log-level" })
+	if !logLevelSet && logLevels[cfg.LogLevel] < slog.LevelWarn {
+		cfg.LogLevel = "warn"
+	}
+	applyConfig(cfg)
+	initializeApp()
+	return positional
+}
+
+// requireUserArg 检查位置参数中的用户名，并确认该用户存在
+func requireUserArg(positional []string, usage string) (string, error) {
+	if len(positional) != 1 {
+		return "", fmt.Errorf("用法: %s", usage)
+	}
+	userID := positional[0]
+	if info, err := os.Stat(getUserDataPath(userID, "")); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("用户 %s 不存在 (数据目录 %s)", userID, userDataBaseDir)
+	}
+	return userID, nil
+}
+
+// requireCourseFlag 检查课程参数，空表示全部课程
+func requireCourseFlag(course string) error {
+	if course == "" {
+		return nil
+	}
+	if _, ok := courseCatalog[course]; !ok {
+		return fmt.Errorf("未知的课程: %s (可选 %s)", course, strings.Join(courseOrder, ", "))
+	}
+	return nil
+}
+
+// printJSON 以缩进格式输出 JSON
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// formatAccuracy 格式化正确率，没有作答时显示 "-"
+func formatAccuracy(counts AnswerCounts) string {
+	if counts.CorrectCount+counts.ErrorCount == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", counts.Accuracy*100)
+}
+
+// formatTime 格式化时间，零值显示 "-"
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// runStatsCommand stats <用户>：按课程和章节汇总答题统计
+func runStatsCommand(args []string) error {
+	const usage = "stats <用户> [-json] [-tags] [-speed] [-activity]"
+	fs, cf := newCommandFlags("stats")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	withTags := fs.Bool("tags", false, "同时输出按知识点标签统计的正确率")
+	withSpeed := fs.Bool("speed", false, "同时输出按题型和章节统计的作答用时和答得慢的题")
+	withActivity := fs.Bool("activity", false, "同时输出连续学习天数、今日进度和最近四周的学习日历")
+	userID, err := requireUserArg(setupCommand(fs, cf, args), usage)
+	if err != nil {
+		return err
+	}
+
+	summary, err := computeUserStats(userID)
+	if err != nil {
+		return err
+	}
+	var tags []TagAccuracy
+	if *withTags {
+		if tags, err = computeTagAccuracy(userID, ""); err != nil {
+			return err
+		}
+	}
+	var speed *SpeedStats
+	if *withSpeed {
+		stats, err := computeSpeedStats(userID, "")
+		if err != nil {
+			return err
+		}
+		speed = &stats
+	}
+	var activity *StudyActivity
+	if *withActivity {
+		studied, err := computeStudyActivity(userID, time.Now())
+		if err != nil {
+			return err
+		}
+		activity = &studied
+	}
+	if *asJSON {
+		return printJSON(os.Stdout, struct {
+			UserStatsSummary
+			Tags     []TagAccuracy  `json:"tags,omitempty"`
+			Speed    *SpeedStats    `json:"speed,omitempty"`
+			Activity *StudyActivity `json:"activity,omitempty"`
+		}{summary, tags, speed, activity})
+	}
+
+	fmt.Printf("用户 %s: 答过 %d/%d 题，答对 %d 次，答错 %d 次，正确率 %s，错题本 %d 题，最近作答 %s\n",
+		userID, summary.AnsweredQuestions, summary.QuestionCount, summary.CorrectCount, summary.ErrorCount,
+		formatAccuracy(summary.AnswerCounts), summary.IncorrectCount, formatTime(summary.LastAnswered))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "\n课程/章节\t答过\t答对\t答错\t正确率\t错题\t标题\n")
+	for _, course := range summary.Courses {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%d\t%s\t%d\t%s\n", course.Course, course.AnsweredQuestions, course.QuestionCount,
+			course.CorrectCount, course.ErrorCount, formatAccuracy(course.AnswerCounts), course.IncorrectCount, course.Title)
+		for _, chapter := range course.Chapters {
+			if chapter.AnsweredQuestions == 0 {
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%d/%d\t%d\t%d\t%s\t\t%s\n", chapter.Key, chapter.AnsweredQuestions, chapter.QuestionCount,
+				chapter.CorrectCount, chapter.ErrorCount, formatAccuracy(chapter.AnswerCounts), chapter.Title)
+		}
+	}
+	if *withTags {
+		fmt.Fprintf(tw, "\n知识点\t答过\t答对\t答错\t正确率\n")
+		for _, tag := range tags {
+			counts := AnswerCounts{CorrectCount: tag.CorrectCount, ErrorCount: tag.ErrorCount, Accuracy: tag.Accuracy}
+			fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%d\t%s\n", tag.Tag, tag.QuestionCount, tag.TotalQuestions, tag.CorrectCount, tag.ErrorCount, formatAccuracy(counts))
+		}
+	}
+	if speed != nil {
+		fmt.Fprintf(tw, "\n题型\t计时作答\t平均用时\n")
+		fmt.Fprintf(tw, "全部\t%d\t%.1f 秒\n", speed.Overall.TimedAnswers, speed.Overall.AverageSeconds)
+		for _, byType := range speed.ByType {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f 秒\n", byType.QuestionType, byType.TimedAnswers, byType.AverageSeconds)
+		}
+		fmt.Fprintf(tw, "\n课程/章节\t计时作答\t平均用时\t标题\n")
+		for _, chapter := range speed.ByChapter {
+			fmt.Fprintf(tw, "%s/%s\t%d\t%.1f 秒\t%s\n", chapter.Course, chapter.Chapter, chapter.TimedAnswers, chapter.AverageSeconds, chapter.Title)
+		}
+		fmt.Fprintf(tw, "\n答得慢的题\t最近用时\t平均用时\t答对\t答错\t题干\n")
+		for _, slow := range speed.SlowQuestions {
+			stem := []rune(slow.QuestionText)
+			if len(stem) > 30 {
+				stem = append(stem[:30], []rune("…")...)
+			}
+			fmt.Fprintf(tw, "%s\t%.1f 秒\t%.1f 秒\t%d\t%d\t%s\n", slow.QuestionID, slow.LastSeconds, slow.AverageSeconds,
+				slow.CorrectCount, slow.ErrorCount, string(stem))
+		}
+	}
+	if activity != nil {
+		goal := "答过题即算完成"
+		if activity.Goals.QuestionsPerDay > 0 || activity.Goals.MinutesPerDay > 0 {
+			goal = fmt.Sprintf("%d 题, %d 分钟", activity.Goals.QuestionsPerDay, activity.Goals.MinutesPerDay)
+		}
+		fmt.Fprintf(tw, "\n连续学习 %d 天（最长 %d 天），今天 %d 题 %.0f 分钟，每日目标: %s（时区 %s）\n",
+			activity.CurrentStreak, activity.LongestStreak, activity.Today.Count, activity.Today.Minutes, goal, activity.Timezone)
+		fmt.Fprintf(tw, "日期\t题数\t分钟\t完成目标\n")
+		for _, day := range activity.Calendar[max(0, len(activity.Calendar)-28):] {
+			if day.Count == 0 {
+				continue
+			}
+			met := ""
+			if day.GoalMet {
+				met = "✔"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\n", day.Date, day.Count, day.Minutes, met)
+		}
+	}
+	return tw.Flush()
+}
+
+// runWrongbookCommand wrongbook list|export|clear <用户>
+func runWrongbookCommand(args []string) error {
+	const usage = "wrongbook list|export|clear <用户> [-course 课程] [-json] [-format json|csv] [-o 文件] [-yes]"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	action := args[0]
+	fs, cf := newCommandFlags("wrongbook " + action)
+	course := fs.String("course", "", "只处理该课程 (含虚拟课程)，为空表示全部课程")
+	asJSON := fs.Bool("json", false, "list: 以 JSON 输出")
+	format := fs.String("format", "json", "export: 导出格式 json 或 csv")
+	output := fs.String("o", "", "export: 输出文件，为空时输出到标准输出")
+	yes := fs.Bool("yes", false, "clear: 确认清空 (原文件会备份为 .bak)")
+	userID, err := requireUserArg(setupCommand(fs, cf, args[1:]), usage)
+	if err != nil {
+		return err
+	}
+	if err := requireCourseFlag(*course); err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		incorrect, err := loadUserIncorrectForCourses(userID, *course)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(os.Stdout, incorrect)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "课程\t章节\t题号\t答案\t当时选\t答错时间\t题干\n")
+		for _, iq := range incorrect {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber,
+				iq.CorrectAnswer, iq.UserAnswer, formatTime(iq.Timestamp), iq.QuestionText)
+		}
+		fmt.Fprintf(tw, "\n共 %d 道错题\n", len(incorrect))
+		return tw.Flush()
+
+	case "export":
+		incorrect, err := loadUserIncorrectForCourses(userID, *course)
+		if err != nil {
+			return err
+		}
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		switch *format {
+		case "json":
+			err = printJSON(w, incorrect)
+		case "csv":
+			err = writeIncorrectCSV(w, incorrect)
+		default:
+			return fmt.Errorf("不支持的导出格式: %s (可选 json, csv)", *format)
+		}
+		if err == nil && *output != "" {
+			fmt.Fprintf(os.Stderr, "已导出 %d 道错题到 %s\n", len(incorrect), *output)
+		}
+		return err
+
+	case "clear":
+		if !*yes {
+			return errors.New("清空错题本需要加上 -yes 确认")
+		}
+		cleared, err := clearUserIncorrect(userID, *course)
+		for _, fileName := range cleared {
+			fmt.Printf("已清空 %s (原文件已备份)\n", fileName)
+		}
+		if len(cleared) == 0 && err == nil {
+			fmt.Println("错题本本来就是空的")
+		}
+		return err
+
+	default:
+		return fmt.Errorf("未知的操作: %s\n用法: %s", action, usage)
+	}
+}
+
+// writeIncorrectCSV 以 CSV 导出错题，选项按 A-E 分列
+func writeIncorrectCSV(w io.Writer, incorrect []UserIncorrectQuestion) error {
+	writer := csv.NewWriter(w)
+	header := []string{"course", "chapter", "question_number", "question_type", "question_text", "A", "B", "C", "D", "E", "correct_answer", "user_answer", "timestamp"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, iq := range incorrect {
+		record := []string{iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber, iq.QuestionType, iq.QuestionText}
+		for _, letter := range []string{"A", "B", "C", "D", "E"} {
+			record = append(record, iq.Options[letter])
+		}
+		record = append(record, iq.CorrectAnswer, iq.UserAnswer, iq.Timestamp.Format(time.RFC3339))
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// runUsersCommand users list：列出数据目录中的用户
+func runUsersCommand(args []string) error {
+	const usage = "users list [-json]"
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	fs, cf := newCommandFlags("users list")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if positional := setupCommand(fs, cf, args[1:]); len(positional) > 0 {
+		return fmt.Errorf("用法: %s", usage)
+	}
+
+	users, err := listUsers()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(os.Stdout, users)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "用户\t答过\t错题\t笔记\t最近作答\n")
+	for _, user := range users {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", user.UserID, user.AnsweredQuestions, user.IncorrectCount, user.NoteCount, formatTime(user.LastAnswered))
+	}
+	fmt.Fprintf(tw, "\n共 %d 个用户 (数据目录 %s)\n", len(users), userDataBaseDir)
+	return tw.Flush()
+}
+
+// runBankCommand bank validate|export：校验或导出题库
+func runBankCommand(args []string) error {
+	const usage = "bank validate [-course 课程] [-json] | bank difficulty [-course 课程] [-sort hardest|easiest|discrimination] [-limit N] [-json] | bank export -o 目录或表格 [-course 课程] [-format json|csv|tsv] | bank import <表格> [-course 课程] [-o 目录] [-install]"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	action := args[0]
+	fs, cf := newCommandFlags("bank " + action)
+	course := fs.String("course", "", "只处理该课程 (含虚拟课程)，为空表示全部实体课程")
+	asJSON := fs.Bool("json", false, "validate / import: 以 JSON 输出发现的问题；difficulty: 以 JSON 输出")
+	output := fs.String("o", "", "export: 输出目录 (json，导出为 课程/章节.json，可直接用作 -bank-dir) 或表格文件 (csv / tsv)；import: 写入章节 JSON 的目录")
+	format := fs.String("format", "", "export: json (默认)、csv 或 tsv；import: csv 或 tsv，默认按扩展名判断")
+	install := fs.Bool("install", false, "import: 校验通过后安装到数据目录的 banks/课程 下，重启服务后生效")
+	sortBy := fs.String("sort", difficultySortHardest, "difficulty: 排序方式 hardest、easiest 或 discrimination (区分度从低到高)")
+	limit := fs.Int("limit", 20, "difficulty: 最多列出的题数，0 表示全部")
+	positional := setupCommand(fs, cf, args[1:])
+	if (action == "import") != (len(positional) == 1) || len(positional) > 1 {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	if err := requireCourseFlag(*course); err != nil {
+		return err
+	}
+
+	switch action {
+	case "validate":
+		issues, err := validateQuestionBank(*course)
+		if err != nil {
+			return err
+		}
+		if err := printBankIssues(issues, *asJSON); err != nil {
+			return err
+		}
+		if countErrorIssues(issues) > 0 {
+			return fmt.Errorf("题库校验未通过")
+		}
+		return nil
+
+	case "difficulty":
+		list, err := listQuestionDifficulties(*course, *sortBy, *limit)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(os.Stdout, list)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "题目ID\t题型\t作答人数\t难度指数\t等级\t区分度\t题干")
+		for _, entry := range list {
+			d := entry.Difficulty
+			discrimination := "-"
+			if d.Discrimination != nil {
+				discrimination = fmt.Sprintf("%.2f", *d.Discrimination)
+			}
+			stem := []rune(entry.QuestionText)
+			if len(stem) > 30 {
+				stem = append(stem[:30], []rune("…")...)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%s\t%s\t%s\n", entry.QuestionID, entry.QuestionType, d.Respondents,
+				d.DifficultyIndex, d.Level, discrimination, string(stem))
+		}
+		fmt.Fprintf(tw, "\n共 %d 题 (至少 %d 人作答才计算难度，至少 %d 人才计算区分度)\n", len(list), difficultyMinRespondents, discriminationMinRespondents)
+		return tw.Flush()
+
+	case "export":
+		if *output == "" {
+			return errors.New("请用 -o 指定输出目录")
+		}
+		if *format == "csv" || *format == "tsv" {
+			return exportQuestionBankCSV(*course, *output, *format)
+		} else if *format != "" && *format != "json" {
+			return fmt.Errorf("未知的导出格式: %s (可选 json, csv, tsv)", *format)
+		}
+		files, err := exportQuestionBank(*course, *output)
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "已导出 %d 个章节文件到 %s\n", len(files), *output)
+		return nil
+
+	case "import":
+		return importQuestionBankCSV(positional[0], *course, *format, *output, *install, *asJSON)
+
+	default:
+		return fmt.Errorf("未知的操作: %s\n用法: %s", action, usage)
+	}
+}
+
+// printBankIssues 以表格或 JSON 输出题库问题，表格输出时附带汇总
+func printBankIssues(issues []BankIssue, asJSON bool) error {
+	if asJSON {
+		return printJSON(os.Stdout, issues)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, issue := range issues {
+		fmt.Fprintf(tw, "%s\t%s\t题号 %s\t%s\n", issue.Severity, issue.QuestionID, issue.QuestionNumber, issue.Problem)
+	}
+	tw.Flush()
+	fmt.Printf("共发现 %d 个问题，其中 %d 个错误\n", len(issues), countErrorIssues(issues))
+	return nil
+}
+
+// exportQuestionBankCSV 把课程（已应用修正）的题目导出为一个表格文件，并做往返校验
+func exportQuestionBankCSV(course, output, format string) error {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return err
+	}
+	comma, err := csvDelimiter(format, output, nil)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if _, err := writeQuestionsCSV(file, questions, comma); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已导出 %d 道题到 %s\n", len(questions), output)
+
+	issues, err := csvRoundTripIssues(questions, comma)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		printBankIssues(issues, false)
+		return fmt.Errorf("往返校验未通过，以上题目从表格导回时会与题库不一致")
+	}
+	return nil
+}
+
+// importQuestionBankCSV 校验表格中的题目，通过后写入章节 JSON 目录或安装为外部题库；两者都未指定时只做校验
+func importQuestionBankCSV(source, course, format, output string, install, asJSON bool) error {
+	if isVirtualCourse(course) {
+		return fmt.Errorf("导入时只能指定实体课程: %s", course)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	comma, err := csvDelimiter(format, source, data)
+	if err != nil {
+		return err
+	}
+	questions, issues, err := checkCSVBank(data, comma, course)
+	if err != nil {
+		return err
+	}
+	if err := printBankIssues(issues, asJSON); err != nil {
+		return err
+	}
+	if countErrorIssues(issues) > 0 {
+		return fmt.Errorf("表格校验未通过，未写入任何文件")
+	}
+
+	if output != "" {
+		files, err := writeChapterFiles(output, questions)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "已把 %d 道题写入 %d 个章节文件到 %s\n", len(questions), len(files), output)
+	}
+	if install {
+		byCourse := make(map[string][]Question)
+		for _, q := range questions {
+			byCourse[q.OriginalCourse] = append(byCourse[q.OriginalCourse], q)
+		}
+		for _, key := range physicalCourses() {
+			if len(byCourse[key]) == 0 {
+				continue
+			}
+			dir, err := installQuestionBank(key, byCourse[key])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "已安装课程 %s 的题库 (%d 道题) 到 %s，重启服务后生效\n", key, len(byCourse[key]), dir)
+		}
+	}
+	return nil
+}
+
+// runExportCommand 导出选定章节的题目或用户的错题本
+func runExportCommand(args []string) error {
+	const usage = "export anki|worksheet [-course 课程] [-chapters 章节,...] [-user 用户] [-o 文件] " +
+		"[-types 题型,...] [-count 题数] [-order sequential|random] [-seed 编号] [-title 标题] [-answer-key append|none|only]"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	action := args[0]
+	fs, cf := newCommandFlags("export " + action)
+	course := fs.String("course", "", "课程 (含虚拟课程)；导出错题本时为空表示全部课程")
+	chapters := fs.String("chapters", "", "章节键，逗号分隔，默认全部章节，见 /api/courses")
+	userID := fs.String("user", "", "导出该用户的错题本，而不是课程题目")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	types := fs.String("types", "", "worksheet: 只出这些题型，逗号分隔，如 单选题,多选题")
+	count := fs.Int("count", 0, "worksheet: 题数，0 表示全部")
+	order := fs.String("order", "sequential", "worksheet: 题目顺序 sequential 或 random")
+	seed := fs.Int64("seed", 0, "worksheet: 试卷编号（随机种子），相同编号生成同一份试卷，0 表示生成新的编号")
+	title := fs.String("title", "", "worksheet: 试卷标题")
+	answerKey := fs.String("answer-key", worksheetKeyAppend, "worksheet: 参考答案 append (另起一页附在后面)、none 或 only")
+	if positional := setupCommand(fs, cf, args[1:]); len(positional) > 0 {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	if err := requireCourseFlag(*course); err != nil {
+		return err
+	}
+	if *userID != "" {
+		if _, err := requireUserArg([]string{*userID}, usage); err != nil {
+			return err
+		}
+	}
+
+	sel := ExportSelection{Course: *course, UserID: *userID}
+	if *chapters != "" {
+		sel.ChapterChoice = strings.Split(*chapters, ",")
+	}
+
+	var write func(io.Writer) error
+	var total int
+	switch action {
+	case "anki":
+		questions, err := selectExportQuestions(sel)
+		if err != nil {
+			return err
+		}
+		total = len(questions)
+		write = func(w io.Writer) error { return writeAnkiNotes(w, questions) }
+	case "worksheet":
+		req := WorksheetExportRequest{ExportSelection: sel, Count: *count, OrderChoice: *order, Seed: *seed, Title: *title, AnswerKey: *answerKey}
+		if *types != "" {
+			req.QuestionTypes = strings.Split(*types, ",")
+		}
+		ws, err := buildWorksheet(req)
+		if err != nil {
+			return err
+		}
+		total = ws.Total
+		fmt.Fprintf(os.Stderr, "试卷编号 %d\n", ws.Seed)
+		write = func(w io.Writer) error { return writeWorksheet(w, ws) }
+	default:
+		return fmt.Errorf("未知的导出格式: %s\n用法: %s", action, usage)
+	}
+	if *output == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已导出 %d 道题到 %s\n", total, *output)
+	return nil
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，Windows 等没有系统时区库的环境也能使用 timezone 配置
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 服务运行配置。优先级：内置默认值 < 配置文件 < 环境变量 < 命令行参数
+type Config struct {
+	Host            string            `json:"host" yaml:"host" toml:"host"`
+	Port            int               `json:"port" yaml:"port" toml:"port"`
+	DataDir         string            `json:"data_dir" yaml:"data_dir" toml:"data_dir"`    // 数据目录，其下为 user_data 和 bank_overlays
+	BankDirs        map[string]string `json:"bank_dirs" yaml:"bank_dirs" toml:"bank_dirs"` // 课程 -> 外部题库目录（目录下为 0.json, 1.json ...），未配置的课程使用内置题库
+	NoBrowser       bool              `json:"no_browser" yaml:"no_browser" toml:"no_browser"`
+	LogLevel        string            `json:"log_level" yaml:"log_level" toml:"log_level"`                      // debug, info, warn, error
+	LogFormat       string            `json:"log_format" yaml:"log_format" toml:"log_format"`                   // text 或 json
+	PrivacyMode     bool              `json:"privacy_mode" yaml:"privacy_mode" toml:"privacy_mode"`             // 日志中不写出用户ID，改为本次运行内一致的化名
+	Timezone        string            `json:"timezone" yaml:"timezone" toml:"timezone"`                         // 划分学习日（连续学习天数、学习日历）使用的时区
+	ShutdownTimeout int               `json:"shutdown_timeout" yaml:"shutdown_timeout" toml:"shutdown_timeout"` // 收到退出信号后等待处理中的请求和写入完成的最长秒数
+	Features        FeatureToggles    `json:"features" yaml:"features" toml:"features"`
+
+	ConfigFile string         `json:"-" yaml:"-" toml:"-"` // 实际加载的配置文件，仅用于展示
+	location   *time.Location // Timezone 对应的时区，由 validateConfig 加载
+}
+
+// FeatureToggles 可以单独关闭的功能，关闭后不注册对应的接口
+type FeatureToggles struct {
+	Admin       bool `json:"admin" yaml:"admin" toml:"admin"`                   // 管理接口 (/api/admin/*)
+	Notes       bool `json:"notes" yaml:"notes" toml:"notes"`                   // 个人笔记与收藏
+	Reports     bool `json:"reports" yaml:"reports" toml:"reports"`             // 题目纠错报告
+	Tags        bool `json:"tags" yaml:"tags" toml:"tags"`                      // 知识点标签
+	Leaderboard bool `json:"leaderboard" yaml:"leaderboard" toml:"leaderboard"` // 排行榜（用户仍需自行选择参加）
+	Assignments bool `json:"assignments" yaml:"assignments" toml:"assignments"` // 班级作业
+	Battles     bool `json:"battles" yaml:"battles" toml:"battles"`             // 实时对战
+}
+
+// 配置相关的环境变量
+const (
+	envConfigFile      = "QUIZ_CONFIG"
+	envHost            = "QUIZ_HOST"
+	envPort            = "QUIZ_PORT"
+	envDataDir         = "QUIZ_DATA_DIR"
+	envBankDirs        = "QUIZ_BANK_DIRS" // 形如 "maogai=/path/a,xigai_li=/path/b"
+	envNoBrowser       = "QUIZ_NO_BROWSER"
+	envLogLevel        = "QUIZ_LOG_LEVEL"
+	envLogFormat       = "QUIZ_LOG_FORMAT"
+	envPrivacyMode     = "QUIZ_PRIVACY_MODE"
+	envTimezone        = "QUIZ_TIMEZONE"
+	envShutdownTimeout = "QUIZ_SHUTDOWN_TIMEOUT"
+	envDisableFeatures = "QUIZ_DISABLE_FEATURES" // 形如 "notes,tags"
+)
+
+var appConfig = defaultConfig() // 当前生效的配置，在 main 中加载
+
+// defaultConfig 返回内置的默认配置
+func defaultConfig() Config {
+	return Config{
+		Host:            "0.0.0.0",
+		Port:            8899,
+		DataDir:         defaultDataDir(),
+		BankDirs:        map[string]string{},
+		LogLevel:        "info",
+		LogFormat:       "text",
+		Timezone:        "Asia/Shanghai",
+		ShutdownTimeout: 10,
+		Features:        FeatureToggles{Admin: true, Notes: true, Reports: true, Tags: true, Leaderboard: true, Assignments: true, Battles: true},
+	}
+}
+
+// defaultDataDir 默认把数据放在可执行文件所在目录，这样数据位置不随启动时的工作目录变化。
+// 通过 go run 运行时可执行文件位于临时目录，此时回退为当前工作目录。
+func defaultDataDir() string {
+	exe, err := os.Executable()
+	if err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		dir := filepath.Dir(exe)
+		if !strings.HasPrefix(dir, filepath.Clean(os.TempDir())) {
+			return dir
+		}
+	}
+	return "."
+}
+
+// configFlags 配置相关的命令行参数，服务和各子命令共用
+type configFlags struct {
+	configFile      *string
+	host            *string
+	port            *int
+	dataDir         *string
+	bankDirs        stringMapFlag
+	noBrowser       *bool
+	logLevel        *string
+	logFormat       *string
+	privacyMode     *bool
+	timezone        *string
+	shutdownTimeout *int
+	disableFeatures *string
+}
+
+// registerConfigFlags 在参数集上注册配置参数
+func registerConfigFlags(fs *flag.FlagSet) *configFlags {
+	defaults := defaultConfig()
+	cf := &configFlags{}
+	cf.configFile = fs.String("config", "", "配置文件路径 (.json/.yaml/.yml/.toml)，也可用环境变量 "+envConfigFile)
+	cf.host = fs.String("host", defaults.Host, "监听地址")
+	cf.port = fs.Int("port", defaults.Port, "监听端口")
+	cf.dataDir = fs.String("data-dir", defaults.DataDir, "数据目录，其下为 user_data 和 bank_overlays")
+	fs.Var(&cf.bankDirs, "bank-dir", "外部题库目录，格式为 课程=目录，可重复，如 -bank-dir maogai=./my_bank")
+	cf.noBrowser = fs.Bool("no-browser", defaults.NoBrowser, "启动后不自动打开浏览器")
+	cf.logLevel = fs.String("log-level", defaults.LogLevel, "日志级别: debug, info, warn, error")
+	cf.logFormat = fs.String("log-format", defaults.LogFormat, "日志格式: text, json")
+	cf.privacyMode = fs.Bool("privacy-mode", defaults.PrivacyMode, "日志中不写出用户ID，改为化名")
+	cf.timezone = fs.String("timezone", defaults.Timezone, "划分学习日使用的时区，如 Asia/Shanghai、UTC")
+	cf.shutdownTimeout = fs.Int("shutdown-timeout", defaults.ShutdownTimeout, "收到退出信号后等待处理中的请求和写入完成的最长秒数")
+	cf.disableFeatures = fs.String("disable-features", "", "关闭的功能，逗号分隔: admin, notes, reports, tags, leaderboard, assignments, battles")
+	return cf
+}
+
+// loadConfig 在参数解析之后，按 默认值 < 配置文件 < 环境变量 < 命令行参数 的顺序加载配置
+func (cf *configFlags) loadConfig(fs *flag.FlagSet) (Config, error) {
+	cfg := defaultConfig()
+
+	// 1. 配置文件
+	path := *cf.configFile
+	if path == "" {
+		path = os.Getenv(envConfigFile)
+	}
+	if path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+		cfg.ConfigFile = path
+	}
+
+	// 2. 环境变量
+	if err := applyConfigEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	// 3. 显式设置的命令行参数
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Host = *cf.host
+		case "port":
+			cfg.Port = *cf.port
+		case "data-dir":
+			cfg.DataDir = *cf.dataDir
+		case "bank-dir":
+			for course, dir := range cf.bankDirs {
+				cfg.BankDirs[course] = dir
+			}
+		case "no-browser":
+			cfg.NoBrowser = *cf.noBrowser
+		case "log-level":
+			cfg.LogLevel = *cf.logLevel
+		case "log-format":
+			cfg.LogFormat = *cf.logFormat
+		case "privacy-mode":
+			cfg.PrivacyMode = *cf.privacyMode
+		case "timezone":
+			cfg.Timezone = *cf.timezone
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = *cf.shutdownTimeout
+		case "disable-features":
+			if err := disableConfigFeatures(&cfg.Features, *cf.disableFeatures); err != nil {
+				flagErr = err
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, validateConfig(&cfg)
+}
+
+// loadConfigFile 按扩展名解析配置文件，文件中未出现的字段保持原值
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s (支持 .json, .yaml, .yml, .toml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	if cfg.BankDirs == nil {
+		cfg.BankDirs = map[string]string{}
+	}
+	return nil
+}
+
+// applyConfigEnv 用环境变量覆盖配置
+func applyConfigEnv(cfg *Config) error {
+	if v := os.Getenv(envHost); v != "" {
+		cfg.Host = v
+	}
+	if v := os.Getenv(envPort); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的端口: %s", envPort, v)
+		}
+		cfg.Port = port
+	}
+	if v := os.Getenv(envDataDir); v != "" {
+		cfg.DataDir = v
+	}
+	if v := os.Getenv(envBankDirs); v != "" {
+		var dirs stringMapFlag
+		if err := dirs.Set(v); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %w", envBankDirs, err)
+		}
+		for course, dir := range dirs {
+			cfg.BankDirs[course] = dir
+		}
+	}
+	if v := os.Getenv(envNoBrowser); v != "" {
+		noBrowser, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的布尔值: %s", envNoBrowser, v)
+		}
+		cfg.NoBrowser = noBrowser
+	}
+	if v := os.Getenv(envLogLevel); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := os.Getenv(envLogFormat); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv(envPrivacyMode); v != "" {
+		privacyMode, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的布尔值: %s", envPrivacyMode, v)
+		}
+		cfg.PrivacyMode = privacyMode
+	}
+	if v := os.Getenv(envTimezone); v != "" {
+		cfg.Timezone = v
+	}
+	if v := os.Getenv(envShutdownTimeout); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的秒数: %s", envShutdownTimeout, v)
+		}
+		cfg.ShutdownTimeout = seconds
+	}
+	if v := os.Getenv(envDisableFeatures); v != "" {
+		if err := disableConfigFeatures(&cfg.Features, v); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %w", envDisableFeatures, err)
+		}
+	}
+	return nil
+}
+
+// disableConfigFeatures 按逗号分隔的功能名关闭功能
+func disableConfigFeatures(features *FeatureToggles, list string) error {
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "":
+		case "admin":
+			features.Admin = false
+		case "notes":
+			features.Notes = false
+		case "reports":
+			features.Reports = false
+		case "tags":
+			features.Tags = false
+		case "leaderboard":
+			features.Leaderboard = false
+		case "assignments":
+			features.Assignments = false
+		case "battles":
+			features.Battles = false
+		default:
+			return fmt.Errorf("未知的功能: %s", name)
+		}
+	}
+	return nil
+}
+
+// validateConfig 校验配置并把目录转换为绝对路径
+func validateConfig(cfg *Config) error {
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return fmt.Errorf("无效的端口: %d", cfg.Port)
+	}
+	if _, ok := logLevels[strings.ToLower(cfg.LogLevel)]; !ok {
+		return fmt.Errorf("无效的日志级别: %s (可选 debug, info, warn, error)", cfg.LogLevel)
+	}
+	cfg.LogLevel = strings.ToLower(cfg.LogLevel)
+	cfg.LogFormat = strings.ToLower(cfg.LogFormat)
+	if cfg.LogFormat != logFormatText && cfg.LogFormat != logFormatJSON {
+		return fmt.Errorf("无效的日志格式: %s (可选 text, json)", cfg.LogFormat)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return fmt.Errorf("无效的退出等待时间: %d 秒", cfg.ShutdownTimeout)
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("无效的时区: %s", cfg.Timezone)
+	}
+	cfg.location = location
+	absDataDir, err := filepath.Abs(cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("无效的数据目录 %s: %w", cfg.DataDir, err)
+	}
+	cfg.DataDir = absDataDir
+	for course, dir := range cfg.BankDirs {
+		if _, _, ok := getCourseQuestionBank(course); !ok {
+			return fmt.Errorf("外部题库配置了未知的课程: %s", course)
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("无效的题库目录 %s: %w", dir, err)
+		}
+		cfg.BankDirs[course] = absDir
+	}
+	return nil
+}
+
+// applyConfig 让配置生效：设置数据目录和日志级别
+func applyConfig(cfg Config) {
+	appConfig = cfg
+	userDataBaseDir = filepath.Join(cfg.DataDir, userDataDirName)
+	bankOverlayDir = filepath.Join(cfg.DataDir, bankOverlayDirName)
+	uploadedBankDir = filepath.Join(cfg.DataDir, uploadedBanksDirName)
+	if cfg.location != nil {
+		studyLocation = cfg.location
+	}
+	setupLogging(cfg)
+
+	// 提示旧版本在工作目录下留下的数据
+	if cwd, err := os.Getwd(); err == nil && filepath.Clean(cwd) != cfg.DataDir {
+		if info, err := os.Stat(filepath.Join(cwd, userDataDirName)); err == nil && info.IsDir() {
+			slog.Warn("当前目录下存在旧版本的用户数据，但数据目录不同。如需继续使用旧数据，请加上参数 -data-dir", "found", userDataDirName, "data_dir", cfg.DataDir, "cwd", cwd)
+		}
+	}
+}
+
+// listenAddress 返回服务监听地址
+func (cfg Config) listenAddress() string {
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+}
+
+// browserURL 返回在本机浏览器中打开的地址
+func (cfg Config) browserURL() string {
+	host := cfg.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("http://%s:%d", host, cfg.Port)
+}
+
+// shutdownTimeout 返回退出时最长的等待时间
+func (cfg Config) shutdownTimeout() time.Duration {
+	return time.Duration(cfg.ShutdownTimeout) * time.Second
+}
+
+// logEffectiveConfig 在启动时打印生效的配置
+func logEffectiveConfig(cfg Config) {
+	configFile := cfg.ConfigFile
+	if configFile == "" {
+		configFile = "(未使用)"
+	}
+	slog.Info("生效配置", "config_file", configFile, "listen", cfg.listenAddress(), "log_level", cfg.LogLevel, "log_format", cfg.LogFormat,
+		"privacy_mode", cfg.PrivacyMode, "open_browser", !cfg.NoBrowser, "timezone", cfg.Timezone, "shutdown_timeout", cfg.shutdownTimeout())
+	slog.Info("生效配置: 数据目录", "data_dir", cfg.DataDir, "user_data", userDataBaseDir, "overlay", bankOverlayDir)
+	courses := make([]string, 0, len(cfg.BankDirs))
+	for course := range cfg.BankDirs {
+		courses = append(courses, course)
+	}
+	sort.Strings(courses)
+	for _, course := range courses {
+		slog.Info("生效配置: 外部题库", "course", course, "dir", cfg.BankDirs[course])
+	}
+	if entries, err := os.ReadDir(uploadedBankDir); err == nil {
+		for _, entry := range entries {
+			course := entry.Name()
+			if _, configured := cfg.BankDirs[course]; entry.IsDir() && !configured {
+				if _, _, ok := getCourseQuestionBank(course); ok {
+					slog.Info("生效配置: 已安装的外部题库", "course", course, "dir", filepath.Join(uploadedBankDir, course))
+				}
+			}
+		}
+	}
+	slog.Info("生效配置: 功能", "admin", cfg.Features.Admin, "notes", cfg.Features.Notes, "reports", cfg.Features.Reports, "tags", cfg.Features.Tags,
+		"leaderboard", cfg.Features.Leaderboard, "assignments", cfg.Features.Assignments, "battles", cfg.Features.Battles)
+	if os.Getenv(adminTokenEnv) != "" {
+		slog.Info("生效配置: 管理令牌已设置", "env", adminTokenEnv)
+	}
+	if os.Getenv(teacherTokenEnv) != "" {
+		slog.Info("生效配置: 老师令牌已设置", "env", teacherTokenEnv)
+	}
+}
+
+// stringMapFlag 解析 "键=值" 形式的参数，可重复或用逗号分隔
+type stringMapFlag map[string]string
+
+func (m *stringMapFlag) String() string {
+	if m == nil || *m == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*m))
+	for k, v := range *m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m *stringMapFlag) Set(value string) error {
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" || strings.TrimSpace(val) == "" {
+			return fmt.Errorf("应为 键=值 的形式: %s", pair)
+		}
+		(*m)[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return nil
+}
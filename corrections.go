@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var (
+	questionCorrections map[string]QuestionCorrection // 题目ID -> 管理员修正（来自旁路文件）
+	correctionsMu       sync.RWMutex                  // 保护 questionCorrections
+)
+
+// loadQuestionCorrections 从旁路文件加载题目修正
+func loadQuestionCorrections() {
+	corrections := make(map[string]QuestionCorrection)
+	if err := loadOverlayJSON(correctionsFile, &corrections); err != nil {
+		slog.Error("加载题目修正文件失败，将使用原始题库", "error", err)
+		corrections = make(map[string]QuestionCorrection)
+	}
+	correctionsMu.Lock()
+	questionCorrections = corrections
+	correctionsMu.Unlock()
+	slog.Info("加载题目修正", "count", len(corrections))
+}
+
+// getCorrectedQuestion 返回应用了管理员修正后的题目；没有修正时原样返回。
+// 嵌入的题库本身不会被修改，所有读取题目内容的地方都应经过这里。
+func getCorrectedQuestion(q Question) Question {
+	correctionsMu.RLock()
+	correction, ok := questionCorrections[questionIDOf(q)]
+	correctionsMu.RUnlock()
+	if !ok {
+		return q
+	}
+	if correction.QuestionType != "" {
+		q.QuestionType = correction.QuestionType
+	}
+	if correction.QuestionText != "" {
+		q.QuestionText = correction.QuestionText
+	}
+	if len(correction.Options) > 0 {
+		q.Options = correction.Options
+	}
+	if correction.CorrectAnswer != "" {
+		q.CorrectAnswer = correction.CorrectAnswer
+	}
+	return q
+}
+
+// isQuestionCorrected 判断题目是否存在修正
+func isQuestionCorrected(questionID string) bool {
+	correctionsMu.RLock()
+	defer correctionsMu.RUnlock()
+	_, ok := questionCorrections[questionID]
+	return ok
+}
+
+// lookupQuestion 按题目ID查找题目（已应用修正）
+func lookupQuestion(questionID string) (Question, bool) {
+	q, ok := questionMapByID[questionID]
+	if !ok {
+		return Question{}, false
+	}
+	return getCorrectedQuestion(q), true
+}
+
+// normalizeAnswer 规范化答案字母：转为大写、去重并按字母顺序排列，如 "ca" -> "AC"
+func normalizeAnswer(answer string) string {
+	seen := make(map[rune]bool)
+	var letters []string
+	for _, r := range strings.ToUpper(answer) {
+		if r >= 'A' && r <= 'Z' && !seen[r] {
+			seen[r] = true
+			letters = append(letters, string(r))
+		}
+	}
+	sort.Strings(letters)
+	return strings.Join(letters, "")
+}
+
+// answerMatchesOptions 判断答案中的每个字母都是题目的选项
+func answerMatchesOptions(answer string, options map[string]string) bool {
+	if answer == "" {
+		return false
+	}
+	for _, r := range answer {
+		if _, ok := options[string(r)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyCorrectionHandler 处理管理员对题目的修正，修正以旁路文件保存并立即生效
+func ApplyCorrectionHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req ApplyCorrectionRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	questionID, ok := normalizeQuestionID(req.QuestionID)
+	if !ok {
+		writeError(c, errUnknownQuestion.with(req.QuestionID))
+		return
+	}
+
+	correction := QuestionCorrection{
+		QuestionType:  strings.TrimSpace(req.QuestionType),
+		QuestionText:  strings.TrimSpace(req.QuestionText),
+		Options:       req.Options,
+		CorrectAnswer: normalizeAnswer(req.CorrectAnswer),
+		Comment:       strings.TrimSpace(req.Comment),
+		UpdatedAt:     time.Now(),
+	}
+	if correction.QuestionType == "" && correction.QuestionText == "" && len(correction.Options) == 0 && correction.CorrectAnswer == "" {
+		writeError(c, newAppError(codeEmptyCorrection))
+		return
+	}
+
+	// 修正后的答案必须与（修正后的）选项对应
+	original := questionMapByID[questionID]
+	options := original.Options
+	if len(correction.Options) > 0 {
+		options = correction.Options
+	}
+	answer := original.CorrectAnswer
+	if correction.CorrectAnswer != "" {
+		answer = correction.CorrectAnswer
+	}
+	if !answerMatchesOptions(answer, options) {
+		writeError(c, newAppError(codeAnswerMismatch, answer))
+		return
+	}
+
+	correctionsMu.Lock()
+	updated := make(map[string]QuestionCorrection, len(questionCorrections)+1)
+	for id, entry := range questionCorrections {
+		updated[id] = entry
+	}
+	updated[questionID] = correction
+	if err := saveOverlayJSON(correctionsFile, updated); err != nil {
+		correctionsMu.Unlock()
+		slog.ErrorContext(ctx, "保存题目修正文件失败", "question_id", questionID, "error", err)
+		writeError(c, &userDataError{Message: "保存题目修正失败", Err: err})
+		return
+	}
+	questionCorrections = updated
+	correctionsMu.Unlock()
+	refreshCourseCatalog() // 修正可能改变题型，重新统计各题型的题数
+	slog.InfoContext(ctx, "已修正题目", "question_id", questionID, "comment", correction.Comment)
+
+	resolved := 0
+	if req.ResolveReports {
+		var err error
+		if resolved, err = resolveQuestionReports(questionID, reportStatusResolved); err != nil {
+			slog.WarnContext(ctx, "修正题目后标记报告为已解决失败", "question_id", questionID, "error", err)
+		}
+	}
+
+	corrected, _ := lookupQuestion(questionID)
+	c.JSON(consts.StatusOK, utils.H{
+		"message":          "修正已保存",
+		"question_id":      questionID,
+		"question":         corrected,
+		"resolved_reports": resolved,
+	})
+}
+
+// DeleteCorrectionHandler 撤销管理员对题目的修正，恢复为嵌入题库中的原始内容
+func DeleteCorrectionHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req DeleteCorrectionRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	questionID := strings.TrimPrefix(req.QuestionID, "quiz_")
+
+	correctionsMu.Lock()
+	if _, ok := questionCorrections[questionID]; !ok {
+		correctionsMu.Unlock()
+		c.Status(consts.StatusNoContent)
+		return
+	}
+	updated := make(map[string]QuestionCorrection, len(questionCorrections))
+	for id, entry := range questionCorrections {
+		if id != questionID {
+			updated[id] = entry
+		}
+	}
+	if err := saveOverlayJSON(correctionsFile, updated); err != nil {
+		correctionsMu.Unlock()
+		slog.ErrorContext(ctx, "保存题目修正文件失败", "question_id", questionID, "error", err)
+		writeError(c, &userDataError{Message: "保存题目修正失败", Err: err})
+		return
+	}
+	questionCorrections = updated
+	correctionsMu.Unlock()
+	refreshCourseCatalog() // 恢复原始题型，重新统计各题型的题数
+	slog.InfoContext(ctx, "已撤销题目修正", "question_id", questionID)
+	c.Status(consts.StatusNoContent)
+}
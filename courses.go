@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// virtualCourses 定义由多个实体课程（或其部分章节）组合而成的虚拟课程。
+// 虚拟课程本身没有题库文件和错题本，题目、统计和错题都归属到各自的来源课程。
+var virtualCourses = map[string][]VirtualCourseMember{
+	// 习概合并：李老师题库 + 杨老师题库
+	"xigai_all": {
+		{Course: "xigai_li"},
+		{Course: "xigai_yang"},
+	},
+}
+
+// getCourseQuestionBank 返回实体课程的章节题库和最大章节索引
+func getCourseQuestionBank(course string) (map[string][]Question, int, bool) {
+	switch course {
+	case "maogai":
+		return maogaiQuestionsByChapter, maogaiMaxChapterIndex, true
+	case "xigai_li":
+		return xigaiLiQuestionsByChapter, xigaiLiMaxChapterIndex, true
+	case "xigai_yang":
+		return xigaiYangQuestionsByChapter, xigaiYangMaxChapterIndex, true
+	default:
+		return nil, 0, false
+	}
+}
+
+// isVirtualCourse 判断课程键是否为虚拟课程
+func isVirtualCourse(course string) bool {
+	_, ok := virtualCourses[course]
+	return ok
+}
+
+// resolveCourseMembers 将课程键展开为实体课程列表。
+// 实体课程返回只包含自身的列表，虚拟课程返回其全部组成部分。
+func resolveCourseMembers(course string) []VirtualCourseMember {
+	if members, ok := virtualCourses[course]; ok {
+		return members
+	}
+	return []VirtualCourseMember{{Course: course}}
+}
+
+// memberChapterKeys 返回虚拟课程组成部分包含的章节键（按数字顺序）
+func memberChapterKeys(member VirtualCourseMember) []string {
+	if len(member.Chapters) > 0 {
+		return member.Chapters
+	}
+	_, maxChapterIdx, ok := getCourseQuestionBank(member.Course)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, maxChapterIdx+1)
+	for i := 0; i <= maxChapterIdx; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	return keys
+}
+
+// memberIncludesChapter 判断虚拟课程组成部分是否包含指定章节
+func memberIncludesChapter(member VirtualCourseMember, chapterKey string) bool {
+	for _, key := range memberChapterKeys(member) {
+		if key == chapterKey {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuizQuestionID 从 QuizQuestionID 中解析出原始题目的课程、章节号和原始索引。
+// QuizQuestionID 格式为 "quiz_<course>_<chapter>_<index>"，其中 course 可能包含下划线（例如 xigai_li）
+func parseQuizQuestionID(quizQuestionID string) (course, chapterKey, index string, err error) {
+	raw := strings.TrimPrefix(quizQuestionID, "quiz_")
+	parts := strings.Split(raw, "_")
+	if len(parts) < 3 { // 至少需要课程、章节和索引三部分
+		return "", "", "", newAppError(codeInvalidQuestionID, quizQuestionID)
+	}
+	// course 可能有多个下划线段，章节是倒数第二段，索引是最后一段
+	course = strings.Join(parts[:len(parts)-2], "_")
+	chapterKey = parts[len(parts)-2]
+	index = parts[len(parts)-1]
+	return course, chapterKey, index, nil
+}
+
+// parseIncorrectQuestionID 从错题回顾的题目ID中解析出来源课程、章节号和原始题号。
+// 格式为 "incorrect_<course>_<chapter>_<question_number>_<列表索引>"，其中 course 可能包含下划线
+func parseIncorrectQuestionID(quizQuestionID string) (course, chapterKey, questionNumber string, err error) {
+	raw := strings.TrimPrefix(quizQuestionID, "incorrect_")
+	parts := strings.Split(raw, "_")
+	if len(parts) < 4 { // 至少需要课程、章节、题号和列表索引四部分
+		return "", "", "", newAppError(codeInvalidQuestionID, quizQuestionID)
+	}
+	course = strings.Join(parts[:len(parts)-3], "_")
+	chapterKey = parts[len(parts)-3]
+	questionNumber = parts[len(parts)-2]
+	return course, chapterKey, questionNumber, nil
+}
+
+// findQuestionByNumber 按课程、章节和原始题号查找题目（已应用修正）
+func findQuestionByNumber(course, chapterKey, questionNumber string) (Question, bool) {
+	questionsByChapter, _, ok := getCourseQuestionBank(course)
+	if !ok {
+		return Question{}, false
+	}
+	for _, q := range questionsByChapter[chapterKey] {
+		if q.QuestionNumber == questionNumber {
+			return getCorrectedQuestion(q), true
+		}
+	}
+	return Question{}, false
+}
+
+// getQuestionStatKey 返回题目在用户统计文件中的键，格式为 "课程_章节_题号"
+func getQuestionStatKey(course, chapterKey, questionNumber string) string {
+	return fmt.Sprintf("%s_%s_%s", course, chapterKey, questionNumber)
+}
+
+// legacyQuestionStatKey 返回旧版本统计文件中的键，格式为 "章节_题号"，不区分课程
+func legacyQuestionStatKey(chapterKey, questionNumber string) string {
+	return fmt.Sprintf("%s_%s", chapterKey, questionNumber)
+}
+
+// userStatFor 返回用户对一道题的统计。没有按课程记录时回退到仍保留旧键、且能确定属于该课程的统计。
+func userStatFor(userStats map[string]UserQuestionStat, course, chapterKey, questionNumber string) (UserQuestionStat, bool) {
+	if stat, ok := userStats[getQuestionStatKey(course, chapterKey, questionNumber)]; ok {
+		return stat, true
+	}
+	return legacyStatFor(userStats, course, chapterKey, questionNumber)
+}
+
+// legacyStatFor 返回旧键 "章节_题号" 下属于指定实体课程的统计。多门课程都有该章节和题号、无法确定归属时
+// 不计入任何课程，以免同一条统计在几门课程中重复计算。
+func legacyStatFor(userStats map[string]UserQuestionStat, course, chapterKey, questionNumber string) (UserQuestionStat, bool) {
+	stat, ok := userStats[legacyQuestionStatKey(chapterKey, questionNumber)]
+	if !ok {
+		return UserQuestionStat{}, false
+	}
+	if stat.OriginalChapterKey == "" {
+		stat.OriginalChapterKey = chapterKey
+	}
+	if stat.OriginalQuestionNumber == "" {
+		stat.OriginalQuestionNumber = questionNumber
+	}
+	if legacyStatCourse(stat, nil) != course {
+		return UserQuestionStat{}, false
+	}
+	return stat, true
+}
+
+// isLegacyStatKey 判断统计键是否为旧版本不带课程的键
+func isLegacyStatKey(key string) bool {
+	for _, course := range physicalCourses() {
+		if strings.HasPrefix(key, course+"_") {
+			return false
+		}
+	}
+	return true
+}
+
+// legacyStatCourse 判断旧统计条目所属的实体课程：只有一门课程有该章节和题号时即为该课程；
+// 多门课程都有时，若只有其中一门课程的错题本记录过这道题，则归属该课程。无法确定时返回空。
+func legacyStatCourse(stat UserQuestionStat, incorrectByCourse map[string][]UserIncorrectQuestion) string {
+	if stat.OriginalCourse != "" {
+		return stat.OriginalCourse
+	}
+	var candidates []string
+	for _, course := range physicalCourses() {
+		if _, ok := findQuestionByNumber(course, stat.OriginalChapterKey, stat.OriginalQuestionNumber); ok {
+			candidates = append(candidates, course)
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	var inIncorrect []string
+	for _, course := range candidates {
+		for _, iq := range incorrectByCourse[course] {
+			if iq.OriginalChapter == stat.OriginalChapterKey && iq.QuestionNumber == stat.OriginalQuestionNumber {
+				inIncorrect = append(inIncorrect, course)
+				break
+			}
+		}
+	}
+	if len(inIncorrect) == 1 {
+		return inIncorrect[0]
+	}
+	return ""
+}
+
+// mergeUserStats 合并同一道题的两条统计
+func mergeUserStats(a, b UserQuestionStat) UserQuestionStat {
+	merged := a
+	merged.CorrectCount += b.CorrectCount
+	merged.ErrorCount += b.ErrorCount
+	merged.TimedCount += b.TimedCount
+	merged.TotalTimeMs += b.TotalTimeMs
+	if b.LastAnswered.After(a.LastAnswered) {
+		merged.LastAnswered = b.LastAnswered
+		if b.LastTimeMs > 0 {
+			merged.LastTimeMs = b.LastTimeMs
+		}
+	}
+	return merged
+}
+
+// migrateLegacyStatKeys 把统计中旧版本的 "章节_题号" 键改写为 "课程_章节_题号"，返回改写的条目数。
+// 无法确定课程的条目保留旧键，不计入任何课程的统计。
+func migrateLegacyStatKeys(userID string, userStats map[string]UserQuestionStat) (int, error) {
+	var incorrectByCourse map[string][]UserIncorrectQuestion
+	migrated := 0
+	for key, stat := range userStats {
+		if !isLegacyStatKey(key) || stat.OriginalChapterKey == "" || stat.OriginalQuestionNumber == "" {
+			continue
+		}
+		if incorrectByCourse == nil {
+			incorrectByCourse = make(map[string][]UserIncorrectQuestion)
+			for _, course := range physicalCourses() {
+				incorrect := []UserIncorrectQuestion{}
+				if err := loadUserJSONData(userID, getIncorrectQuestionsFileName(course), &incorrect); err != nil {
+					return 0, err
+				}
+				incorrectByCourse[course] = incorrect
+			}
+		}
+		course := legacyStatCourse(stat, incorrectByCourse)
+		if course == "" {
+			continue
+		}
+		stat.OriginalCourse = course
+		newKey := getQuestionStatKey(course, stat.OriginalChapterKey, stat.OriginalQuestionNumber)
+		if existing, ok := userStats[newKey]; ok {
+			stat = mergeUserStats(existing, stat)
+		}
+		userStats[newKey] = stat
+		delete(userStats, key)
+		migrated++
+	}
+	return migrated, nil
+}
+
+// migrateAllLegacyUserStats 启动时为所有用户改写旧版本的统计键（需在加载题库和修正后调用），只在有改写时保存
+func migrateAllLegacyUserStats() {
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("读取用户数据目录失败，跳过统计键迁移", "error", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		userStats := make(map[string]UserQuestionStat)
+		if err := loadUserJSONData(userID, questionStatsFile, &userStats); err != nil {
+			slog.Warn("迁移统计键时读取统计数据失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		migrated, err := migrateLegacyStatKeys(userID, userStats)
+		if err != nil {
+			slog.Warn("迁移统计键时读取错题本失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		if migrated == 0 {
+			continue
+		}
+		if err := saveUserJSONData(userID, questionStatsFile, userStats); err != nil {
+			slog.Error("保存迁移后的统计数据失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		slog.Info("已迁移旧版本的统计键", logKeyUserID, userID, "entries", migrated)
+	}
+}
+
+// loadUserIncorrectForCourse 加载用户在指定课程下的错题。
+// 虚拟课程会合并各组成部分的错题本，并只保留组成部分包含的章节；每道错题都会标记来源课程。
+func loadUserIncorrectForCourse(userID, course string) ([]UserIncorrectQuestion, error) {
+	result := []UserIncorrectQuestion{}
+	virtual := isVirtualCourse(course)
+	for _, member := range resolveCourseMembers(course) {
+		memberIncorrect := []UserIncorrectQuestion{}
+		if err := loadUserJSONData(userID, getIncorrectQuestionsFileName(member.Course), &memberIncorrect); err != nil {
+			return nil, err
+		}
+		for _, iq := range memberIncorrect {
+			if virtual && !memberIncludesChapter(member, iq.OriginalChapter) {
+				continue
+			}
+			if iq.OriginalCourse == "" {
+				iq.OriginalCourse = member.Course // 旧数据没有记录来源课程，按所在错题本补全
+			}
+			result = append(result, iq)
+		}
+	}
+	return result, nil
+}
+
+// questionsInScope 返回课程（含虚拟课程）的全部题目（已应用修正）；course 为空时返回所有实体课程的题目
+func questionsInScope(course string) ([]Question, error) {
+	courses := []string{course}
+	if course == "" {
+		courses = nil
+		for _, key := range courseOrder {
+			if !isVirtualCourse(key) {
+				courses = append(courses, key)
+			}
+		}
+	}
+	var questions []Question
+	for _, key := range courses {
+		selected, err := _getQuestionsForProcessing(key, []string{allChaptersChoice}, "sequential", nil)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, selected...)
+	}
+	return questions, nil
+}
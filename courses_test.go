@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+// legacyKeyCourses 按旧统计键 "章节_题号" 列出有这道题的实体课程
+func legacyKeyCourses(t *testing.T) map[string][]string {
+	t.Helper()
+	courses := make(map[string][]string)
+	for _, course := range physicalCourses() {
+		questions, err := questionsInScope(course)
+		if err != nil {
+			t.Fatalf("读取 %s 题库失败: %v", course, err)
+		}
+		seen := make(map[string]bool)
+		for _, q := range questions {
+			key := legacyQuestionStatKey(q.OriginalChapterKey, q.QuestionNumber)
+			if !seen[key] {
+				seen[key] = true
+				courses[key] = append(courses[key], course)
+			}
+		}
+	}
+	return courses
+}
+
+func TestUserStatForLegacyKey(t *testing.T) {
+	setupTestApp(t)
+	courses := legacyKeyCourses(t)
+	var shared, unique string
+	for key, owners := range courses {
+		if len(owners) > 1 && shared == "" {
+			shared = key
+		}
+		if len(owners) == 1 && unique == "" {
+			unique = key
+		}
+	}
+	if shared == "" || unique == "" {
+		t.Skip("题库中没有所需的章节和题号组合")
+	}
+
+	for _, key := range []string{shared, unique} {
+		var chapterKey, questionNumber string
+		for _, course := range courses[key] {
+			questions, _ := questionsInScope(course)
+			for _, q := range questions {
+				if legacyQuestionStatKey(q.OriginalChapterKey, q.QuestionNumber) == key {
+					chapterKey, questionNumber = q.OriginalChapterKey, q.QuestionNumber
+				}
+			}
+		}
+		userStats := map[string]UserQuestionStat{
+			key: {CorrectCount: 1, OriginalChapterKey: chapterKey, OriginalQuestionNumber: questionNumber},
+		}
+		counted := 0
+		for _, course := range physicalCourses() {
+			if _, ok := userStatFor(userStats, course, chapterKey, questionNumber); ok {
+				counted++
+				if len(courses[key]) == 1 && course != courses[key][0] {
+					t.Errorf("旧统计 %s 计入了 %s，应只属于 %s", key, course, courses[key][0])
+				}
+			}
+		}
+		want := 0
+		if len(courses[key]) == 1 {
+			want = 1
+		}
+		if counted != want {
+			t.Errorf("旧统计 %s（课程 %v）计入了 %d 门课程，应为 %d", key, courses[key], counted, want)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- CSV / TSV 题库 ---
+// 方便同学们用表格软件维护题库：每行一道题，列为课程、章节、题号、题型、题干、选项 A~E 和答案。
+// 行的先后顺序就是题目在章节文件中的顺序（题目ID中的索引），导入导出时保持不变。
+
+// csvBankHeader 导出时使用的表头
+var csvBankHeader = []string{"course", "chapter", "number", "type", "text", "A", "B", "C", "D", "E", "answer"}
+
+// csvOptionLetters 表格中可以容纳的选项
+var csvOptionLetters = []string{"A", "B", "C", "D", "E"}
+
+// csvHeaderAliases 导入时可识别的表头（不区分大小写），便于直接使用中文表头
+var csvHeaderAliases = map[string]string{
+	"course": "course", "课程": "course",
+	"chapter": "chapter", "章节": "chapter",
+	"number": "number", "题号": "number",
+	"type": "type", "题型": "type",
+	"text": "text", "题干": "text",
+	"a": "A", "选项a": "A",
+	"b": "B", "选项b": "B",
+	"c": "C", "选项c": "C",
+	"d": "D", "选项d": "D",
+	"e": "E", "选项e": "E",
+	"answer": "answer", "答案": "answer",
+}
+
+// utf8BOM 写在文件开头，Excel 才能正确识别中文
+const utf8BOM = "\ufeff"
+
+// csvDelimiter 根据格式参数、文件扩展名或内容判断分隔符。format 为 csv、tsv，或空 / auto 表示自动判断。
+func csvDelimiter(format, name string, data []byte) (rune, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return ',', nil
+	case "tsv":
+		return '\t', nil
+	case "", "auto":
+	default:
+		return 0, newOptionError("format", format, "csv", "tsv")
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return ',', nil
+	case ".tsv", ".tab":
+		return '\t', nil
+	}
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Contains(firstLine, []byte("\t")) {
+		return '\t', nil
+	}
+	return ',', nil
+}
+
+// writeQuestionsCSV 把题目写成表格。超出 A~E 的选项无法写入，返回这些题目的问题列表。
+func writeQuestionsCSV(w io.Writer, questions []Question, comma rune) ([]BankIssue, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(csvBankHeader); err != nil {
+		return nil, err
+	}
+
+	issues := []BankIssue{}
+	for _, q := range questions {
+		record := []string{q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber, q.QuestionType, q.QuestionText}
+		for _, letter := range csvOptionLetters {
+			record = append(record, q.Options[letter])
+		}
+		record = append(record, q.CorrectAnswer)
+		for letter := range q.Options {
+			if !slices.Contains(csvOptionLetters, letter) {
+				issues = append(issues, csvBankIssue(q, 0, "选项 %s 超出表格支持的 A~E，导出时丢失", letter))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return issues, err
+		}
+	}
+	cw.Flush()
+	return issues, cw.Error()
+}
+
+// readQuestionsCSV 解析表格中的题目。defaultCourse 非空时，课程列可以省略或留空，
+// 但不能填写其他课程。逐行的问题（未知课程、章节越界、缺少题号等）以 error 级别的问题返回，
+// 表头缺失或表格格式错误时返回 error。
+func readQuestionsCSV(r io.Reader, comma rune, defaultCourse string) ([]Question, []BankIssue, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("表格是空的")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, utf8BOM)))
+		if key, ok := csvHeaderAliases[name]; ok {
+			columns[key] = i
+		}
+	}
+	required := []string{"chapter", "number", "type", "text", "A", "B", "answer"}
+	if defaultCourse == "" {
+		required = append([]string{"course"}, required...)
+	}
+	for _, key := range required {
+		if _, ok := columns[key]; !ok {
+			return nil, nil, fmt.Errorf("表头缺少 %s 列 (需要 %s)", key, strings.Join(csvBankHeader, ", "))
+		}
+	}
+
+	questions := []Question{}
+	issues := []BankIssue{}
+	chapterCounts := make(map[string]int) // 课程/章节 -> 已读取的题数，用作题目在章节中的索引
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("解析表格失败: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		cell := func(key string) string {
+			if i, ok := columns[key]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue // 跳过空行
+		}
+
+		q := Question{
+			QuestionNumber: strings.TrimSpace(cell("number")),
+			QuestionType:   strings.TrimSpace(cell("type")),
+			QuestionText:   cell("text"),
+			Options:        make(map[string]string),
+			CorrectAnswer:  strings.TrimSpace(cell("answer")),
+			OriginalCourse: strings.TrimSpace(cell("course")),
+		}
+		for _, letter := range csvOptionLetters {
+			if text := cell(letter); text != "" {
+				q.Options[letter] = text
+			}
+		}
+		if q.OriginalCourse == "" {
+			q.OriginalCourse = defaultCourse
+		}
+
+		chapter := strings.TrimSpace(cell("chapter"))
+		if index, err := strconv.Atoi(chapter); err == nil {
+			chapter = strconv.Itoa(index) // "01" 与 "1" 视为同一章节
+		}
+		q.OriginalChapterKey = chapter
+
+		_, maxChapterIdx, known := getCourseQuestionBank(q.OriginalCourse)
+		switch {
+		case q.OriginalCourse == "":
+			issues = append(issues, csvBankIssue(q, line, "缺少课程"))
+			continue
+		case defaultCourse != "" && q.OriginalCourse != defaultCourse:
+			issues = append(issues, csvBankIssue(q, line, "课程 %s 与导入的课程 %s 不一致", q.OriginalCourse, defaultCourse))
+			continue
+		case !known:
+			issues = append(issues, csvBankIssue(q, line, "未知的课程: %s", q.OriginalCourse))
+			continue
+		}
+		if index, err := strconv.Atoi(chapter); err != nil || index < 0 || index > maxChapterIdx {
+			issues = append(issues, csvBankIssue(q, line, "章节 %q 无效，%s 的章节为 0~%d", chapter, q.OriginalCourse, maxChapterIdx))
+			continue
+		}
+		if q.QuestionNumber == "" {
+			issues = append(issues, csvBankIssue(q, line, "缺少题号"))
+		}
+
+		chapterKey := q.OriginalCourse + "/" + chapter
+		q.OriginalIndex = chapterCounts[chapterKey]
+		chapterCounts[chapterKey]++
+		questions = append(questions, q)
+	}
+	return questions, issues, nil
+}
+
+// csvBankIssue 生成 error 级别的问题：line 为表格中的行号，为 0 时表示题库中的题目
+func csvBankIssue(q Question, line int, format string, args ...interface{}) BankIssue {
+	problem := fmt.Sprintf(format, args...)
+	if line > 0 {
+		problem = fmt.Sprintf("第 %d 行: %s", line, problem)
+	}
+	issue := BankIssue{
+		Course:         q.OriginalCourse,
+		Chapter:        q.OriginalChapterKey,
+		QuestionNumber: q.QuestionNumber,
+		Severity:       "error",
+		Problem:        problem,
+	}
+	if line == 0 {
+		issue.QuestionID = questionIDOf(q) // 表格中的行可能课程或章节无效，只给已在题库中的题目标注ID
+	}
+	return issue
+}
+
+// csvRoundTripIssues 把题目写成表格再读回来，逐题比较课程、章节、顺序、题号、题型、题干、选项和答案，
+// 返回无法无损转换的题目。拆解和出处不在表格中，不参与比较；表格单元格内的 "\r\n" 读回时为 "\n"，不算作差异。
+func csvRoundTripIssues(questions []Question, comma rune) ([]BankIssue, error) {
+	var buf bytes.Buffer
+	issues, err := writeQuestionsCSV(&buf, questions, comma)
+	if err != nil {
+		return nil, err
+	}
+	decoded, readIssues, err := readQuestionsCSV(&buf, comma, "")
+	if err != nil {
+		return nil, fmt.Errorf("读回导出的表格失败: %w", err)
+	}
+	issues = append(issues, readIssues...)
+	if len(decoded) != len(questions) {
+		return append(issues, BankIssue{Severity: "error", Problem: fmt.Sprintf("导出 %d 道题，读回 %d 道", len(questions), len(decoded))}), nil
+	}
+
+	for i, q := range questions {
+		got := decoded[i]
+		var diffs []string
+		if got.OriginalCourse != q.OriginalCourse || got.OriginalChapterKey != q.OriginalChapterKey || got.OriginalIndex != q.OriginalIndex {
+			diffs = append(diffs, "位置")
+		}
+		if got.QuestionNumber != q.QuestionNumber {
+			diffs = append(diffs, "题号")
+		}
+		if got.QuestionType != q.QuestionType {
+			diffs = append(diffs, "题型")
+		}
+		if got.QuestionText != normalizeLineEndings(q.QuestionText) {
+			diffs = append(diffs, "题干")
+		}
+		wantOptions := make(map[string]string, len(q.Options))
+		for letter, text := range q.Options {
+			wantOptions[letter] = normalizeLineEndings(text)
+		}
+		if !reflect.DeepEqual(got.Options, wantOptions) {
+			diffs = append(diffs, "选项")
+		}
+		if got.CorrectAnswer != q.CorrectAnswer {
+			diffs = append(diffs, "答案")
+		}
+		if len(diffs) > 0 {
+			issues = append(issues, csvBankIssue(q, 0, "往返转换后%s不一致", strings.Join(diffs, "、")))
+		}
+	}
+	return issues, nil
+}
+
+// normalizeLineEndings 把 "\r\n" 换为 "\n"，与 encoding/csv 读取带引号单元格时的处理一致
+func normalizeLineEndings(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// checkCSVBank 解析并校验表格：行级问题、题目校验（validateQuestions）以及课程中没有题目的章节。
+// 没有 error 级别的问题时才可以安装。
+func checkCSVBank(data []byte, comma rune, course string) ([]Question, []BankIssue, error) {
+	questions, issues, err := readQuestionsCSV(bytes.NewReader(data), comma, course)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(questions) == 0 && len(issues) == 0 {
+		return nil, nil, errors.New("表格中没有题目")
+	}
+	issues = append(issues, validateQuestions(questions)...)
+
+	chapterSeen := make(map[string]bool)
+	for _, q := range questions {
+		chapterSeen[q.OriginalCourse+"/"+q.OriginalChapterKey] = true
+	}
+	courseSeen := make(map[string]bool)
+	for _, q := range questions {
+		if courseSeen[q.OriginalCourse] {
+			continue
+		}
+		courseSeen[q.OriginalCourse] = true
+		_, maxChapterIdx, _ := getCourseQuestionBank(q.OriginalCourse)
+		for i := 0; i <= maxChapterIdx; i++ {
+			if !chapterSeen[q.OriginalCourse+"/"+strconv.Itoa(i)] {
+				issues = append(issues, BankIssue{Course: q.OriginalCourse, Chapter: strconv.Itoa(i), Severity: "warning",
+					Problem: fmt.Sprintf("第 %d 章没有题目，安装后该章节为空", i)})
+			}
+		}
+	}
+	return questions, issues, nil
+}
+
+// countErrorIssues 统计 error 级别的问题数
+func countErrorIssues(issues []BankIssue) int {
+	count := 0
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			count++
+		}
+	}
+	return count
+}
+
+// installedBankDir 返回通过上传安装的课程题库目录（数据目录下的 banks/课程），不存在时返回 false
+func installedBankDir(course string) (string, bool) {
+	dir := filepath.Join(uploadedBankDir, course)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+// installQuestionBank 把一门课程的题目写入数据目录下的 banks/课程，作为该课程的外部题库。
+// 先写入临时目录再整体替换，原有的题库目录重命名为带时间戳的 .bak 目录。重启服务后生效。
+func installQuestionBank(course string, questions []Question) (string, error) {
+	if err := os.MkdirAll(uploadedBankDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	staging, err := os.MkdirTemp(uploadedBankDir, "."+course+".staging-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+	if _, err := writeChapterFiles(staging, questions); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(uploadedBankDir, course)
+	if _, err := os.Stat(target); err == nil {
+		backupPath := target + time.Now().Format(".2006_01_02_15_04_05.bak")
+		if err := os.Rename(target, backupPath); err != nil {
+			return "", err
+		}
+		slog.Info("原有的外部题库已备份", "course", course, "backup", backupPath)
+	}
+	if err := os.Rename(filepath.Join(staging, course), target); err != nil {
+		return "", err
+	}
+	slog.Info("已安装外部题库，重启服务后生效", "course", course, "questions", len(questions), "dir", target)
+	return target, nil
+}
+
+// BankUploadHandler 校验上传的 CSV / TSV 题库，通过后安装为课程的外部题库
+// (?course=课程&format=csv|tsv&dry_run=true)，表格可以是 multipart 的 file 字段或直接作为请求体
+func BankUploadHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	course := c.Query("course")
+	if _, _, ok := getCourseQuestionBank(course); !ok {
+		writeError(c, newOptionError("course", course, physicalCourses()...))
+		return
+	}
+
+	data, fileName := c.Request.Body(), ""
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			writeError(c, invalidRequest(fmt.Errorf("读取上传文件失败: %w", err)))
+			return
+		}
+		data, err = io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeError(c, invalidRequest(fmt.Errorf("读取上传文件失败: %w", err)))
+			return
+		}
+		fileName = fileHeader.Filename
+	}
+	comma, err := csvDelimiter(c.Query("format"), fileName, data)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	questions, issues, err := checkCSVBank(data, comma, course)
+	if err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	result := utils.H{"course": course, "total_questions": len(questions), "total_issues": len(issues), "issues": issues, "installed": false}
+	if errorCount := countErrorIssues(issues); errorCount > 0 {
+		result["error"] = fmt.Sprintf("题库校验未通过，发现 %d 个错误", errorCount)
+		c.JSON(consts.StatusUnprocessableEntity, result)
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		c.JSON(consts.StatusOK, result)
+		return
+	}
+
+	dir, err := installQuestionBank(course, questions)
+	if err != nil {
+		slog.ErrorContext(ctx, "安装外部题库失败", "course", course, "error", err)
+		writeError(c, &userDataError{Message: "安装题库失败", Err: err})
+		return
+	}
+	result["installed"] = true
+	result["bank_dir"] = dir
+	result["message"] = "题库已安装，重启服务后生效"
+	c.JSON(consts.StatusOK, result)
+}
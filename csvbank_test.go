@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setupTestApp 使用临时数据目录加载内置题库
+func setupTestApp(t *testing.T) {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.LogLevel = "error"
+	if err := validateConfig(&cfg); err != nil {
+		t.Fatalf("配置无效: %v", err)
+	}
+	applyConfig(cfg)
+	initializeApp()
+}
+
+// csvRoundTrip 把题目写成表格再读回来，返回读回的题目和导出时报告的问题
+func csvRoundTrip(t *testing.T, questions []Question, comma rune) ([]Question, []BankIssue) {
+	t.Helper()
+	var buf bytes.Buffer
+	writeIssues, err := writeQuestionsCSV(&buf, questions, comma)
+	if err != nil {
+		t.Fatalf("导出表格失败: %v", err)
+	}
+	decoded, issues, err := readQuestionsCSV(&buf, comma, "")
+	if err != nil {
+		t.Fatalf("读回表格失败: %v", err)
+	}
+	if len(issues) > 0 {
+		t.Fatalf("读回表格有问题: %+v", issues)
+	}
+	if len(decoded) != len(questions) {
+		t.Fatalf("导出 %d 道题，读回 %d 道", len(questions), len(decoded))
+	}
+	return decoded, writeIssues
+}
+
+// assertSameQuestion 比较表格中包含的字段
+func assertSameQuestion(t *testing.T, want, got Question) {
+	t.Helper()
+	if questionIDOf(got) != questionIDOf(want) {
+		t.Errorf("题目ID: 读回 %s, 原为 %s", questionIDOf(got), questionIDOf(want))
+	}
+	want.QuestionText = normalizeLineEndings(want.QuestionText)
+	if got.QuestionNumber != want.QuestionNumber || got.QuestionType != want.QuestionType ||
+		got.QuestionText != want.QuestionText || got.CorrectAnswer != want.CorrectAnswer {
+		t.Errorf("题目 %s 读回后不一致:\n读回 %q %q %q %q\n原为 %q %q %q %q", questionIDOf(want),
+			got.QuestionNumber, got.QuestionType, got.QuestionText, got.CorrectAnswer,
+			want.QuestionNumber, want.QuestionType, want.QuestionText, want.CorrectAnswer)
+	}
+	wantOptions := make(map[string]string, len(want.Options))
+	for letter, text := range want.Options {
+		wantOptions[letter] = normalizeLineEndings(text)
+	}
+	if !reflect.DeepEqual(got.Options, wantOptions) {
+		t.Errorf("题目 %s 的选项读回后不一致: 读回 %v, 原为 %v", questionIDOf(want), got.Options, wantOptions)
+	}
+}
+
+func TestCSVRoundTripBuiltinBanks(t *testing.T) {
+	setupTestApp(t)
+	for _, course := range physicalCourses() {
+		questions, err := questionsInScope(course)
+		if err != nil {
+			t.Fatalf("读取 %s 题库失败: %v", course, err)
+		}
+		if len(questions) == 0 {
+			t.Fatalf("%s 题库没有题目", course)
+		}
+		for _, format := range []struct {
+			name  string
+			comma rune
+		}{{"csv", ','}, {"tsv", '\t'}} {
+			t.Run(course+"/"+format.name, func(t *testing.T) {
+				// 题库中个别题目有 A~E 以外的选项（识别错误），导出时会报告，只比较其余题目
+				unsupported := make(map[string]bool)
+				decoded, writeIssues := csvRoundTrip(t, questions, format.comma)
+				for _, issue := range writeIssues {
+					if !strings.Contains(issue.Problem, "超出表格支持的 A~E") {
+						t.Errorf("导出时报告了意外的问题: %+v", issue)
+					}
+					unsupported[issue.QuestionID] = true
+				}
+				for i := range questions {
+					if !unsupported[questionIDOf(questions[i])] {
+						assertSameQuestion(t, questions[i], decoded[i])
+					}
+				}
+				issues, err := csvRoundTripIssues(questions, format.comma)
+				if err != nil {
+					t.Fatalf("往返检查失败: %v", err)
+				}
+				for _, issue := range issues {
+					if !unsupported[issue.QuestionID] {
+						t.Errorf("往返检查报告了问题: %+v", issue)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCSVRoundTripSpecialContent(t *testing.T) {
+	setupTestApp(t)
+	questions := []Question{
+		{
+			QuestionNumber: "1", QuestionType: "单选题",
+			QuestionText:  "题干中有逗号, 引号 \"实事求是\" 和 'single'",
+			Options:       map[string]string{"A": "甲, 乙", "B": "\"丙\"", "C": "丁", "D": "戊"},
+			CorrectAnswer: "B",
+		},
+		{
+			QuestionNumber: "2", QuestionType: "多选题",
+			QuestionText:  "题干换行\n第二行\r\n第三行\t带制表符",
+			Options:       map[string]string{"A": "一\n二", "B": "乙", "C": "丙", "D": "丁", "E": "第五个选项"},
+			CorrectAnswer: "ACE",
+		},
+		{
+			QuestionNumber: "3", QuestionType: "判断题",
+			QuestionText:  "  前后有空格的题干  ",
+			Options:       map[string]string{"A": "正确", "B": "错误"},
+			CorrectAnswer: "A",
+		},
+	}
+	for i := range questions {
+		questions[i].OriginalCourse = "maogai"
+		questions[i].OriginalChapterKey = "1"
+		questions[i].OriginalIndex = i
+	}
+
+	// 标签保存在旁路文件中，按题目ID关联，读回后题目ID不变才能保留标签
+	tagsMu.Lock()
+	oldTags := questionTags
+	t.Cleanup(func() {
+		tagsMu.Lock()
+		questionTags = oldTags
+		tagsMu.Unlock()
+	})
+	questionTags = map[string][]string{
+		questionIDOf(questions[0]): {"实事求是"},
+		questionIDOf(questions[1]): {"多选", "新民主主义革命"},
+	}
+	tagsMu.Unlock()
+
+	for _, comma := range []rune{',', '\t'} {
+		decoded, writeIssues := csvRoundTrip(t, questions, comma)
+		if len(writeIssues) > 0 {
+			t.Fatalf("导出表格有问题: %+v", writeIssues)
+		}
+		for i := range questions {
+			assertSameQuestion(t, questions[i], decoded[i])
+			if got, want := getQuestionTags(questionIDOf(decoded[i])), getQuestionTags(questionIDOf(questions[i])); !reflect.DeepEqual(got, want) {
+				t.Errorf("题目 %s 的标签: 读回 %v, 原为 %v", questionIDOf(questions[i]), got, want)
+			}
+		}
+		issues, err := csvRoundTripIssues(questions, comma)
+		if err != nil {
+			t.Fatalf("往返检查失败: %v", err)
+		}
+		if len(issues) > 0 {
+			t.Errorf("往返检查报告了问题: %+v", issues)
+		}
+	}
+}
+
+func TestCSVExportReportsUnsupportedOption(t *testing.T) {
+	setupTestApp(t)
+	q := Question{
+		QuestionNumber: "1", QuestionType: "单选题", QuestionText: "六个选项",
+		Options:        map[string]string{"A": "1", "B": "2", "C": "3", "D": "4", "E": "5", "F": "6"},
+		CorrectAnswer:  "F",
+		OriginalCourse: "maogai", OriginalChapterKey: "1",
+	}
+	issues, err := csvRoundTripIssues([]Question{q}, ',')
+	if err != nil {
+		t.Fatalf("往返检查失败: %v", err)
+	}
+	if len(issues) == 0 {
+		t.Fatal("选项 F 无法写入表格，应报告问题")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// errorCode 接口返回的稳定错误码，客户端应按错误码而不是提示文字判断错误类型
+type errorCode string
+
+const (
+	codeInvalidRequest     errorCode = "invalid_request"      // 请求无法解析或参数不合法
+	codeMissingField       errorCode = "missing_field"        // 缺少必填字段
+	codeInvalidOption      errorCode = "invalid_option"       // 参数不是可选值之一
+	codeUnknownCourse      errorCode = "unknown_course"       // 课程不存在
+	codeUnknownChapter     errorCode = "unknown_chapter"      // 课程中没有所选章节
+	codeInvalidQuestionID  errorCode = "invalid_question_id"  // 题目ID格式不对
+	codeAmbiguousQuestion  errorCode = "ambiguous_question"   // 虚拟课程的几门成员课程中都有该题，需要指定实体课程
+	codeEmptySelection     errorCode = "empty_selection"      // 所选范围没有题目
+	codeOutOfRange         errorCode = "out_of_range"         // 数值超出允许的范围
+	codeNegativeValue      errorCode = "negative_value"       // 数值不能为负数
+	codeInvalidDue         errorCode = "invalid_due"          // 截止时间格式不对
+	codeMissingScope       errorCode = "missing_scope"        // 导出时没有指定课程或用户
+	codeEmptyCorrection    errorCode = "empty_correction"     // 题目修正没有修改任何字段
+	codeNotInAssignment    errorCode = "not_in_assignment"    // 题目不属于该作业
+	codeWrongMode          errorCode = "wrong_mode"           // 会话不处于所需的模式
+	codeForbidden          errorCode = "forbidden"            // 没有管理或老师权限
+	codeQuestionNotFound   errorCode = "question_not_found"   // 题库中没有该题
+	codeAssignmentNotFound errorCode = "assignment_not_found" // 作业不存在
+	codeBattleNotFound     errorCode = "battle_not_found"     // 对战房间不存在或已清理
+	codeRouteNotFound      errorCode = "route_not_found"      // 接口不存在
+	codeNotBattleHost      errorCode = "not_battle_host"      // 只有房主可以开始对战
+	codeNotBattlePlayer    errorCode = "not_battle_player"    // 没有加入该房间
+	codeBattleStarted      errorCode = "battle_started"       // 对战已经开始
+	codeBattleFull         errorCode = "battle_full"          // 房间人数已满
+	codeBattleNotPlaying   errorCode = "battle_not_playing"   // 对战未在进行中
+	codeQuestionClosed     errorCode = "question_closed"      // 本题已结束，不再接受作答
+	codeAlreadyAnswered    errorCode = "already_answered"     // 本题已经作答
+	codeAnswerMismatch     errorCode = "answer_mismatch"      // 答案与题目选项不匹配
+	codeTooLong            errorCode = "too_long"             // 文字超过长度限制
+	codeStorage            errorCode = "storage_error"        // 读写数据失败
+	codeInternal           errorCode = "internal_error"       // 其他未预料到的服务器错误
+)
+
+// errorDef 错误码对应的状态码和中文提示模板（按 fmt 格式填入参数，其他语言见 messageBundles）
+type errorDef struct {
+	Status  int
+	Message string
+}
+
+// errorCatalog 全部错误码。新增错误码时同时在 messageBundles 中补上英文提示。
+var errorCatalog = map[errorCode]errorDef{
+	codeInvalidRequest:     {consts.StatusBadRequest, "无效请求: %s"},
+	codeMissingField:       {consts.StatusBadRequest, "缺少必填字段 %s"},
+	codeInvalidOption:      {consts.StatusBadRequest, "参数 %s 的值 %q 无效 (可选 %s)"},
+	codeUnknownCourse:      {consts.StatusBadRequest, "未知的课程: %s"},
+	codeUnknownChapter:     {consts.StatusBadRequest, "课程 %s 中不存在章节: %s"},
+	codeInvalidQuestionID:  {consts.StatusBadRequest, "无法从题目ID %s 解析原始题目信息"},
+	codeAmbiguousQuestion:  {consts.StatusBadRequest, "课程 %s 的章节 %s 题号 %s 在多门课程中都有错题，请指定来源课程 (%s)"},
+	codeEmptySelection:     {consts.StatusBadRequest, "所选范围没有题目"},
+	codeOutOfRange:         {consts.StatusBadRequest, "%s应在 %d 到 %d 之间"},
+	codeNegativeValue:      {consts.StatusBadRequest, "%s不能为负数: %d"},
+	codeInvalidDue:         {consts.StatusBadRequest, "无效的截止时间 %q (格式如 2025-06-06 或 2025-06-06T18:00:00+08:00)"},
+	codeMissingScope:       {consts.StatusBadRequest, "需要指定课程或用户"},
+	codeEmptyCorrection:    {consts.StatusBadRequest, "至少需要修正一个字段 (question_type, question_text, options, correct_answer)"},
+	codeNotInAssignment:    {consts.StatusBadRequest, "题目 %s 不属于作业 %s"},
+	codeWrongMode:          {consts.StatusBadRequest, "当前不处于%s模式 (或会话模式不匹配)"},
+	codeForbidden:          {consts.StatusForbidden, "%s"},
+	codeQuestionNotFound:   {consts.StatusNotFound, "找不到题目: %s"},
+	codeAssignmentNotFound: {consts.StatusNotFound, "作业不存在: %s"},
+	codeBattleNotFound:     {consts.StatusNotFound, "对战房间不存在: %s"},
+	codeRouteNotFound:      {consts.StatusNotFound, "接口不存在: %s"},
+	codeNotBattleHost:      {consts.StatusForbidden, "只有房主可以开始对战"},
+	codeNotBattlePlayer:    {consts.StatusForbidden, "你不在这个房间中"},
+	codeBattleStarted:      {consts.StatusConflict, "对战已经开始"},
+	codeBattleFull:         {consts.StatusConflict, "房间已满 (最多 %d 人)"},
+	codeBattleNotPlaying:   {consts.StatusConflict, "对战未在进行中"},
+	codeQuestionClosed:     {consts.StatusConflict, "第 %d 题已结束"},
+	codeAlreadyAnswered:    {consts.StatusConflict, "本题已经作答"},
+	codeAnswerMismatch:     {consts.StatusBadRequest, "答案 %s 与题目选项不匹配"},
+	codeTooLong:            {consts.StatusBadRequest, "%s不能超过 %d 个字"},
+	codeStorage:            {consts.StatusInternalServerError, "%s"},
+	codeInternal:           {consts.StatusInternalServerError, "服务器内部错误"},
+}
+
+// 支持的语言，默认中文
+const (
+	langZH = "zh-CN"
+	langEN = "en"
+)
+
+// messageBundles 各语言的提示，以中文原文为键；找不到时使用中文。
+// 错误码的提示模板、读写数据失败和权限检查的提示都在这里翻译。
+var messageBundles = map[string]map[string]string{
+	langEN: {
+		"无效请求: %s":               "Invalid request: %s",
+		"服务器内部错误":                "Internal server error",
+		"缺少必填字段 %s":              "Missing required field %s",
+		"参数 %s 的值 %q 无效 (可选 %s)": "Invalid value %[2]q for %[1]s (allowed: %[3]s)",
+		"未知的课程: %s":              "Unknown course: %s",
+		"课程 %s 中不存在章节: %s":       "Course %s has no chapter: %s",
+		"无法从题目ID %s 解析原始题目信息":    "Cannot parse question ID %s",
+		"课程 %s 的章节 %s 题号 %s 在多门课程中都有错题，请指定来源课程 (%s)": "Chapter %[2]s question %[3]s of course %[1]s is in more than one wrong-question book; specify the source course (%[4]s)",
+		"所选范围没有题目":        "No questions in the selected range",
+		"%s应在 %d 到 %d 之间": "%s must be between %d and %d",
+		"%s不能为负数: %d":     "%s must not be negative: %d",
+		"无效的截止时间 %q (格式如 2025-06-06 或 2025-06-06T18:00:00+08:00)": "Invalid due time %q (use 2025-06-06 or 2025-06-06T18:00:00+08:00)",
+		"题目 %s 不属于作业 %s":       "Question %s is not part of assignment %s",
+		"当前不处于%s模式 (或会话模式不匹配)": "The session is not in %s mode",
+		"找不到题目: %s":            "Question not found: %s",
+		"作业不存在: %s":            "Assignment not found: %s",
+		"对战房间不存在: %s":          "Battle room not found: %s",
+		"接口不存在: %s":            "No such endpoint: %s",
+		"只有房主可以开始对战":           "Only the host can start the battle",
+		"你不在这个房间中":             "You have not joined this room",
+		"对战已经开始":               "The battle has already started",
+		"房间已满 (最多 %d 人)":       "The room is full (at most %d players)",
+		"第 %d 题已结束":            "Question %d is already closed",
+		"对战未在进行中":              "The battle is not in progress",
+		"本题已经作答":               "You have already answered this question",
+		"答案 %s 与题目选项不匹配":       "Answer %s does not match the options",
+		"%s不能超过 %d 个字":         "%s must be at most %d characters",
+		"理由":                   "The reason",
+		"答题":                   "quiz",
+		"题数":                   "The question count",
+		"每题限时（秒）":              "The time limit per question (seconds)",
+		"每日题数目标":               "The daily question goal",
+		"每日分钟数目标":              "The daily minutes goal",
+		"速刷":                   "review",
+		"至少需要修正一个字段 (question_type, question_text, options, correct_answer)": "At least one field must be corrected (question_type, question_text, options, correct_answer)",
+		"需要指定课程或用户": "A course or user is required",
+		"管理令牌无效":    "Invalid admin token",
+		"老师令牌无效":    "Invalid teacher token",
+		"管理接口仅允许本机访问 (可设置 " + adminTokenEnv + " 以远程管理)": "Admin endpoints are only available from this machine (set " + adminTokenEnv + " for remote access)",
+		"保存用户数据失败":           "Failed to save user data",
+		"加载用户统计数据失败":         "Failed to load answer statistics",
+		"保存用户统计数据失败":         "Failed to save answer statistics",
+		"加载用户错题本失败":          "Failed to load the wrong-question book",
+		"保存用户错题本失败":          "Failed to save the wrong-question book",
+		"保存更新后的错题本失败":        "Failed to save the updated wrong-question book",
+		"加载已删除错题历史失败":        "Failed to load the deleted-question history",
+		"保存已删除错题历史失败":        "Failed to save the deleted-question history",
+		"清理用户统计数据时发生部分或全部失败": "Failed to clear some or all answer statistics",
+		"清理用户错题本时发生部分或全部失败":  "Failed to clear some or all wrong-question books",
+		"清理用户学习记录失败":         "Failed to clear the study activity",
+		"加载用户笔记失败":           "Failed to load notes",
+		"保存用户笔记失败":           "Failed to save notes",
+		"加载用户学习目标失败":         "Failed to load study goals",
+		"保存用户学习目标失败":         "Failed to save study goals",
+		"加载用户学习记录失败":         "Failed to load study activity",
+		"保存用户学习记录失败":         "Failed to save study activity",
+		"加载排行榜设置失败":          "Failed to load leaderboard settings",
+		"保存排行榜设置失败":          "Failed to save leaderboard settings",
+		"保存作业失败":             "Failed to save the assignment",
+		"加载作业记录失败":           "Failed to load assignment work",
+		"保存作业记录失败":           "Failed to save assignment work",
+		"加载对战记录失败":           "Failed to load battle history",
+		"保存对战记录失败":           "Failed to save battle history",
+		"无法初始化用户数据存储区":       "Cannot initialize user data storage",
+		"检查用户数据时出错":          "Failed to check user data",
+		"读取用户数据目录失败":         "Failed to read the user data directory",
+		"保存题目修正失败":           "Failed to save the correction",
+		"保存题目拆解失败":           "Failed to save the explanation",
+		"保存纠错报告失败":           "Failed to save the report",
+		"保存题目标签失败":           "Failed to save the tags",
+		"安装题库失败":             "Failed to install the question bank",
+		"生成 Anki 笔记失败":       "Failed to generate Anki notes",
+		"生成试卷失败":             "Failed to generate the worksheet",
+	},
+}
+
+// appError 带错误码的错误，Args 按顺序填入错误码的提示模板
+type appError struct {
+	Code    errorCode
+	Args    []interface{}
+	Details map[string]interface{} // 可选，随 /api/v1 的错误信封返回
+}
+
+// newAppError 构造带错误码的错误
+func newAppError(code errorCode, args ...interface{}) *appError {
+	return &appError{Code: code, Args: args}
+}
+
+func (e *appError) Error() string { return e.message(langZH) }
+
+// Is 错误码相同即视为同一种错误，因此 errors.Is(err, errUnknownAssignment) 对带参数的错误同样成立
+func (e *appError) Is(target error) bool {
+	t, ok := target.(*appError)
+	return ok && t.Code == e.Code
+}
+
+// with 返回填入参数后的同类错误
+func (e *appError) with(args ...interface{}) *appError {
+	return &appError{Code: e.Code, Args: args}
+}
+
+// message 按语言生成提示，能翻译的字符串参数一并翻译；没有参数的哨兵错误只使用提示模板中参数之前的部分
+func (e *appError) message(lang string) string {
+	template := localize(lang, errorCatalog[e.Code].Message)
+	if len(e.Args) == 0 {
+		if i := strings.Index(template, "%"); i >= 0 {
+			return strings.TrimRight(template[:i], ": ")
+		}
+		return template
+	}
+	args := make([]interface{}, len(e.Args))
+	for i, arg := range e.Args {
+		if text, ok := arg.(string); ok {
+			arg = localize(lang, text) // 如 wrong_mode 中的模式名、forbidden 中的提示
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// newOptionError 参数不是可选值之一
+func newOptionError(param, value string, options ...string) *appError {
+	return newAppError(codeInvalidOption, param, value, strings.Join(options, ", "))
+}
+
+// localize 把中文提示翻译为指定语言，没有译文时返回原文
+func localize(lang, message string) string {
+	if translated, ok := messageBundles[lang][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// requestLanguage 按 Accept-Language 选出支持的语言（按 q 值从高到低，zh* 为中文，en* 为英文），默认中文
+func requestLanguage(c *app.RequestContext) string {
+	header := string(c.GetHeader("Accept-Language"))
+	best, bestQ := langZH, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		var lang string
+		switch tag = strings.ToLower(tag); {
+		case strings.HasPrefix(tag, "zh"):
+			lang = langZH
+		case strings.HasPrefix(tag, "en"):
+			lang = langEN
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// resolveError 把错误对应到状态码和按请求语言生成的错误内容：带错误码的错误查错误码目录，
+// 读写用户数据失败为 storage_error，其余未预料到的错误记录日志后为 internal_error
+func resolveError(c *app.RequestContext, err error) (int, APIError) {
+	lang := requestLanguage(c)
+	var appErr *appError
+	var dataErr *userDataError
+	switch {
+	case errors.As(err, &appErr):
+		return errorCatalog[appErr.Code].Status, APIError{Code: string(appErr.Code), Message: appErr.message(lang), Details: appErr.Details}
+	case errors.As(err, &dataErr):
+		slog.ErrorContext(requestContext(c), "读写数据失败", "method", string(c.Method()), "route", c.FullPath(), "error", err)
+		return consts.StatusInternalServerError, APIError{Code: string(codeStorage), Message: localize(lang, dataErr.Message)}
+	default:
+		slog.ErrorContext(requestContext(c), "处理请求失败", "method", string(c.Method()), "route", c.FullPath(), "error", err)
+		internal := newAppError(codeInternal)
+		return consts.StatusInternalServerError, APIError{Code: string(internal.Code), Message: internal.message(lang)}
+	}
+}
+
+// invalidRequest 把请求绑定、校验或解析失败包装为 invalid_request
+func invalidRequest(err error) *appError {
+	return newAppError(codeInvalidRequest, err.Error())
+}
+
+// writeError 输出 /api 接口的错误：{"error": 提示, "code": 错误码}
+func writeError(c *app.RequestContext, err error) {
+	status, apiErr := resolveError(c, err)
+	c.JSON(status, utils.H{"error": apiErr.Message, "code": apiErr.Code})
+}
+
+// errorCodes 按字母顺序列出全部错误码，用于 OpenAPI 文档
+func errorCodes() []string {
+	codes := make([]string, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+	return codes
+}
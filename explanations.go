@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var (
+	questionExplanations map[string]QuestionExplanation // 题目ID -> 拆解与教材出处（来自旁路文件）
+	explanationsMu       sync.RWMutex                   // 保护 questionExplanations
+)
+
+// loadQuestionExplanations 从旁路文件加载题目拆解
+func loadQuestionExplanations() {
+	explanations := make(map[string]QuestionExplanation)
+	if err := loadOverlayJSON(explanationsFile, &explanations); err != nil {
+		slog.Error("加载题目拆解文件失败，将不显示拆解", "error", err)
+		explanations = make(map[string]QuestionExplanation)
+	}
+	explanationsMu.Lock()
+	questionExplanations = explanations
+	explanationsMu.Unlock()
+	slog.Info("加载题目拆解", "count", len(explanations))
+}
+
+// getQuestionExplanation 返回题目的拆解与出处。旁路文件优先，其次是题库中自带的字段。
+func getQuestionExplanation(q Question) (string, *QuestionReference) {
+	explanationsMu.RLock()
+	entry, ok := questionExplanations[questionIDOf(q)]
+	explanationsMu.RUnlock()
+	if ok {
+		return entry.Explanation, entry.Reference
+	}
+	return q.Explanation, q.Reference
+}
+
+// withExplanations 为题目输出附加拆解与出处（用于速刷模式，题目和答案一起展示）
+func withExplanations(output []QuestionOutput, questions []Question) []QuestionOutput {
+	for i := range output {
+		output[i].Explanation, output[i].Reference = getQuestionExplanation(questions[i])
+	}
+	return output
+}
+
+// answerFeedback 构造答题后的反馈，附带题目的拆解与出处（如果有）
+func answerFeedback(q Question, message string) AnswerFeedback {
+	explanation, reference := getQuestionExplanation(q)
+	return AnswerFeedback{Message: message, Explanation: explanation, Reference: reference}
+}
+
+// UpsertExplanationHandler 处理新增、修改或删除题目拆解的管理请求
+func UpsertExplanationHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req UpsertExplanationRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+
+	questionID := strings.TrimPrefix(req.QuestionID, "quiz_")
+	if _, ok := questionMapByID[questionID]; !ok {
+		writeError(c, errUnknownQuestion.with(req.QuestionID))
+		return
+	}
+
+	explanationsMu.Lock()
+	defer explanationsMu.Unlock()
+
+	// 复制一份再修改，保存失败时内存中的数据保持不变
+	updated := make(map[string]QuestionExplanation, len(questionExplanations)+1)
+	for id, entry := range questionExplanations {
+		updated[id] = entry
+	}
+	explanation := strings.TrimSpace(req.Explanation)
+	removed := explanation == "" && req.Reference == nil
+	if removed {
+		delete(updated, questionID)
+	} else {
+		updated[questionID] = QuestionExplanation{
+			Explanation: explanation,
+			Reference:   req.Reference,
+			UpdatedAt:   time.Now(),
+		}
+	}
+
+	if err := saveOverlayJSON(explanationsFile, updated); err != nil {
+		slog.ErrorContext(ctx, "保存题目拆解文件失败", "question_id", questionID, "error", err)
+		writeError(c, &userDataError{Message: "保存题目拆解失败", Err: err})
+		return
+	}
+	questionExplanations = updated
+
+	if removed {
+		slog.InfoContext(ctx, "已删除题目拆解", "question_id", questionID)
+		c.JSON(consts.StatusOK, utils.H{"message": "拆解已删除", "question_id": questionID})
+		return
+	}
+	slog.InfoContext(ctx, "已更新题目拆解", "question_id", questionID)
+	c.JSON(consts.StatusOK, utils.H{"message": "拆解已保存", "question_id": questionID, "explanation": updated[questionID]})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 题目导出 ---
+// 把选定的题目（或用户的错题本）导出成其他工具可以直接使用的格式，HTTP 接口和 export 子命令共用。
+
+// selectExportQuestions 按导出选择取出题目（已应用修正）。指定用户时取该用户的错题本，
+// 课程为空表示全部课程；否则按课程和章节取题，章节为空表示全部章节。
+func selectExportQuestions(sel ExportSelection) ([]Question, error) {
+	if sel.Course != "" {
+		if _, ok := courseCatalog[sel.Course]; !ok {
+			return nil, newAppError(codeUnknownCourse, sel.Course)
+		}
+	}
+	chapterChoices := sel.ChapterChoice
+	if len(chapterChoices) == 0 {
+		chapterChoices = []string{allChaptersChoice}
+	}
+
+	if sel.UserID == "" {
+		if sel.Course == "" {
+			return nil, newAppError(codeMissingScope)
+		}
+		return _getQuestionsForProcessing(sel.Course, chapterChoices, "sequential", nil)
+	}
+
+	incorrect, err := loadUserIncorrectForCourses(sel.UserID, sel.Course)
+	if err != nil {
+		return nil, err
+	}
+	var inChapters map[string]bool // 课程/章节，为空表示不按章节筛选
+	if sel.Course != "" && len(sel.ChapterChoice) > 0 {
+		chapters, err := resolveChapterChoices(sel.Course, sel.ChapterChoice)
+		if err != nil {
+			return nil, err
+		}
+		inChapters = make(map[string]bool)
+		for _, chapter := range chapters {
+			inChapters[chapter.Course+"/"+chapter.ChapterKey] = true
+		}
+	}
+	questions := make([]Question, 0, len(incorrect))
+	for _, iq := range incorrect {
+		q := incorrectToQuestion(iq) // 题库中找得到时已应用修正
+		if inChapters != nil && !inChapters[q.OriginalCourse+"/"+q.OriginalChapterKey] {
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// ankiField 把文本转换为 Anki 的 HTML 字段：转义并把换行和制表符换成不会破坏列的形式
+func ankiField(text string) string {
+	text = html.EscapeString(strings.TrimSpace(text))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n", "<br>")
+	return strings.ReplaceAll(text, "\t", " ")
+}
+
+// sortedOptionLetters 返回按字母顺序排列的选项
+func sortedOptionLetters(options map[string]string) []string {
+	letters := make([]string, 0, len(options))
+	for letter := range options {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+	return letters
+}
+
+// ankiTags 返回题目的 Anki 标签：课程，以及 "课程::chapter_章节" 层级标签
+func ankiTags(q Question) string {
+	return q.OriginalCourse + " " + q.OriginalCourse + "::chapter_" + q.OriginalChapterKey
+}
+
+// writeAnkiNotes 把题目写成 Anki 可以导入的制表符分隔文本，每道题一条笔记：
+// GUID（按 课程_章节_题号，重复导入时更新而不是新增）、正面（题型、题干和选项）、背面（答案、拆解和出处）和标签。
+func writeAnkiNotes(w io.Writer, questions []Question) error {
+	var buf bytes.Buffer
+	buf.WriteString("#separator:tab\n#html:true\n#guid column:1\n#tags column:4\n")
+	for _, q := range questions {
+		var front, back strings.Builder
+		fmt.Fprintf(&front, "[%s] %s", ankiField(q.QuestionType), ankiField(q.QuestionText))
+		for _, letter := range sortedOptionLetters(q.Options) {
+			fmt.Fprintf(&front, "<br>%s. %s", letter, ankiField(q.Options[letter]))
+		}
+
+		answer := normalizeAnswer(q.CorrectAnswer)
+		fmt.Fprintf(&back, "答案: %s", ankiField(answer))
+		for _, letter := range answer {
+			if text, ok := q.Options[string(letter)]; ok {
+				fmt.Fprintf(&back, "<br>%c. %s", letter, ankiField(text))
+			}
+		}
+		explanation, reference := getQuestionExplanation(q)
+		if explanation != "" {
+			fmt.Fprintf(&back, "<br><br>%s", ankiField(explanation))
+		}
+		if reference != nil {
+			source := strings.TrimSpace(strings.Join([]string{reference.Chapter, reference.Section}, " "))
+			if reference.Page != "" {
+				source = strings.TrimSpace(source + " 第" + reference.Page + "页")
+			}
+			if source != "" {
+				fmt.Fprintf(&back, "<br>出处: %s", ankiField(source))
+			}
+		}
+
+		guid := "meow_" + getQuestionStatKey(q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\n", guid, front.String(), back.String(), ankiTags(q))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// exportFileName 返回导出文件的默认文件名（仅含 ASCII，便于作为下载文件名）
+func exportFileName(sel ExportSelection, kind, ext string) string {
+	name := sel.Course
+	if name == "" {
+		name = "all"
+	}
+	if sel.UserID != "" {
+		name += "_wrongbook"
+	}
+	return name + "_" + kind + ext
+}
+
+// bindExportSelection 绑定导出请求，并按逗号拆分章节参数（既支持 chapter_choice=1&chapter_choice=2，也支持 chapter_choice=1,2）
+func bindExportSelection(c *app.RequestContext, req interface{}, sel *ExportSelection) bool {
+	if err := c.BindAndValidate(req); err != nil {
+		writeError(c, invalidRequest(err))
+		return false
+	}
+	sel.ChapterChoice = splitListParams(sel.ChapterChoice)
+	return true
+}
+
+// splitListParams 展开查询参数中的列表：可重复出现，也可用逗号分隔
+func splitListParams(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// AnkiExportHandler 把选定章节的题目或用户的错题本导出为 Anki 可导入的文本
+// (?course=&chapter_choice=&user_id=)
+func AnkiExportHandler(ctx context.Context, c *app.RequestContext) {
+	var req ExportSelection
+	if !bindExportSelection(c, &req, &req) {
+		return
+	}
+	questions, err := selectExportQuestions(req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := writeAnkiNotes(&buf, questions); err != nil {
+		writeError(c, &userDataError{Message: "生成 Anki 笔记失败", Err: err})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+exportFileName(req, "anki", ".txt")+`"`)
+	c.Data(consts.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 全局题目难度 ---
+// 汇总服务器上所有用户在答题模式下的作答：每道题每名用户只记首次作答是否答对，重复刷同一道题不会拉高正确率。
+// 由此计算经典测量理论中的难度指数（答对比例）和区分度（高分组与低分组答对比例之差，
+// 按每名用户在其余题目上的首次正确率分组），作答人数不足时不给出。
+
+const (
+	difficultyMinRespondents     = 5    // 给出难度指数所需的最少作答人数
+	discriminationMinRespondents = 10   // 给出区分度所需的最少作答人数
+	discriminationGroupRatio     = 0.27 // 高分组和低分组各占作答人数的比例
+	difficultyEasyThreshold      = 0.7  // 难度指数不低于此值为「容易」
+	difficultyHardThreshold      = 0.3  // 难度指数低于此值为「较难」
+	hardestSourceMinQuestions    = 10   // 「最难的题」来源至少出的题数
+	difficultySortHardest        = "hardest"
+	difficultySortEasiest        = "easiest"
+	difficultySortDiscrimination = "discrimination"
+	globalStatsFlushInterval     = 30 * time.Second // 新的首次作答多久后写入旁路文件
+)
+
+var (
+	globalAnswers      map[string]map[string]bool  // 题目ID -> 用户 -> 首次作答是否答对（来自旁路文件）
+	globalDifficulties map[string]GlobalDifficulty // 题目ID -> 难度，为空表示需要重新计算
+	globalStatsDirty   bool                        // 有尚未写入文件的首次作答
+	globalStatsMu      sync.Mutex                  // 保护 globalAnswers、globalDifficulties 和 globalStatsDirty
+	globalStatsFlushMu sync.Mutex                  // 保证写文件按顺序进行，写入时不持有 globalStatsMu
+)
+
+// loadGlobalStats 从旁路文件加载全局作答统计。文件不存在时（首次启用）用已有的用户统计回填：
+// 用户统计只有累计次数，答错过的题按首次答错计，只答对过的按首次答对计。
+func loadGlobalStats() {
+	loaded := make(map[string]map[string]bool)
+	if _, err := os.Stat(getOverlayPath(globalStatsFile)); os.IsNotExist(err) {
+		loaded = backfillGlobalStats()
+		if err := saveOverlayJSON(globalStatsFile, loaded); err != nil {
+			slog.Error("保存回填的全局作答统计失败", "error", err)
+		}
+	} else if err := loadOverlayJSON(globalStatsFile, &loaded); err != nil {
+		slog.Error("加载全局作答统计失败", "error", err)
+		loaded = make(map[string]map[string]bool)
+	}
+	globalStatsMu.Lock()
+	globalAnswers = loaded
+	globalDifficulties = nil
+	globalStatsMu.Unlock()
+	slog.Info("加载全局作答统计", "questions", len(loaded))
+}
+
+// backfillGlobalStats 由 user_data 下所有用户的题目统计生成全局作答统计
+func backfillGlobalStats() map[string]map[string]bool {
+	answers := make(map[string]map[string]bool)
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("读取用户数据目录失败，全局作答统计从零开始", "error", err)
+		}
+		return answers
+	}
+	questions, err := questionsInScope("")
+	if err != nil {
+		slog.Warn("读取题库失败，全局作答统计从零开始", "error", err)
+		return answers
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		userStats, err := loadUserStats(userID)
+		if err != nil {
+			slog.Warn("回填全局作答统计时跳过用户", logKeyUserID, userID, "error", err)
+			continue
+		}
+		for _, q := range questions {
+			stat, ok := userStatFor(userStats, q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+			if !ok || stat.CorrectCount+stat.ErrorCount == 0 {
+				continue
+			}
+			id := questionIDOf(q)
+			if answers[id] == nil {
+				answers[id] = make(map[string]bool)
+			}
+			answers[id][userID] = stat.ErrorCount == 0
+		}
+	}
+	slog.Info("由用户统计回填全局作答统计", "questions", len(answers))
+	return answers
+}
+
+// recordGlobalAnswer 记录用户对一道题的作答，只有首次作答会计入全局统计。
+// 只更新内存，由 flushGlobalStats 定时和退出时写入文件。
+func recordGlobalAnswer(userID string, q Question, wasCorrect bool) {
+	id := questionIDOf(q)
+	globalStatsMu.Lock()
+	defer globalStatsMu.Unlock()
+	if globalAnswers == nil {
+		globalAnswers = make(map[string]map[string]bool)
+	}
+	if _, answered := globalAnswers[id][userID]; answered {
+		return
+	}
+	if globalAnswers[id] == nil {
+		globalAnswers[id] = make(map[string]bool)
+	}
+	globalAnswers[id][userID] = wasCorrect
+	globalDifficulties = nil
+	globalStatsDirty = true
+}
+
+// flushGlobalStats 把尚未保存的全局作答统计写入旁路文件。复制一份后再写，写文件时不阻塞作答。
+func flushGlobalStats() error {
+	globalStatsFlushMu.Lock()
+	defer globalStatsFlushMu.Unlock()
+
+	globalStatsMu.Lock()
+	if !globalStatsDirty {
+		globalStatsMu.Unlock()
+		return nil
+	}
+	snapshot := make(map[string]map[string]bool, len(globalAnswers))
+	for id, users := range globalAnswers {
+		snapshot[id] = maps.Clone(users)
+	}
+	globalStatsDirty = false
+	globalStatsMu.Unlock()
+
+	if err := saveOverlayJSON(globalStatsFile, snapshot); err != nil {
+		globalStatsMu.Lock()
+		globalStatsDirty = true // 下次再试
+		globalStatsMu.Unlock()
+		return fmt.Errorf("保存全局作答统计失败: %w", err)
+	}
+	return nil
+}
+
+// startGlobalStatsFlusher 启动后台定时保存全局作答统计，开始退出时停止（最后一次保存由 finishShutdown 完成）
+func startGlobalStatsFlusher(background *sync.WaitGroup) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(globalStatsFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := flushGlobalStats(); err != nil {
+					slog.Warn("定时保存全局作答统计失败", "error", err)
+				}
+			case <-stopping:
+				return
+			}
+		}
+	}()
+}
+
+// difficultyLevel 按难度指数给出难度等级
+func difficultyLevel(index float64) string {
+	switch {
+	case index >= difficultyEasyThreshold:
+		return "容易"
+	case index < difficultyHardThreshold:
+		return "较难"
+	default:
+		return "中等"
+	}
+}
+
+// computeGlobalDifficulties 由全局作答统计计算每道题的难度指数和区分度，调用方需持有 globalStatsMu
+func computeGlobalDifficulties() map[string]GlobalDifficulty {
+	// 每名用户的首次作答总数和答对数，用于区分度分组
+	type userTotal struct{ answered, correct int }
+	totals := make(map[string]userTotal)
+	for _, users := range globalAnswers {
+		for userID, correct := range users {
+			total := totals[userID]
+			total.answered++
+			if correct {
+				total.correct++
+			}
+			totals[userID] = total
+		}
+	}
+
+	difficulties := make(map[string]GlobalDifficulty)
+	for id, users := range globalAnswers {
+		n := len(users)
+		if n < difficultyMinRespondents {
+			continue
+		}
+		d := GlobalDifficulty{Respondents: n}
+		for _, correct := range users {
+			if correct {
+				d.CorrectCount++
+			}
+		}
+		d.DifficultyIndex = float64(d.CorrectCount) / float64(n)
+		d.Level = difficultyLevel(d.DifficultyIndex)
+
+		if n >= discriminationMinRespondents {
+			// 按在其余题目上的首次正确率排序（不含本题，避免本题自身抬高相关性），取两端各 27% 比较本题的答对比例
+			type respondent struct {
+				userID  string
+				ability float64
+				correct bool
+			}
+			respondents := make([]respondent, 0, n)
+			for userID, correct := range users {
+				total := totals[userID]
+				rest, restCorrect := total.answered-1, total.correct
+				if correct {
+					restCorrect--
+				}
+				ability := 0.0
+				if rest > 0 {
+					ability = float64(restCorrect) / float64(rest)
+				}
+				respondents = append(respondents, respondent{userID: userID, ability: ability, correct: correct})
+			}
+			sort.Slice(respondents, func(i, j int) bool {
+				if respondents[i].ability != respondents[j].ability {
+					return respondents[i].ability > respondents[j].ability
+				}
+				return respondents[i].userID < respondents[j].userID
+			})
+			group := max(1, int(math.Round(float64(n)*discriminationGroupRatio)))
+			upper, lower := 0, 0
+			for i := 0; i < group; i++ {
+				if respondents[i].correct {
+					upper++
+				}
+				if respondents[n-1-i].correct {
+					lower++
+				}
+			}
+			discrimination := float64(upper-lower) / float64(group)
+			d.Discrimination = &discrimination
+		}
+		difficulties[id] = d
+	}
+	return difficulties
+}
+
+// getGlobalDifficulties 返回每道题的难度，有新的作答后重新计算
+func getGlobalDifficulties() map[string]GlobalDifficulty {
+	globalStatsMu.Lock()
+	defer globalStatsMu.Unlock()
+	if globalDifficulties == nil {
+		globalDifficulties = computeGlobalDifficulties()
+	}
+	return globalDifficulties
+}
+
+// withGlobalCounts 填入题目的全局答对和答错人数
+func withGlobalCounts(q Question) Question {
+	globalStatsMu.Lock()
+	defer globalStatsMu.Unlock()
+	q.GlobalCorrectCount, q.GlobalErrorCount = 0, 0
+	for _, correct := range globalAnswers[questionIDOf(q)] {
+		if correct {
+			q.GlobalCorrectCount++
+		} else {
+			q.GlobalErrorCount++
+		}
+	}
+	return q
+}
+
+// withGlobalDifficulty 为题目输出附加全局难度，作答人数不足的题目不附带
+func withGlobalDifficulty(output []QuestionOutput) []QuestionOutput {
+	difficulties := getGlobalDifficulties()
+	for i := range output {
+		if d, ok := difficulties[output[i].QuestionID]; ok {
+			output[i].Difficulty = &d
+		}
+	}
+	return output
+}
+
+// filterHardestQuestions 只保留有难度数据的题目中难度指数最低的四分之一（至少 hardestSourceMinQuestions 题），保持原有顺序
+func filterHardestQuestions(questions []Question) []Question {
+	difficulties := getGlobalDifficulties()
+	rated := []Question{}
+	for _, q := range questions {
+		if _, ok := difficulties[questionIDOf(q)]; ok {
+			rated = append(rated, q)
+		}
+	}
+	keep := max(hardestSourceMinQuestions, (len(rated)+3)/4)
+	if keep >= len(rated) {
+		return rated
+	}
+	ranked := append([]Question{}, rated...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return difficulties[questionIDOf(ranked[i])].DifficultyIndex < difficulties[questionIDOf(ranked[j])].DifficultyIndex
+	})
+	hardest := make(map[string]bool, keep)
+	for _, q := range ranked[:keep] {
+		hardest[questionIDOf(q)] = true
+	}
+	filtered := make([]Question, 0, keep)
+	for _, q := range rated {
+		if hardest[questionIDOf(q)] {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
+
+// listQuestionDifficulties 按指定顺序列出课程（为空表示全部课程）中有难度数据的题目
+func listQuestionDifficulties(course, sortBy string, limit int) ([]QuestionDifficultyEntry, error) {
+	if course != "" {
+		if _, ok := courseCatalog[course]; !ok {
+			return nil, newAppError(codeUnknownCourse, course)
+		}
+	}
+	var less func(a, b GlobalDifficulty) bool
+	switch sortBy {
+	case "", difficultySortHardest:
+		less = func(a, b GlobalDifficulty) bool { return a.DifficultyIndex < b.DifficultyIndex }
+	case difficultySortEasiest:
+		less = func(a, b GlobalDifficulty) bool { return a.DifficultyIndex > b.DifficultyIndex }
+	case difficultySortDiscrimination:
+		// 区分度低（甚至为负）的题可能答案有误或题意不清，排在前面；没有区分度的排在最后
+		less = func(a, b GlobalDifficulty) bool {
+			if a.Discrimination == nil || b.Discrimination == nil {
+				return a.Discrimination != nil && b.Discrimination == nil
+			}
+			return *a.Discrimination < *b.Discrimination
+		}
+	default:
+		return nil, newOptionError("sort", sortBy, "hardest", "easiest", "discrimination")
+	}
+	if limit < 0 {
+		return nil, newAppError(codeNegativeValue, "limit", limit)
+	}
+
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return nil, err
+	}
+	difficulties := getGlobalDifficulties()
+	list := []QuestionDifficultyEntry{}
+	for _, q := range questions {
+		d, ok := difficulties[questionIDOf(q)]
+		if !ok {
+			continue
+		}
+		list = append(list, QuestionDifficultyEntry{
+			QuestionID:     questionIDOf(q),
+			OriginalCourse: q.OriginalCourse,
+			Chapter:        q.OriginalChapterKey,
+			QuestionNumber: q.QuestionNumber,
+			QuestionType:   q.QuestionType,
+			QuestionText:   q.QuestionText,
+			Difficulty:     d,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return less(list[i].Difficulty, list[j].Difficulty) })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// QuestionDifficultyHandler 按难度或区分度列出题目 (?course=&sort=hardest|easiest|discrimination&limit=)
+func QuestionDifficultyHandler(ctx context.Context, c *app.RequestContext) {
+	var req QuestionDifficultyRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := questionDifficultyList(req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// questionDifficultyList 按请求列出题目的难度，并附上计算难度和区分度所需的最少人数
+func questionDifficultyList(req QuestionDifficultyRequest) (QuestionDifficultyResponse, error) {
+	list, err := listQuestionDifficulties(req.Course, req.Sort, req.Limit)
+	if err != nil {
+		return QuestionDifficultyResponse{}, err
+	}
+	return QuestionDifficultyResponse{
+		MinRespondents:               difficultyMinRespondents,
+		DiscriminationMinRespondents: discriminationMinRespondents,
+		Total:                        len(list),
+		Questions:                    list,
+	}, nil
+}
@@ -2,7 +2,11 @@ module quiz
 
 go 1.24.1
 
-require github.com/cloudwego/hertz v0.10.3
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/cloudwego/hertz v0.10.3
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/bytedance/gopkg v0.1.1 // indirect
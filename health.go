@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// bankLoadIssues 启动时加载题库遇到的问题，按课程记录章节键
+var bankLoadIssues = struct {
+	mu      sync.Mutex
+	missing map[string][]string // 没有题库文件的章节
+	failed  map[string][]string // 题库文件无法读取或解析的章节
+}{
+	missing: make(map[string][]string),
+	failed:  make(map[string][]string),
+}
+
+// resetBankLoadIssues 在重新加载题库前清空记录
+func resetBankLoadIssues() {
+	bankLoadIssues.mu.Lock()
+	defer bankLoadIssues.mu.Unlock()
+	bankLoadIssues.missing = make(map[string][]string)
+	bankLoadIssues.failed = make(map[string][]string)
+}
+
+// recordBankLoadIssue 记录一个没能加载的章节
+func recordBankLoadIssue(course, chapterKey string, missing bool) {
+	bankLoadIssues.mu.Lock()
+	defer bankLoadIssues.mu.Unlock()
+	if missing {
+		bankLoadIssues.missing[course] = append(bankLoadIssues.missing[course], chapterKey)
+	} else {
+		bankLoadIssues.failed[course] = append(bankLoadIssues.failed[course], chapterKey)
+	}
+}
+
+// bankReadiness 汇总各实体课程的题库加载情况：每门课程都有题目且没有章节加载失败时为就绪
+func bankReadiness() BankReadiness {
+	bankLoadIssues.mu.Lock()
+	defer bankLoadIssues.mu.Unlock()
+	readiness := BankReadiness{OK: true}
+	for _, course := range physicalCourses() {
+		status := CourseBankStatus{
+			Course:          course,
+			MissingChapters: bankLoadIssues.missing[course],
+			FailedChapters:  bankLoadIssues.failed[course],
+		}
+		if meta, ok := courseCatalog[course]; ok {
+			status.QuestionCount = meta.QuestionCount
+		}
+		if status.QuestionCount == 0 || len(status.FailedChapters) > 0 {
+			readiness.OK = false
+		}
+		readiness.Courses = append(readiness.Courses, status)
+	}
+	return readiness
+}
+
+// checkDirWritable 在目录中创建并删除一个临时文件，确认目录可写
+func checkDirWritable(dir string) DirReadiness {
+	result := DirReadiness{Path: dir}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// HealthzHandler 存活检查：进程能处理请求即返回 200
+func HealthzHandler(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, MessageResponse{Message: "ok"})
+}
+
+// ReadyzHandler 就绪检查：题库已加载且用户数据目录、旁路文件目录可写时返回 200，否则（包括正在退出时）返回 503
+func ReadyzHandler(ctx context.Context, c *app.RequestContext) {
+	resp := ReadinessResponse{
+		Status: "ready",
+		Bank:   bankReadiness(),
+		Data:   []DirReadiness{checkDirWritable(userDataBaseDir), checkDirWritable(bankOverlayDir)},
+	}
+	ready := resp.Bank.OK
+	for _, dir := range resp.Data {
+		ready = ready && dir.OK
+	}
+	status := consts.StatusOK
+	switch {
+	case shuttingDown.Load():
+		resp.Status = "shutting_down"
+		status = consts.StatusServiceUnavailable
+	case !ready:
+		resp.Status = "not_ready"
+		status = consts.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
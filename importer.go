@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- 试卷导入 ---
+// 把老师发的纯文本 / Markdown 试卷解析为章节题库 ([]Question)。支持的常见写法：
+//   1. 题干(  )。            题号后可以是 . 、 ) ： 等，也支持 "第1题"
+//   A.xx B.xx  或每行一个选项  选项字母后可以是 . 、 ： ) 等，多个选项可以写在同一行
+//   答案：C / 【答案】AB       每道题后的答案；题干括号中的答案 "(C)" 也能识别
+//   参考答案 1-5 ACBDA 6.AB   试卷末尾集中给出的答案表，判断题可以写 对/错/√/×
+//   - [x] A. xx              Markdown 勾选框标出的答案
+//   解析：...                 作为拆解导入
+// 无法确定的题目会在审核报告中列出，需要人工确认。
+
+var (
+	importQuestionStartRe = regexp.MustCompile(`^(?:第\s*)?(\d{1,4})\s*(?:题)?\s*[.、)）:：]\s*(.*)$`)
+	importOptionRe        = regexp.MustCompile(`(?:^|[^A-Za-z0-9(（])([A-H])\s*[.、:：)）]\s*`)
+	importInlineAnswerRe  = regexp.MustCompile(`(?:【\s*(?:正确)?答案\s*】|(?:正确|参考)?答案\s*[:：])\s*([A-Ha-h]{1,8}|对|错|正确|错误|√|×)`)
+	importStemAnswerRe    = regexp.MustCompile(`[(（]\s*([A-H]{1,8})\s*[)）]`)
+	importExplanationRe   = regexp.MustCompile(`^(?:【\s*解析\s*】|解析\s*[:：])\s*(.*)$`)
+	importKeyHeadingRe    = regexp.MustCompile(`^(?:参考|标准)?答案(?:与解析|及解析)?\s*[:：]?\s*(.*)$`)
+	importKeyRangeRe      = regexp.MustCompile(`(\d+)\s*[-~—–－]\s*(\d+)\s*[.、:：]?\s*((?:(?:[A-H]+|[对错√×]+)\s*)+)`)
+	importKeySingleRe     = regexp.MustCompile(`(\d+)\s*[.、:：]?\s*([A-H]+|对|错|√|×)`)
+	importKeyLineRe       = regexp.MustCompile(`^(?:\d+\s*(?:[-~—–－]\s*\d+\s*)?[.、:：]?\s*(?:(?:[A-H]+|[对错√×]+)\s*)+)+$`)
+	importMarkdownListRe  = regexp.MustCompile(`^(?:[-*+]\s+)?(?:\[([ xX])\]\s*)?`)
+)
+
+// 试卷中用于提示题型的小节标题关键词，按顺序匹配
+var importSectionTypes = []struct {
+	keyword      string
+	questionType string
+}{
+	{"不定项", "不定项"},
+	{"多选", "多选题"},
+	{"多项选择", "多选题"},
+	{"单选", "单选题"},
+	{"单项选择", "单选题"},
+	{"判断", "判断题"},
+}
+
+// importedQuestion 解析中的一道题
+type importedQuestion struct {
+	number        string
+	line          int
+	typeHint      string // 所在小节或题干标注的题型
+	stem          []string
+	options       map[string]string
+	optionOrder   []string
+	lastOption    string
+	inlineAnswer  string
+	keyAnswer     string
+	checkedAnswer string
+	stemAnswer    string
+	explanation   []string
+	problems      []string
+}
+
+// ImportIssue 导入时需要人工确认的问题
+type ImportIssue struct {
+	QuestionNumber string `json:"question_number"`
+	Line           int    `json:"line"`
+	Problem        string `json:"problem"`
+}
+
+// PaperImportResult 试卷解析结果
+type PaperImportResult struct {
+	Questions []Question    `json:"questions"`
+	Issues    []ImportIssue `json:"issues"`
+	Flagged   int           `json:"flagged"` // 有问题的题目数
+}
+
+// normalizeImportLine 全角字母数字和标点转半角，去掉 Markdown 标记，返回勾选框是否勾选
+func normalizeImportLine(line string, markdown bool) (string, bool) {
+	line = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'Ａ' && r <= 'Ｚ':
+			return r - 'Ａ' + 'A'
+		case r >= 'ａ' && r <= 'ｚ':
+			return r - 'ａ' + 'a'
+		case r >= '０' && r <= '９':
+			return r - '０' + '0'
+		case r == '．':
+			return '.'
+		case r == '　':
+			return ' '
+		}
+		return r
+	}, line)
+	line = strings.TrimSpace(line)
+	if !markdown {
+		return line, false
+	}
+	line = strings.TrimLeft(line, "#>")
+	line = strings.NewReplacer("**", "", "__", "", "`", "").Replace(strings.TrimSpace(line))
+	checked := false
+	if m := importMarkdownListRe.FindStringSubmatch(line); m != nil && m[0] != "" {
+		checked = strings.EqualFold(m[1], "x")
+		line = strings.TrimSpace(line[len(m[0]):])
+	}
+	return line, checked
+}
+
+// sectionTypeHint 判断一行是否为题型小节标题 (如 "二、多项选择题")，返回题型
+func sectionTypeHint(line string) string {
+	if utf8.RuneCountInString(line) > 40 || importQuestionStartRe.MatchString(line) || importOptionRe.MatchString(line) {
+		return ""
+	}
+	if !strings.Contains(line, "题") && !strings.Contains(line, "不定项") {
+		return ""
+	}
+	for _, section := range importSectionTypes {
+		if strings.Contains(line, section.keyword) {
+			return section.questionType
+		}
+	}
+	return ""
+}
+
+// parseExamPaper 解析试卷文本
+func parseExamPaper(text string, markdown bool) *PaperImportResult {
+	var questions []*importedQuestion
+	var current *importedQuestion
+	sectionType := ""
+	inKey := false
+	inExplanation := false
+	inCodeBlock := false
+	keyAnswers := make(map[string]string)
+	var keyIssues []ImportIssue
+
+	parseKey := func(text string, lineNo int) {
+		text = importKeyRangeRe.ReplaceAllStringFunc(text, func(match string) string {
+			m := importKeyRangeRe.FindStringSubmatch(match)
+			start, _ := strconv.Atoi(m[1])
+			end, _ := strconv.Atoi(m[2])
+			tokens := strings.Fields(m[3])
+			count := end - start + 1
+			if len(tokens) != count {
+				// 连写的答案每个字符一题，如 "1-3 CBA"、"4-6 对错对"
+				joined := []rune(strings.Join(tokens, ""))
+				if count <= 0 || len(joined) != count {
+					keyIssues = append(keyIssues, ImportIssue{Line: lineNo, Problem: fmt.Sprintf("答案表 %q 的答案个数与题号范围不符", match)})
+					return " "
+				}
+				tokens = make([]string, count)
+				for i, r := range joined {
+					tokens[i] = string(r)
+				}
+			}
+			for i, token := range tokens {
+				keyAnswers[strconv.Itoa(start+i)] = token
+			}
+			return " "
+		})
+		for _, m := range importKeySingleRe.FindAllStringSubmatch(text, -1) {
+			keyAnswers[m[1]] = m[2]
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, rawLine := range lines {
+		lineNo := i + 1
+		if markdown && strings.HasPrefix(strings.TrimSpace(rawLine), "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		line, checked := normalizeImportLine(rawLine, markdown)
+		if line == "" || strings.Trim(line, "-=*_ ") == "" {
+			continue
+		}
+
+		// 集中给出的答案表
+		if m := importKeyHeadingRe.FindStringSubmatch(line); m != nil && (m[1] == "" || importKeyLineRe.MatchString(m[1])) {
+			inKey = true
+			inExplanation = false
+			current = nil
+			parseKey(m[1], lineNo)
+			continue
+		}
+		if inKey && importQuestionStartRe.MatchString(line) && !importKeyLineRe.MatchString(line) {
+			inKey = false // 答案表之后又出现了题目，例如每节后面附答案的试卷
+		}
+		if inKey || importKeyLineRe.MatchString(line) && len(importKeySingleRe.FindAllString(line, -1)) > 1 {
+			if hint := sectionTypeHint(line); hint != "" {
+				continue // 答案表中的小节标题
+			}
+			parseKey(line, lineNo)
+			continue
+		}
+
+		if hint := sectionTypeHint(line); hint != "" && (current == nil || len(current.options) > 0) {
+			sectionType = hint
+			current = nil
+			continue
+		}
+
+		if m := importExplanationRe.FindStringSubmatch(line); m != nil && current != nil {
+			inExplanation = true
+			current.explanation = append(current.explanation, m[1])
+			continue
+		}
+
+		if m := importQuestionStartRe.FindStringSubmatch(line); m != nil {
+			current = &importedQuestion{number: m[1], line: lineNo, typeHint: sectionType, options: make(map[string]string)}
+			questions = append(questions, current)
+			inExplanation = false
+			line = m[2]
+			if line == "" {
+				continue
+			}
+		}
+		if current == nil {
+			continue // 试卷标题、说明等
+		}
+		if inExplanation {
+			current.explanation = append(current.explanation, line)
+			continue
+		}
+
+		// 每道题后的答案，可能单独一行，也可能跟在选项后面
+		if loc := importInlineAnswerRe.FindStringSubmatchIndex(line); loc != nil {
+			answer := line[loc[2]:loc[3]]
+			if current.inlineAnswer != "" && current.inlineAnswer != answer {
+				current.problems = append(current.problems, fmt.Sprintf("出现了多个答案: %s 和 %s", current.inlineAnswer, answer))
+			}
+			current.inlineAnswer = answer
+			line = strings.TrimSpace(line[:loc[0]] + line[loc[1]:])
+			if line == "" {
+				continue
+			}
+		}
+
+		// 题干括号中填写的答案，如 "……是(C)。"，先取出来以免被当作选项
+		if len(current.options) == 0 {
+			if m := importStemAnswerRe.FindStringSubmatch(line); m != nil {
+				current.stemAnswer = m[1]
+				line = importStemAnswerRe.ReplaceAllString(line, "（  ）")
+			}
+		}
+
+		// 选项，可能多个选项写在同一行；选项之前的文字属于题干或上一个选项。
+		// 匹配位置以选项字母 (loc[2]) 为准，匹配中可能包含字母前的一个标点
+		locs := importOptionRe.FindAllStringSubmatchIndex(line, -1)
+		if len(locs) == 0 {
+			if current.lastOption != "" {
+				current.options[current.lastOption] += line
+			} else {
+				current.stem = append(current.stem, line)
+			}
+			continue
+		}
+		if prefix := strings.TrimSpace(line[:locs[0][2]]); prefix != "" {
+			if current.lastOption != "" {
+				current.options[current.lastOption] += prefix
+			} else {
+				current.stem = append(current.stem, prefix)
+			}
+		}
+		for j, loc := range locs {
+			letter := line[loc[2]:loc[3]]
+			end := len(line)
+			if j+1 < len(locs) {
+				end = locs[j+1][2]
+			}
+			if _, exists := current.options[letter]; exists {
+				current.problems = append(current.problems, fmt.Sprintf("选项 %s 重复出现", letter))
+			} else {
+				current.optionOrder = append(current.optionOrder, letter)
+			}
+			current.options[letter] = strings.TrimSpace(line[loc[1]:end])
+			current.lastOption = letter
+			if checked && len(locs) == 1 {
+				current.checkedAnswer += letter
+			}
+		}
+	}
+
+	for _, q := range questions {
+		q.keyAnswer = keyAnswers[q.number]
+	}
+	return buildImportResult(questions, keyIssues)
+}
+
+// resolveImportedAnswer 综合各来源的答案：题后答案 > 答案表 > 勾选框 > 题干括号，来源不一致时记录问题
+func resolveImportedAnswer(q *importedQuestion) string {
+	answer := ""
+	for _, candidate := range []string{q.inlineAnswer, q.keyAnswer, q.checkedAnswer, q.stemAnswer} {
+		candidate = normalizeJudgeAnswer(candidate)
+		if candidate == "" {
+			continue
+		}
+		if answer == "" {
+			answer = candidate
+		} else if normalizeAnswer(candidate) != normalizeAnswer(answer) {
+			q.problems = append(q.problems, fmt.Sprintf("答案来源不一致: %s 与 %s，已采用 %s", answer, candidate, answer))
+		}
+	}
+	return answer
+}
+
+// normalizeJudgeAnswer 判断题的 对/错 转换为 A/B，其余规范化为大写字母
+func normalizeJudgeAnswer(answer string) string {
+	switch answer {
+	case "对", "正确", "√":
+		return "A"
+	case "错", "错误", "×":
+		return "B"
+	}
+	return normalizeAnswer(answer)
+}
+
+// buildImportResult 确定题型和答案，生成题目并汇总需要人工确认的问题
+func buildImportResult(parsed []*importedQuestion, keyIssues []ImportIssue) *PaperImportResult {
+	result := &PaperImportResult{Questions: []Question{}, Issues: keyIssues}
+	seenNumbers := make(map[string]int)
+	for _, q := range parsed {
+		answer := resolveImportedAnswer(q)
+		stem := strings.TrimSpace(strings.Join(q.stem, ""))
+
+		questionType := q.typeHint
+		switch {
+		case strings.Contains(stem, "不定项"):
+			questionType = "不定项"
+		case strings.Contains(stem, "(多选") || strings.Contains(stem, "（多选"):
+			questionType = "多选题"
+		case strings.Contains(stem, "(单选") || strings.Contains(stem, "（单选"):
+			questionType = "单选题"
+		}
+		if questionType == "判断题" && len(q.options) == 0 {
+			q.options = map[string]string{"A": "正确", "B": "错误"}
+			q.optionOrder = []string{"A", "B"}
+		}
+		if questionType == "" {
+			switch {
+			case len(answer) > 1:
+				questionType = "多选题"
+			case len(answer) == 1:
+				questionType = "单选题"
+			default:
+				questionType = "单选题"
+				q.problems = append(q.problems, "无法判断题型，暂按单选题处理")
+			}
+		}
+
+		if prev, ok := seenNumbers[q.number]; ok {
+			q.problems = append(q.problems, fmt.Sprintf("题号与第 %d 行的题目重复", prev))
+		}
+		seenNumbers[q.number] = q.line
+		if stem == "" {
+			q.problems = append(q.problems, "题干为空")
+		}
+		if len(q.options) < 2 {
+			q.problems = append(q.problems, fmt.Sprintf("只识别到 %d 个选项", len(q.options)))
+		}
+		for i, letter := range q.optionOrder {
+			if letter != string(rune('A'+i)) {
+				q.problems = append(q.problems, fmt.Sprintf("选项字母不连续: %s", strings.Join(q.optionOrder, "")))
+				break
+			}
+		}
+		switch {
+		case answer == "":
+			q.problems = append(q.problems, "没有找到答案")
+		case !answerMatchesOptions(answer, q.options):
+			q.problems = append(q.problems, fmt.Sprintf("答案 %s 不在选项中", answer))
+		case questionType == "单选题" && len(answer) > 1:
+			q.problems = append(q.problems, fmt.Sprintf("单选题有多个答案 %s", answer))
+		}
+
+		explanation := strings.TrimSpace(strings.Join(q.explanation, "\n"))
+		result.Questions = append(result.Questions, Question{
+			QuestionNumber: q.number,
+			QuestionType:   questionType,
+			QuestionText:   stem,
+			Options:        q.options,
+			CorrectAnswer:  answer,
+			Explanation:    explanation,
+		})
+		if len(q.problems) > 0 {
+			result.Flagged++
+		}
+		for _, problem := range q.problems {
+			result.Issues = append(result.Issues, ImportIssue{QuestionNumber: q.number, Line: q.line, Problem: problem})
+		}
+	}
+	return result
+}
+
+// writeImportReport 生成 Markdown 格式的审核报告
+func writeImportReport(path, source, output string, result *PaperImportResult) error {
+	typeCounts := make(map[string]int)
+	for _, q := range result.Questions {
+		typeCounts[q.QuestionType]++
+	}
+	types := make([]string, 0, len(typeCounts))
+	for t := range typeCounts {
+		types = append(types, fmt.Sprintf("%s %d", t, typeCounts[t]))
+	}
+	sort.Strings(types)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 导入报告: %s\n\n", filepath.Base(source))
+	fmt.Fprintf(&b, "- 识别题目: %d 道 (%s)\n", len(result.Questions), strings.Join(types, ", "))
+	fmt.Fprintf(&b, "- 需要人工确认: %d 道\n", result.Flagged)
+	fmt.Fprintf(&b, "- 输出文件: %s\n\n", output)
+	if len(result.Issues) == 0 {
+		b.WriteString("没有发现问题。\n")
+	} else {
+		b.WriteString("## 需要人工确认\n\n| 题号 | 行号 | 问题 | 题干 |\n| --- | --- | --- | --- |\n")
+		stems := make(map[string]string)
+		for _, q := range result.Questions {
+			stems[q.QuestionNumber] = q.QuestionText
+		}
+		for _, issue := range result.Issues {
+			stem := []rune(stems[issue.QuestionNumber])
+			if len(stem) > 30 {
+				stem = append(stem[:30], []rune("…")...)
+			}
+			fmt.Fprintf(&b, "| %s | %d | %s | %s |\n", issue.QuestionNumber, issue.Line, issue.Problem, strings.ReplaceAll(string(stem), "|", "\\|"))
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// runImportCommand import <试卷>：把文本 / Markdown 试卷解析为章节题库 JSON 和审核报告
+func runImportCommand(args []string) error {
+	const usage = "import <试卷.txt|试卷.md> [-o 章节.json] [-report 报告.md] [-format auto|text|markdown] [-skip-flagged]"
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	output := fs.String("o", "", "输出的章节题库文件，默认与试卷同名的 .json")
+	reportPath := fs.String("report", "", "审核报告文件，默认与试卷同名的 .report.md")
+	format := fs.String("format", "auto", "试卷格式: auto (按扩展名), text, markdown")
+	skipFlagged := fs.Bool("skip-flagged", false, "不输出需要人工确认的题目")
+	var positional []string
+	for rest := args; ; {
+		fs.Parse(rest)
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("用法: %s", usage)
+	}
+	source := positional[0]
+
+	markdown := false
+	switch *format {
+	case "auto":
+		ext := strings.ToLower(filepath.Ext(source))
+		markdown = ext == ".md" || ext == ".markdown"
+	case "markdown", "md":
+		markdown = true
+	case "text", "txt":
+	default:
+		return fmt.Errorf("不支持的试卷格式: %s", *format)
+	}
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	if *output == "" {
+		*output = base + ".json"
+	}
+	if *reportPath == "" {
+		*reportPath = base + ".report.md"
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	result := parseExamPaper(string(data), markdown)
+	if len(result.Questions) == 0 {
+		return fmt.Errorf("没有在 %s 中识别到题目", source)
+	}
+
+	questions := result.Questions
+	if *skipFlagged {
+		flagged := make(map[string]bool)
+		for _, issue := range result.Issues {
+			flagged[issue.QuestionNumber] = true
+		}
+		questions = []Question{}
+		for _, q := range result.Questions {
+			if !flagged[q.QuestionNumber] {
+				questions = append(questions, q)
+			}
+		}
+	}
+	jsonData, err := json.MarshalIndent(questions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, jsonData, 0644); err != nil {
+		return err
+	}
+	if err := writeImportReport(*reportPath, source, *output, result); err != nil {
+		return err
+	}
+	fmt.Printf("识别 %d 道题，写入 %d 道到 %s；%d 道需要人工确认，详见 %s\n",
+		len(result.Questions), len(questions), *output, result.Flagged, *reportPath)
+	return nil
+}
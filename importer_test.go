@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExamPaperAnswers(t *testing.T) {
+	choices := "一、单项选择题\n" +
+		"1. 第一题（  ）\nA. 甲 B. 乙 C. 丙 D. 丁\n" +
+		"2. 第二题（  ）\nA. 甲 B. 乙 C. 丙 D. 丁\n"
+	judges := "二、判断题\n" +
+		"3. 第三题\n" +
+		"4. 第四题\n"
+
+	tests := []struct {
+		name    string
+		text    string
+		answers map[string]string // 题号 -> 答案
+	}{
+		{
+			name: "每道题后的答案",
+			text: "1. 第一题（  ）\nA. 甲 B. 乙 C. 丙 D. 丁\n答案：C\n" +
+				"2. 第二题（多选）\nA. 甲\nB. 乙\nC. 丙\n【答案】AB\n",
+			answers: map[string]string{"1": "C", "2": "AB"},
+		},
+		{
+			name:    "题干括号中的答案",
+			text:    "1. 第一题（B）\nA. 甲 B. 乙 C. 丙 D. 丁\n",
+			answers: map[string]string{"1": "B"},
+		},
+		{
+			name:    "答案表逐题给出",
+			text:    choices + "参考答案\n1.C 2.B\n",
+			answers: map[string]string{"1": "C", "2": "B"},
+		},
+		{
+			name:    "答案表与标题同一行",
+			text:    choices + "参考答案：1.A 2.D\n",
+			answers: map[string]string{"1": "A", "2": "D"},
+		},
+		{
+			name:    "答案表按范围给出",
+			text:    choices + "参考答案\n1-2 CB\n",
+			answers: map[string]string{"1": "C", "2": "B"},
+		},
+		{
+			name:    "答案表中的判断题",
+			text:    choices + judges + "参考答案\n1-2 CB\n3.对\n4.×\n",
+			answers: map[string]string{"1": "C", "2": "B", "3": "A", "4": "B"},
+		},
+		{
+			name:    "答案表中判断题按范围给出",
+			text:    choices + judges + "参考答案\n1-2 CB\n3-4 错√\n",
+			answers: map[string]string{"1": "C", "2": "B", "3": "B", "4": "A"},
+		},
+		{
+			name:    "答案表中的小节标题",
+			text:    choices + judges + "参考答案\n一、单项选择题\n1-2 AD\n二、判断题\n3.√ 4.错\n",
+			answers: map[string]string{"1": "A", "2": "D", "3": "A", "4": "B"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseExamPaper(tt.text, false)
+			got := make(map[string]string)
+			for _, q := range result.Questions {
+				got[q.QuestionNumber] = q.CorrectAnswer
+			}
+			if !reflect.DeepEqual(got, tt.answers) {
+				t.Errorf("答案: 解析为 %v, 应为 %v", got, tt.answers)
+			}
+			if len(result.Issues) > 0 {
+				t.Errorf("不应有需要确认的问题: %+v", result.Issues)
+			}
+		})
+	}
+}
+
+func TestParseExamPaperKeyCountMismatch(t *testing.T) {
+	result := parseExamPaper("1. 第一题\nA. 甲 B. 乙\n参考答案\n1-3 AB\n", false)
+	if len(result.Issues) == 0 {
+		t.Fatal("答案个数与题号范围不符时应报告问题")
+	}
+}
+
+func TestParseExamPaperMarkdownCheckbox(t *testing.T) {
+	result := parseExamPaper("## 一、单项选择题\n1. 第一题\n- [ ] A. 甲\n- [x] B. 乙\n- [ ] C. 丙\n", true)
+	if len(result.Questions) != 1 || result.Questions[0].CorrectAnswer != "B" {
+		t.Fatalf("勾选的答案应为 B: %+v", result.Questions)
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 学习小组排行榜 ---
+// 在局域网共用一个服务时，按课程和时段（本周 / 全部时间）统计参加排行榜的用户：作答题数、正确率（需达到最少作答次数）、
+// 连续学习天数和清理的错题数。排行榜由 user_data 下所有用户的数据计算，首次请求时计算并缓存，之后由后台定时刷新。
+// 用户默认不参加，可以选择用匿名代号代替用户名（代号只有本人能在设置中看到，排行榜上不会透露对应的用户）。
+
+const (
+	leaderboardPeriodWeek    = "week"
+	leaderboardPeriodAll     = "all"
+	leaderboardSize          = 20              // 每个排行榜最多列出的人数
+	leaderboardMinAttempts   = 20              // 进入正确率榜所需的最少作答次数
+	leaderboardRefreshPeriod = 5 * time.Minute // 后台多久重新计算一次缓存的排行榜
+	leaderboardAliasAttempts = 100             // 生成不重复的匿名代号时最多尝试的次数
+)
+
+var (
+	leaderboardMu         sync.Mutex
+	leaderboardCache      = make(map[string]LeaderboardResult) // 课程/时段 -> 最近一次计算的排行榜
+	leaderboardGeneration uint64                               // 每次清空缓存时加一，清空前开始的计算结果不再写入缓存
+
+	leaderboardAliasMu sync.Mutex // 生成匿名代号时加锁，避免两名用户同时拿到相同的代号
+)
+
+// loadLeaderboardSettings 加载用户的排行榜设置，未设置时为不参加
+func loadLeaderboardSettings(userID string) (LeaderboardSettings, error) {
+	var settings LeaderboardSettings
+	if err := loadUserJSONData(userID, leaderboardSettingsFile, &settings); err != nil {
+		return settings, &userDataError{Message: "加载排行榜设置失败", Err: err}
+	}
+	return settings, nil
+}
+
+// updateLeaderboardSettings 修改用户的排行榜设置，首次匿名时生成匿名代号。修改后清空缓存，使设置立即生效。
+func updateLeaderboardSettings(ctx context.Context, req LeaderboardSettingsRequest) (LeaderboardSettings, error) {
+	settings, err := loadLeaderboardSettings(req.UserID)
+	if err != nil {
+		return settings, err
+	}
+	if req.OptIn == nil && req.HideName == nil {
+		return settings, nil
+	}
+	if req.OptIn != nil {
+		settings.OptIn = *req.OptIn
+	}
+	if req.HideName != nil {
+		settings.HideName = *req.HideName
+	}
+	if settings.HideName && settings.Alias == "" {
+		leaderboardAliasMu.Lock()
+		defer leaderboardAliasMu.Unlock()
+		alias, err := newLeaderboardAlias(req.UserID)
+		if err != nil {
+			return settings, err
+		}
+		settings.Alias = alias
+	}
+	if err := saveUserJSONData(req.UserID, leaderboardSettingsFile, settings); err != nil {
+		return settings, &userDataError{Message: "保存排行榜设置失败", Err: err}
+	}
+	slog.InfoContext(ctx, "修改排行榜设置", logKeyUserID, req.UserID, "opt_in", settings.OptIn, "hide_name", settings.HideName)
+
+	leaderboardMu.Lock()
+	clear(leaderboardCache)
+	leaderboardGeneration++
+	leaderboardMu.Unlock()
+	return settings, nil
+}
+
+// newLeaderboardAlias 随机生成一个其他用户没有用过、也不是用户名的匿名代号。调用方需持有 leaderboardAliasMu。
+func newLeaderboardAlias(userID string) (string, error) {
+	used := make(map[string]bool)
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", &userDataError{Message: "读取用户数据目录失败", Err: err}
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		used[entry.Name()] = true
+		if entry.Name() == userID {
+			continue
+		}
+		settings, err := loadLeaderboardSettings(entry.Name())
+		if err != nil {
+			return "", err
+		}
+		if settings.Alias != "" {
+			used[settings.Alias] = true
+		}
+	}
+	for range leaderboardAliasAttempts {
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", fmt.Errorf("生成匿名代号失败: %w", err)
+		}
+		alias := fmt.Sprintf("匿名喵 #%04d", n.Int64())
+		if !used[alias] {
+			return alias, nil
+		}
+	}
+	return "", fmt.Errorf("生成匿名代号失败: 尝试 %d 次都与已有代号重复", leaderboardAliasAttempts)
+}
+
+// weekStart 返回 now 所在周的周一零点（按学习日的时区）
+func weekStart(now time.Time) time.Time {
+	local := now.In(studyLocation)
+	daysSinceMonday := (int(local.Weekday()) + 6) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-daysSinceMonday, 0, 0, 0, 0, studyLocation)
+}
+
+// leaderboardMetrics 一名用户在一门课程一个时段内的各项指标
+type leaderboardMetrics struct {
+	answered int
+	correct  int
+	streak   int
+	cleared  int
+}
+
+// computeLeaderboardMetrics 计算一名用户的各项指标。本周的作答数来自学习记录，全部时间的来自作答统计；
+// since 为零值表示全部时间。
+func computeLeaderboardMetrics(userID string, courses map[string]bool, scope []Question, since, now time.Time) (leaderboardMetrics, error) {
+	var metrics leaderboardMetrics
+	if since.IsZero() {
+		userStats, err := loadUserStats(userID)
+		if err != nil {
+			return metrics, err
+		}
+		for _, q := range scope {
+			stat, _ := userStatFor(userStats, q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+			metrics.answered += stat.CorrectCount + stat.ErrorCount
+			metrics.correct += stat.CorrectCount
+		}
+	} else {
+		slots := make(map[string]StudyActivitySlot)
+		if err := loadUserJSONData(userID, studyActivityFile, &slots); err != nil {
+			return metrics, &userDataError{Message: "加载用户学习记录失败", Err: err}
+		}
+		for key, slot := range slots {
+			start, err := time.Parse(activitySlotLayout, key)
+			if err != nil || start.Before(since) {
+				continue
+			}
+			for course, count := range slot.Courses {
+				if courses[course] {
+					metrics.answered += count.Answers
+					metrics.correct += count.Correct
+				}
+			}
+		}
+	}
+
+	activity, err := computeStudyActivity(userID, now)
+	if err != nil {
+		return metrics, err
+	}
+	metrics.streak = activity.CurrentStreak
+
+	var deleted []UserIncorrectQuestion
+	if err := loadUserJSONData(userID, deleteIncorrectQuestionsFile, &deleted); err != nil {
+		return metrics, &userDataError{Message: "加载已删除错题历史失败", Err: err}
+	}
+	for _, iq := range deleted {
+		if courses[iq.OriginalCourse] && !iq.DeletedAt.Before(since) {
+			metrics.cleared++
+		}
+	}
+	return metrics, nil
+}
+
+// computeLeaderboard 计算一门课程（为空表示全部课程）一个时段的排行榜
+func computeLeaderboard(course, period string, now time.Time) (LeaderboardResult, error) {
+	result := LeaderboardResult{Course: course, Period: period, MinAttempts: leaderboardMinAttempts, GeneratedAt: now}
+	var since time.Time
+	if period == leaderboardPeriodWeek {
+		since = weekStart(now)
+		result.PeriodStart = &since
+	}
+	scope, err := questionsInScope(course)
+	if err != nil {
+		return result, err
+	}
+	courses := make(map[string]bool)
+	if course == "" {
+		for _, physical := range physicalCourses() {
+			courses[physical] = true
+		}
+	} else {
+		for _, member := range resolveCourseMembers(course) {
+			courses[member.Course] = true
+		}
+	}
+
+	boards := []Leaderboard{
+		{Metric: "answered", Title: "作答题数", Entries: []LeaderboardEntry{}},
+		{Metric: "accuracy", Title: fmt.Sprintf("正确率（至少 %d 次作答）", leaderboardMinAttempts), Entries: []LeaderboardEntry{}},
+		{Metric: "streak", Title: "连续学习天数", Entries: []LeaderboardEntry{}},
+		{Metric: "cleared", Title: "清理错题数", Entries: []LeaderboardEntry{}},
+	}
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return result, &userDataError{Message: "读取用户数据目录失败", Err: err}
+	}
+	names := make(map[string]bool) // 本次排行榜已使用的名字，用户名也算在内，避免代号与用户名或旧数据中的代号重复
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names[entry.Name()] = true
+		}
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		settings, err := loadLeaderboardSettings(userID)
+		if err != nil {
+			slog.Warn("读取排行榜设置失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		if !settings.OptIn {
+			continue
+		}
+		metrics, err := computeLeaderboardMetrics(userID, courses, scope, since, now)
+		if err != nil {
+			slog.Warn("计算排行榜数据失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		result.Participants++
+
+		name := userID
+		if settings.HideName {
+			name = uniqueLeaderboardName(settings.Alias, names) // 匿名用户只显示代号，不会出现用户名
+		}
+		add := func(board *Leaderboard, value float64, attempts int) {
+			board.Entries = append(board.Entries, LeaderboardEntry{Name: name, Value: value, Attempts: attempts})
+		}
+		if metrics.answered > 0 {
+			add(&boards[0], float64(metrics.answered), 0)
+		}
+		if metrics.answered >= leaderboardMinAttempts {
+			add(&boards[1], float64(metrics.correct)/float64(metrics.answered), metrics.answered)
+		}
+		if metrics.streak > 0 {
+			add(&boards[2], float64(metrics.streak), 0)
+		}
+		if metrics.cleared > 0 {
+			add(&boards[3], float64(metrics.cleared), 0)
+		}
+	}
+
+	for i := range boards {
+		rankLeaderboardEntries(boards[i].Entries)
+		if len(boards[i].Entries) > leaderboardSize {
+			boards[i].Entries = boards[i].Entries[:leaderboardSize]
+		}
+	}
+	result.Boards = boards
+	return result, nil
+}
+
+// uniqueLeaderboardName 返回本次排行榜中没有用过的匿名名字：代号已被占用时加上序号区分
+func uniqueLeaderboardName(alias string, names map[string]bool) string {
+	if alias == "" {
+		alias = "匿名喵"
+	}
+	name := alias
+	for i := 2; names[name]; i++ {
+		name = fmt.Sprintf("%s (%d)", alias, i)
+	}
+	names[name] = true
+	return name
+}
+
+// rankLeaderboardEntries 按数值从高到低排序并排名，数值相同的名次相同
+func rankLeaderboardEntries(entries []LeaderboardEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
+
+// getLeaderboard 返回缓存的排行榜；尚未缓存的课程和时段在本次请求中计算，之后由后台定时刷新
+func getLeaderboard(course, period string) (LeaderboardResult, error) {
+	if course != "" {
+		if _, ok := courseCatalog[course]; !ok {
+			return LeaderboardResult{}, newAppError(codeUnknownCourse, course)
+		}
+	}
+	switch period {
+	case "":
+		period = leaderboardPeriodWeek
+	case leaderboardPeriodWeek, leaderboardPeriodAll:
+	default:
+		return LeaderboardResult{}, newOptionError("period", period, "week", "all")
+	}
+
+	leaderboardMu.Lock()
+	cached, ok := leaderboardCache[course+"/"+period]
+	leaderboardMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+	return refreshLeaderboard(course, period)
+}
+
+// refreshLeaderboard 重新计算一门课程一个时段的排行榜并写入缓存。计算时不持有 leaderboardMu，
+// 计算期间缓存被清空（有用户修改了设置）时结果只返回，不写入缓存。
+func refreshLeaderboard(course, period string) (LeaderboardResult, error) {
+	leaderboardMu.Lock()
+	generation := leaderboardGeneration
+	leaderboardMu.Unlock()
+
+	result, err := computeLeaderboard(course, period, time.Now())
+	if err != nil {
+		return result, err
+	}
+	key := course + "/" + period
+	leaderboardMu.Lock()
+	if generation == leaderboardGeneration {
+		leaderboardCache[key] = result
+	}
+	leaderboardMu.Unlock()
+	slog.Info("排行榜已重新计算", "key", key, "participants", result.Participants)
+	return result, nil
+}
+
+// refreshLeaderboards 重新计算所有已缓存的排行榜
+func refreshLeaderboards() {
+	leaderboardMu.Lock()
+	cached := make([]LeaderboardResult, 0, len(leaderboardCache))
+	for _, result := range leaderboardCache {
+		cached = append(cached, result)
+	}
+	leaderboardMu.Unlock()
+
+	for _, result := range cached {
+		if _, err := refreshLeaderboard(result.Course, result.Period); err != nil {
+			slog.Warn("刷新排行榜失败", "course", result.Course, "period", result.Period, "error", err)
+		}
+	}
+}
+
+// startLeaderboardRefresher 启动后台定时刷新排行榜，开始退出时停止
+func startLeaderboardRefresher(background *sync.WaitGroup) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(leaderboardRefreshPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refreshLeaderboards()
+			case <-stopping:
+				return
+			}
+		}
+	}()
+}
+
+// LeaderboardHandler 返回一门课程本周或全部时间的排行榜 (?course=&period=week|all)
+func LeaderboardHandler(ctx context.Context, c *app.RequestContext) {
+	var req LeaderboardRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	result, err := getLeaderboard(req.Course, req.Period)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, result)
+}
+
+// LeaderboardSettingsHandler 获取或修改用户的排行榜设置（是否参加、是否匿名）
+func LeaderboardSettingsHandler(ctx context.Context, c *app.RequestContext) {
+	var req LeaderboardSettingsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	settings, err := updateLeaderboardSettings(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, settings)
+}
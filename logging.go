@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// --- 日志 ---
+// 日志统一使用 log/slog，用户ID一律放在 user_id 属性中，隐私模式下由 redactUserID 替换为化名。
+// 处理请求时产生的日志应使用带 Context 的方法（如 slog.InfoContext），以便带上请求ID。
+
+// 日志格式
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevels 配置的日志级别
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// hertzLogLevels 配置的日志级别对应的 Hertz 框架日志级别
+var hertzLogLevels = map[string]hlog.Level{
+	"debug": hlog.LevelDebug,
+	"info":  hlog.LevelInfo,
+	"warn":  hlog.LevelWarn,
+	"error": hlog.LevelError,
+}
+
+// 日志属性名
+const (
+	logKeyUserID    = "user_id"
+	logKeyRequestID = "request_id"
+)
+
+// requestIDHeader 请求ID所在的请求头和响应头，客户端或反向代理传入时沿用，否则由服务端生成
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 沿用客户端传入的请求ID时允许的最大长度
+const maxRequestIDLength = 64
+
+// requestIDKey 请求ID在 context 中的键
+type requestIDKey struct{}
+
+// pseudonymKey 隐私模式下计算用户化名的密钥，每次启动随机生成，因此化名只在本次运行内一致
+var pseudonymKey = newPseudonymKey()
+
+func newPseudonymKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("生成日志化名密钥失败: " + err.Error())
+	}
+	return key
+}
+
+// setupLogging 按配置设置默认的 slog 日志，并把标准库 log 和 Hertz 框架的日志接入同一输出
+func setupLogging(cfg Config) {
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, cfg)))
+	// 尚未改用 slog 的日志（如第三方库经由标准库 log 输出的）按信息级别记录
+	log.SetFlags(0)
+	log.SetOutput(&slogWriter{level: slog.LevelInfo, component: "log"})
+	hlog.SetOutput(&slogWriter{level: slog.LevelInfo, component: "hertz"})
+	hlog.SetLevel(hertzLogLevels[cfg.LogLevel])
+}
+
+// newLogHandler 创建写入 w 的日志处理器：按配置选择文本或 JSON 格式，附加请求ID，隐私模式下替换用户ID
+func newLogHandler(w io.Writer, cfg Config) slog.Handler {
+	opts := &slog.HandlerOptions{Level: logLevels[cfg.LogLevel]}
+	if cfg.PrivacyMode {
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			switch {
+			case a.Key == logKeyUserID:
+				return slog.String(logKeyUserID, redactUserID(a.Value.String()))
+			case a.Value.Kind() == slog.KindString:
+				return slog.String(a.Key, redactUserPaths(a.Value.String()))
+			}
+			if err, ok := a.Value.Any().(error); ok {
+				// 读写用户数据失败的错误信息中带有用户目录
+				return slog.String(a.Key, redactUserPaths(err.Error()))
+			}
+			return a
+		}
+	}
+	var handler slog.Handler
+	if cfg.LogFormat == logFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return requestIDHandler{handler}
+}
+
+// redactUserID 把用户ID替换为本次运行内一致的化名，同一用户的日志仍可关联，但不能反推出用户ID
+func redactUserID(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, pseudonymKey)
+	mac.Write([]byte(userID))
+	return "user-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// redactUserPaths 把文本中用户数据目录下的用户目录名替换为化名
+func redactUserPaths(s string) string {
+	if userDataBaseDir == "" {
+		return s
+	}
+	prefix := userDataBaseDir + string(filepath.Separator)
+	var b strings.Builder
+	for {
+		i := strings.Index(s, prefix)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i+len(prefix)])
+		s = s[i+len(prefix):]
+		end := strings.IndexAny(s, string(filepath.Separator)+" :\"")
+		if end < 0 {
+			end = len(s)
+		}
+		b.WriteString(redactUserID(s[:end]))
+		s = s[end:]
+	}
+}
+
+// requestIDHandler 为带有请求ID的 context 产生的日志附加 request_id 属性
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(logKeyRequestID, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// requestIDFromContext 返回 context 中的请求ID，不在请求中时为空
+func requestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID 生成一个随机的请求ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware 为每个请求分配请求ID：写入响应头，放入传给后续处理函数的 context，
+// 处理完成后以调试级别记录一行访问日志
+func requestIDMiddleware(ctx context.Context, c *app.RequestContext) {
+	id := string(c.GetHeader(requestIDHeader))
+	if id == "" || len(id) > maxRequestIDLength {
+		id = newRequestID()
+	}
+	c.Response.Header.Set(requestIDHeader, id)
+	c.Set(logKeyRequestID, id) // 供只拿得到 RequestContext 的错误处理使用
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+
+	c.Next(ctx)
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	slog.DebugContext(ctx, "请求完成", "method", string(c.Method()), "route", route, "status", c.Response.StatusCode())
+}
+
+// requestContext 返回带有请求ID的 context，用于只拿得到 RequestContext 的地方
+func requestContext(c *app.RequestContext) context.Context {
+	ctx := context.Background()
+	if id := c.GetString(logKeyRequestID); id != "" {
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+	}
+	return ctx
+}
+
+// slogWriter 把标准库 log 或 Hertz 框架写出的日志行转为 slog 记录
+type slogWriter struct {
+	level     slog.Level
+	component string
+}
+
+func (w *slogWriter) Write(p []byte) (int, error) {
+	line := string(bytes.TrimRight(p, "\n"))
+	level := w.level
+	// Hertz 的日志形如 "2024/01/01 12:00:00.000000 engine.go:416: [Info] HERTZ: ..."
+	if w.component == "hertz" {
+		for tag, tagLevel := range map[string]slog.Level{"[Debug]": slog.LevelDebug, "[Trace]": slog.LevelDebug, "[Warn]": slog.LevelWarn, "[Error]": slog.LevelError, "[Fatal]": slog.LevelError} {
+			if strings.Contains(line, tag) {
+				level = tagLevel
+				break
+			}
+		}
+		if _, msg, ok := strings.Cut(line, "HERTZ: "); ok {
+			line = msg
+		}
+	}
+	slog.Log(context.Background(), level, line, "component", w.component)
+	return len(p), nil
+}
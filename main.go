@@ -3,17 +3,21 @@ package main
 import (
 	"context"
 	"embed"
-	"log"
+	"flag"
+	"log/slog"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/route"
 )
 
-//go:embed clean_outputs/* quiz.html
+//go:embed clean_outputs/* quiz.html worksheet.html
 var embeddedFS embed.FS
 
 // openBrowser 在服务器启动后打开浏览器
@@ -39,10 +43,10 @@ func openBrowser(url string) {
 		}
 	}
 
-	log.Printf("正在尝试打开浏览器: %s %v", cmd, args)
+	slog.Info("正在尝试打开浏览器", "cmd", cmd, "args", args)
 	err := exec.Command(cmd, args...).Start()
 	if err != nil {
-		log.Printf("打开浏览器失败: %v", err)
+		slog.Warn("打开浏览器失败", "error", err)
 	}
 }
 
@@ -57,8 +61,37 @@ func isWSL() bool {
 
 // main函数，程序入口
 func main() {
-	// 使用默认配置初始化 Hertz 服务器，监听在 0.0.0.0:8899
-	h := server.Default(server.WithHostPorts("0.0.0.0:8899"))
+	runCLI(os.Args[1:])
+}
+
+// runServe 启动网页服务 (serve 子命令，也是不带子命令时的默认行为)
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cf := registerConfigFlags(fs)
+	fs.Parse(args)
+	cfg, err := cf.loadConfig(fs)
+	if err != nil {
+		slog.Error("加载配置失败", "error", err)
+		os.Exit(1)
+	}
+	applyConfig(cfg)
+	logEffectiveConfig(cfg)
+	initializeApp()
+	if restored := restoreSessions(); restored > 0 {
+		slog.Info("恢复了上次退出时保存的会话", "sessions", restored)
+	}
+
+	// 按配置的地址初始化 Hertz 服务器。收到 SIGINT / SIGTERM 后停止接收新连接，最多等待 shutdown_timeout 让处理中的请求完成
+	h := server.Default(server.WithHostPorts(cfg.listenAddress()), server.WithExitWaitTime(cfg.shutdownTimeout()))
+	h.Use(requestIDMiddleware, trackActiveRequests, metricsMiddleware) // 需在注册路由前添加，才能作用于全部路由
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) { beginShutdown() })
+
+	// GET /healthz - 存活检查
+	h.GET("/healthz", HealthzHandler)
+	// GET /readyz - 就绪检查：题库加载情况和数据目录是否可写
+	h.GET("/readyz", ReadyzHandler)
+	// GET /metrics - Prometheus 文本格式的监控指标
+	h.GET("/metrics", MetricsHandler)
 
 	// 提供静态文件服务，使用嵌入的文件系统
 	h.GET("/", func(ctx context.Context, c *app.RequestContext) {
@@ -73,6 +106,9 @@ func main() {
 	// API 路由组
 	apiGroup := h.Group("/api")
 	{
+		// GET /api/courses - 获取课程、章节及题目数量
+		apiGroup.GET("/courses", CoursesListHandler)
+
 		sessionGroup := apiGroup.Group("/session")
 		{
 			// POST /api/session/init - 初始化用户会话 (现在需要 userID)
@@ -91,6 +127,8 @@ func main() {
 		{
 			// POST /api/quiz/start - 开始答题
 			quizGroup.POST("/start", QuizStartHandler)
+			// POST /api/quiz/serve - 记录一道题的出题时间（用于计算作答用时）
+			quizGroup.POST("/serve", ServeQuestionHandler)
 			// POST /api/quiz/submit_answer - 提交答案
 			quizGroup.POST("/submit_answer", SubmitAnswerHandler)
 		}
@@ -105,20 +143,166 @@ func main() {
 			incorrectGroup.POST("/delete", DeleteIncorrectQuestionHandler)
 		}
 
+		if cfg.Features.Tags {
+			// GET /api/tags - 获取知识点标签及题目数 (?course=)
+			apiGroup.GET("/tags", TagsListHandler)
+		}
+
+		// GET /api/questions/difficulty - 按全局难度或区分度列出题目 (?course=&sort=hardest|easiest|discrimination&limit=)
+		apiGroup.GET("/questions/difficulty", QuestionDifficultyHandler)
+
+		if cfg.Features.Reports {
+			questionsGroup := apiGroup.Group("/questions") // 题目反馈
+			{
+				// POST /api/questions/report - 报告题目有误
+				questionsGroup.POST("/report", ReportQuestionHandler)
+			}
+		}
+
+		if cfg.Features.Notes {
+			notesGroup := apiGroup.Group("/notes") // 个人笔记与收藏
+			{
+				// POST /api/notes/list - 获取用户的全部笔记与收藏
+				notesGroup.POST("/list", UserNotesListHandler)
+				// POST /api/notes/upsert - 新增或修改一道题的笔记/收藏
+				notesGroup.POST("/upsert", UpsertNoteHandler)
+				// POST /api/notes/delete - 删除一道题的笔记与收藏
+				notesGroup.POST("/delete", DeleteNoteHandler)
+			}
+		}
+
+		if cfg.Features.Leaderboard {
+			leaderboardGroup := apiGroup.Group("/leaderboard") // 学习小组排行榜
+			{
+				// GET /api/leaderboard - 获取课程本周或全部时间的排行榜 (?course=&period=week|all)
+				leaderboardGroup.GET("", LeaderboardHandler)
+				// POST /api/leaderboard/settings - 获取或修改是否参加排行榜、是否匿名
+				leaderboardGroup.POST("/settings", LeaderboardSettingsHandler)
+			}
+		}
+
+		if cfg.Features.Assignments {
+			assignmentsGroup := apiGroup.Group("/assignments") // 学生做作业
+			{
+				// POST /api/assignments/list - 获取全部作业及自己的进度
+				assignmentsGroup.POST("/list", StudentAssignmentsHandler)
+				// POST /api/assignments/start - 以答题模式开始做作业
+				assignmentsGroup.POST("/start", StartAssignmentHandler)
+			}
+			teacherGroup := apiGroup.Group("/teacher") // 老师布置作业、查看结果（需要老师权限）
+			{
+				// GET /api/teacher/assignments - 列出作业及完成人数
+				teacherGroup.GET("/assignments", TeacherAssignmentsListHandler)
+				// POST /api/teacher/assignments - 布置作业
+				teacherGroup.POST("/assignments", CreateAssignmentHandler)
+				// POST /api/teacher/assignments/delete - 删除作业
+				teacherGroup.POST("/assignments/delete", DeleteAssignmentHandler)
+				// GET /api/teacher/assignments/results - 作业完成情况 (?id=&format=json|csv&table=students|missed)
+				teacherGroup.GET("/assignments/results", AssignmentResultsHandler)
+			}
+		}
+
+		if cfg.Features.Battles {
+			battlesGroup := apiGroup.Group("/battles") // 实时对战
+			{
+				// POST /api/battles/create - 创建对战房间
+				battlesGroup.POST("/create", CreateBattleHandler)
+				// POST /api/battles/join - 凭房间码加入对战
+				battlesGroup.POST("/join", JoinBattleHandler)
+				// POST /api/battles/start - 房主开始对战
+				battlesGroup.POST("/start", StartBattleHandler)
+				// POST /api/battles/answer - 提交当前题目的答案
+				battlesGroup.POST("/answer", BattleAnswerHandler)
+				// GET /api/battles/events - 订阅房间事件 (SSE, ?code=&user_id=)
+				battlesGroup.GET("/events", BattleEventsHandler)
+				// POST /api/battles/history - 获取用户的对战记录
+				battlesGroup.POST("/history", BattleHistoryHandler)
+			}
+		}
+
+		exportGroup := apiGroup.Group("/export") // 导出题目
+		{
+			// GET /api/export/anki - 导出 Anki 可导入的笔记 (?course=&chapter_choice=&user_id=)
+			exportGroup.GET("/anki", AnkiExportHandler)
+			// GET /api/export/worksheet - 生成可打印的 HTML 试卷 (?course=&chapter_choice=&question_type=&count=&order_choice=&seed=&answer_key=)
+			exportGroup.GET("/worksheet", WorksheetExportHandler)
+		}
+
+		if cfg.Features.Admin {
+			registerAdminRoutes(apiGroup.Group("/admin"), cfg.Features) // 题库维护（需要管理权限）
+		}
+
 		userGroup := apiGroup.Group("/user") // 用户数据管理
 		{
 			// POST /api/user/data/clear - 清理用户数据
 			userGroup.POST("/data/clear", UserDataClearHandler)
+			// POST /api/user/stats - 按课程和章节汇总答题统计
+			userGroup.POST("/stats", UserStatsHandler)
+			// POST /api/user/speed_stats - 按题型和章节统计作答用时，列出答得慢的题
+			userGroup.POST("/speed_stats", SpeedStatsHandler)
+			// POST /api/user/activity - 过去一年的学习日历、今日进度和连续学习天数
+			userGroup.POST("/activity", StudyActivityHandler)
+			// POST /api/user/goals - 获取每日学习目标
+			userGroup.POST("/goals", StudyGoalsHandler)
+			// POST /api/user/goals/update - 修改每日学习目标（题数、分钟数）
+			userGroup.POST("/goals/update", UpdateStudyGoalsHandler)
+			if cfg.Features.Tags {
+				// POST /api/user/tag_stats - 按知识点标签统计正确率
+				userGroup.POST("/tag_stats", TagStatsHandler)
+			}
 		}
 	}
 
-	log.Println("喵喵学习小助手 Go 后端已启动，监听于 http://0.0.0.0:8899")
+	// /api/v1 - 版本化接口：统一的错误信封，只读操作使用 GET，文档见 /api/v1/openapi.json
+	registerAPIV1Routes(h.Group("/api/v1"), cfg.Features)
+	h.NoRoute(apiV1NoRoute)
+
+	slog.Info("喵喵学习小助手 Go 后端已启动", "url", "http://"+cfg.listenAddress())
+
+	var background sync.WaitGroup // 退出前需要等待的后台任务
+	startGlobalStatsFlusher(&background)
+	if cfg.Features.Leaderboard {
+		startLeaderboardRefresher(&background)
+	}
+	if !cfg.NoBrowser {
+		// 启动goroutine在服务器启动后打开浏览器，启动后立即退出时不再打开
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			select {
+			case <-time.After(1 * time.Second): // 等待服务器启动
+				openBrowser(cfg.browserURL())
+			case <-stopping:
+			}
+		}()
+	}
 
-	// 启动goroutine在服务器启动后打开浏览器
-	go func() {
-		time.Sleep(1 * time.Second) // 等待服务器启动
-		openBrowser("http://localhost:8899")
-	}()
+	h.Spin() // 启动服务器并开始监听请求，收到退出信号或启动失败时返回
+	finishShutdown(cfg.shutdownTimeout(), &background)
+}
 
-	h.Spin() // 启动服务器并开始监听请求
+// registerAdminRoutes 注册题库维护接口，已关闭功能的接口不注册
+func registerAdminRoutes(adminGroup *route.RouterGroup, features FeatureToggles) {
+	// GET /api/admin/bank/validate - 校验题库 (?course=)
+	adminGroup.GET("/bank/validate", BankValidateHandler)
+	// POST /api/admin/bank/upload - 校验 CSV / TSV 题库并安装为外部题库 (?course=&format=&dry_run=)
+	adminGroup.POST("/bank/upload", BankUploadHandler)
+	// POST /api/admin/explanations - 新增、修改或删除题目拆解
+	adminGroup.POST("/explanations", UpsertExplanationHandler)
+	if features.Reports {
+		// GET /api/admin/reports - 按题目汇总纠错报告 (?status=open|resolved|dismissed|all)
+		adminGroup.GET("/reports", ReportsListHandler)
+		// POST /api/admin/reports/resolve - 将一道题的报告标记为已解决或驳回
+		adminGroup.POST("/reports/resolve", ResolveReportsHandler)
+	}
+	// POST /api/admin/corrections - 以旁路文件修正题目
+	adminGroup.POST("/corrections", ApplyCorrectionHandler)
+	// POST /api/admin/corrections/delete - 撤销题目修正
+	adminGroup.POST("/corrections/delete", DeleteCorrectionHandler)
+	if features.Tags {
+		// POST /api/admin/tags - 设置一道题的知识点标签
+		adminGroup.POST("/tags", SetTagsHandler)
+		// POST /api/admin/tags/suggest - 按关键词规则推荐标签 (apply=true 时保存)
+		adminGroup.POST("/tags/suggest", SuggestTagsHandler)
+	}
 }
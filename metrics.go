@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// requestDurationBuckets 请求耗时直方图的上界（秒）
+var requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sessionActiveWindow 最近多久内使用过的会话计为活跃会话
+const sessionActiveWindow = 30 * time.Minute
+
+// 存储写入失败的分类
+const (
+	storeUserData = "user_data" // 用户数据目录
+	storeOverlay  = "overlay"   // 旁路文件目录
+)
+
+// requestKey 按接口统计请求数的标签
+type requestKey struct {
+	Method string
+	Route  string // 注册的路由，如 /api/v1/users/:user_id/stats；没有匹配的路由为 unmatched
+	Status int
+}
+
+// routeKey 按接口统计耗时的标签
+type routeKey struct {
+	Method string
+	Route  string
+}
+
+// durationHistogram 一个接口的耗时直方图，Counts 与 requestDurationBuckets 一一对应（不累计）
+type durationHistogram struct {
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+// serverMetrics 进程启动以来的计数，只保存在内存中
+var serverMetrics = struct {
+	mu                   sync.Mutex
+	requests             map[requestKey]uint64
+	durations            map[routeKey]*durationHistogram
+	answersSubmitted     map[string]uint64 // 课程 -> 提交的答案数
+	storageWriteFailures map[string]uint64 // 存储分类 -> 写入失败次数
+}{
+	requests:             make(map[requestKey]uint64),
+	durations:            make(map[routeKey]*durationHistogram),
+	answersSubmitted:     make(map[string]uint64),
+	storageWriteFailures: make(map[string]uint64),
+}
+
+// metricsMiddleware 记录每个请求的接口、状态码和耗时。SSE 等长连接在断开时才计入。
+func metricsMiddleware(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	c.Next(ctx)
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched" // 不按原始路径统计，避免随意的路径撑大标签
+	}
+	observeRequest(string(c.Method()), route, c.Response.StatusCode(), time.Since(start))
+}
+
+// observeRequest 记录一次请求
+func observeRequest(method, route string, status int, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	serverMetrics.mu.Lock()
+	defer serverMetrics.mu.Unlock()
+	serverMetrics.requests[requestKey{Method: method, Route: route, Status: status}]++
+	key := routeKey{Method: method, Route: route}
+	hist, ok := serverMetrics.durations[key]
+	if !ok {
+		hist = &durationHistogram{Counts: make([]uint64, len(requestDurationBuckets))}
+		serverMetrics.durations[key] = hist
+	}
+	for i, bound := range requestDurationBuckets {
+		if seconds <= bound {
+			hist.Counts[i]++
+			break
+		}
+	}
+	hist.Sum += seconds
+	hist.Count++
+}
+
+// countAnswerSubmitted 记录一次提交的答案
+func countAnswerSubmitted(course string) {
+	serverMetrics.mu.Lock()
+	serverMetrics.answersSubmitted[course]++
+	serverMetrics.mu.Unlock()
+}
+
+// countStorageWriteFailure 记录一次存储写入失败
+func countStorageWriteFailure(store string) {
+	serverMetrics.mu.Lock()
+	serverMetrics.storageWriteFailures[store]++
+	serverMetrics.mu.Unlock()
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出监控指标
+func MetricsHandler(ctx context.Context, c *app.RequestContext) {
+	c.Data(consts.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(renderMetrics()))
+}
+
+// renderMetrics 生成 Prometheus 文本格式的全部指标，同一指标的样本按标签排序
+func renderMetrics() string {
+	var b strings.Builder
+
+	serverMetrics.mu.Lock()
+	requestKeys := make([]requestKey, 0, len(serverMetrics.requests))
+	for key := range serverMetrics.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		x, y := requestKeys[i], requestKeys[j]
+		if x.Route != y.Route {
+			return x.Route < y.Route
+		}
+		if x.Method != y.Method {
+			return x.Method < y.Method
+		}
+		return x.Status < y.Status
+	})
+	writeMetricHeader(&b, "quiz_http_requests_total", "counter", "按接口和状态码统计的请求数")
+	for _, key := range requestKeys {
+		writeSample(&b, "quiz_http_requests_total", serverMetrics.requests[key],
+			"method", key.Method, "route", key.Route, "status", strconv.Itoa(key.Status))
+	}
+
+	routeKeys := make([]routeKey, 0, len(serverMetrics.durations))
+	for key := range serverMetrics.durations {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		if routeKeys[i].Route != routeKeys[j].Route {
+			return routeKeys[i].Route < routeKeys[j].Route
+		}
+		return routeKeys[i].Method < routeKeys[j].Method
+	})
+	writeMetricHeader(&b, "quiz_http_request_duration_seconds", "histogram", "按接口统计的请求耗时")
+	for _, key := range routeKeys {
+		hist := serverMetrics.durations[key]
+		var cumulative uint64
+		for i, bound := range requestDurationBuckets {
+			cumulative += hist.Counts[i]
+			writeSample(&b, "quiz_http_request_duration_seconds_bucket", cumulative,
+				"method", key.Method, "route", key.Route, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+		}
+		writeSample(&b, "quiz_http_request_duration_seconds_bucket", hist.Count, "method", key.Method, "route", key.Route, "le", "+Inf")
+		writeSample(&b, "quiz_http_request_duration_seconds_sum", hist.Sum, "method", key.Method, "route", key.Route)
+		writeSample(&b, "quiz_http_request_duration_seconds_count", hist.Count, "method", key.Method, "route", key.Route)
+	}
+
+	writeMetricHeader(&b, "quiz_answers_submitted_total", "counter", "按题目来源课程统计的提交答案数（答题、对战和错题回顾）")
+	for _, course := range sortedKeys(serverMetrics.answersSubmitted) {
+		writeSample(&b, "quiz_answers_submitted_total", serverMetrics.answersSubmitted[course], "course", course)
+	}
+
+	writeMetricHeader(&b, "quiz_storage_write_failures_total", "counter", "写入用户数据或旁路文件失败的次数")
+	for _, store := range []string{storeUserData, storeOverlay} {
+		writeSample(&b, "quiz_storage_write_failures_total", serverMetrics.storageWriteFailures[store], "store", store)
+	}
+	serverMetrics.mu.Unlock()
+
+	activeSince := time.Now().Add(-sessionActiveWindow).UnixNano()
+	activeSessions := 0
+	sessionsMu.RLock()
+	for _, session := range userSessions {
+		if session.lastActive.Load() >= activeSince {
+			activeSessions++
+		}
+	}
+	sessionsMu.RUnlock()
+	writeMetricHeader(&b, "quiz_active_sessions", "gauge", "最近 30 分钟内有请求的用户会话数")
+	writeSample(&b, "quiz_active_sessions", activeSessions)
+
+	battleRoomsMu.Lock()
+	rooms := make([]*battleRoom, 0, len(battleRooms))
+	for _, room := range battleRooms {
+		rooms = append(rooms, room)
+	}
+	battleRoomsMu.Unlock()
+	roomsByState := map[string]int{battleStateWaiting: 0, battleStatePlaying: 0, battleStateFinished: 0}
+	for _, room := range rooms {
+		room.mu.Lock()
+		roomsByState[room.state]++
+		room.mu.Unlock()
+	}
+	writeMetricHeader(&b, "quiz_battle_rooms", "gauge", "按状态统计的对战房间数，state 为 waiting（等待开始）、playing（进行中）或 finished（已结束，等待清理）")
+	for _, state := range []string{battleStateWaiting, battleStatePlaying, battleStateFinished} {
+		writeSample(&b, "quiz_battle_rooms", roomsByState[state], "state", state)
+	}
+
+	writeMetricHeader(&b, "quiz_bank_questions", "gauge", "各课程题库的题目数")
+	for _, course := range courseOrder {
+		if meta, ok := courseCatalog[course]; ok {
+			writeSample(&b, "quiz_bank_questions", meta.QuestionCount, "course", course)
+		}
+	}
+	writeMetricHeader(&b, "quiz_bank_chapters_not_loaded", "gauge", "启动时没能加载的章节数，reason 为 missing（没有题库文件）或 failed（无法读取或解析）")
+	for _, status := range bankReadiness().Courses {
+		writeSample(&b, "quiz_bank_chapters_not_loaded", len(status.MissingChapters), "course", status.Course, "reason", "missing")
+		writeSample(&b, "quiz_bank_chapters_not_loaded", len(status.FailedChapters), "course", status.Course, "reason", "failed")
+	}
+	return b.String()
+}
+
+// writeMetricHeader 输出指标的 HELP 和 TYPE 行
+func writeMetricHeader(b *strings.Builder, name, metricType, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writeSample 输出一个样本，labels 为成对的标签名和值
+func writeSample(b *strings.Builder, name string, value interface{}, labels ...string) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(b, " %v\n", value)
+}
+
+// labelValueEscaper 按 Prometheus 文本格式转义标签值
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// sortedKeys 按字母顺序返回映射的键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
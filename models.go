@@ -2,6 +2,7 @@ package main
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,7 +14,7 @@ const (
 	maogaiMaxChapterIndex           = 8
 	xigaiLiMaxChapterIndex          = 0  // 李老师的习概只有一个章节
 	xigaiYangMaxChapterIndex        = 17 // 杨老师的习概包含导论(0) + 17章
-	userDataBaseDir                 = "user_data"
+	userDataDirName                 = "user_data"
 	incorrectQuestionsFile          = "incorrect_questions.json"            // 默认(毛概)错题文件
 	maogaiIncorrectQuestionsFile    = "maogai_incorrect_questions.json"     // 毛概错题文件
 	xigaiIncorrectQuestionsFile     = "xigai_incorrect_questions.json"      // 兼容老版本的习概错题文件（保留以向后兼容）
@@ -21,30 +22,83 @@ const (
 	xigaiYangIncorrectQuestionsFile = "xigai_yang_incorrect_questions.json" // 习概 杨老师 错题文件
 	deleteIncorrectQuestionsFile    = "deleted_incorrect_questions.json"
 	questionStatsFile               = "question_stats.json"
+	questionNotesFile               = "question_notes.json"   // 个人笔记与收藏
+	studyActivityFile               = "study_activity.json"   // 按 15 分钟时段汇总的作答次数，用于连续学习天数和学习日历
+	studyGoalsFile                  = "study_goals.json"      // 每日学习目标
+	leaderboardSettingsFile         = "leaderboard.json"      // 是否参加排行榜及匿名设置
+	assignmentWorkFile              = "assignment_work.json"  // 学生的作业作答记录
+	battleResultsFile               = "battle_results.json"   // 参加过的对战的最终结果
+	sessionFile                     = "session.json"          // 服务退出时保存的内存会话，下次启动时恢复后删除
+	bankOverlayDirName              = "bank_overlays"         // 数据目录下的题库旁路文件子目录（拆解等），不修改嵌入的题库
+	explanationsFile                = "explanations.json"     // 题目拆解与教材出处
+	questionReportsFile             = "question_reports.json" // 用户提交的题目纠错报告
+	correctionsFile                 = "corrections.json"      // 管理员应用的题目修正
+	tagsFile                        = "tags.json"             // 题目的知识点标签
+	tagRulesFile                    = "tag_rules.json"        // 自动推荐标签的关键词规则（不存在时使用内置规则）
+	assignmentsFile                 = "assignments.json"      // 老师布置的作业
+	globalStatsFile                 = "global_stats.json"     // 全局作答统计：每道题每名用户首次作答是否答对
+	uploadedBanksDirName            = "banks"                 // 数据目录下通过上传安装的外部题库，每门课程一个子目录
+	adminTokenEnv                   = "QUIZ_ADMIN_TOKEN"      // 管理接口令牌的环境变量，未设置时仅允许本机访问
+	teacherTokenEnv                 = "QUIZ_TEACHER_TOKEN"    // 老师接口令牌的环境变量，管理令牌同样可以使用老师接口
+)
+
+// 数据目录，由配置决定 (见 applyConfig)
+var (
+	userDataBaseDir = userDataDirName      // 用户数据根目录
+	bankOverlayDir  = bankOverlayDirName   // 题库旁路文件目录
+	uploadedBankDir = uploadedBanksDirName // 通过上传安装的外部题库目录
 )
 
 // --- 数据结构定义 ---
 type Question struct {
-	QuestionNumber     string            `json:"question_number"`
-	QuestionType       string            `json:"question_type"`
-	QuestionText       string            `json:"question_text"`
-	Options            map[string]string `json:"options"`
-	CorrectAnswer      string            `json:"correct_answer"`
-	GlobalCorrectCount int               `json:"correct_count"` // 未来可能用于全局统计
-	GlobalErrorCount   int               `json:"error_count"`   // 未来可能用于全局统计
-	OriginalChapterKey string            `json:"-"`             // 内部使用，标记原始章节
-	OriginalIndex      int               `json:"-"`             // 内部使用，标记在原始章节中的索引
+	QuestionNumber     string             `json:"question_number"`
+	QuestionType       string             `json:"question_type"`
+	QuestionText       string             `json:"question_text"`
+	Options            map[string]string  `json:"options"`
+	CorrectAnswer      string             `json:"correct_answer"`
+	GlobalCorrectCount int                `json:"correct_count"`         // 全局统计：首次作答答对的人数（每人每题只计一次），只在生成接口响应时填入
+	GlobalErrorCount   int                `json:"error_count"`           // 全局统计：首次作答答错的人数（每人每题只计一次），只在生成接口响应时填入
+	Explanation        string             `json:"explanation,omitempty"` // 拆解（可选），旁路文件中的拆解优先
+	Reference          *QuestionReference `json:"reference,omitempty"`   // 教材出处（可选），旁路文件中的出处优先
+	OriginalCourse     string             `json:"-"`                     // 内部使用，标记所属的实体课程
+	OriginalChapterKey string             `json:"-"`                     // 内部使用，标记原始章节
+	OriginalIndex      int                `json:"-"`                     // 内部使用，标记在原始章节中的索引
 }
 
 type QuestionOutput struct {
-	QuizQuestionID         string            `json:"quiz_question_id"`         // 在当前测验/回顾中的唯一ID
-	DisplayNumber          int               `json:"display_number"`           // 在当前列表中的显示序号 (1-based)
-	OriginalChapter        string            `json:"original_chapter"`         // 原始章节键
-	OriginalQuestionNumber string            `json:"original_question_number"` // 原始题号
-	QuestionType           string            `json:"question_type"`
-	QuestionText           string            `json:"question_text"`
-	Options                map[string]string `json:"options"`
-	CorrectAnswer          string            `json:"correct_answer"` // 答案将始终包含
+	QuizQuestionID         string             `json:"quiz_question_id"`         // 在当前测验/回顾中的唯一ID
+	QuestionID             string             `json:"question_id"`              // 题目的唯一ID (课程_章节_索引)，用于笔记等按题目保存的数据
+	DisplayNumber          int                `json:"display_number"`           // 在当前列表中的显示序号 (1-based)
+	OriginalCourse         string             `json:"original_course"`          // 题目所属的实体课程 (虚拟课程下也指向来源课程)
+	OriginalChapter        string             `json:"original_chapter"`         // 原始章节键
+	OriginalQuestionNumber string             `json:"original_question_number"` // 原始题号
+	QuestionType           string             `json:"question_type"`
+	QuestionText           string             `json:"question_text"`
+	Options                map[string]string  `json:"options"`
+	CorrectAnswer          string             `json:"correct_answer"`          // 答案将始终包含
+	Explanation            string             `json:"explanation,omitempty"`   // 拆解，仅在速刷模式中随题目返回
+	Reference              *QuestionReference `json:"reference,omitempty"`     // 教材出处，仅在速刷模式中随题目返回
+	Bookmarked             bool               `json:"bookmarked"`              // 当前用户是否收藏了该题
+	Note                   string             `json:"note,omitempty"`          // 当前用户对该题的笔记
+	Tags                   []string           `json:"tags,omitempty"`          // 知识点标签
+	AssignmentID           string             `json:"assignment_id,omitempty"` // 作业中的题目，提交答案时带上以记录作业进度
+	GlobalCorrectCount     int                `json:"correct_count"`           // 全局统计：首次作答答对的人数
+	GlobalErrorCount       int                `json:"error_count"`             // 全局统计：首次作答答错的人数
+	Difficulty             *GlobalDifficulty  `json:"difficulty,omitempty"`    // 全局难度，作答人数不足时为空
+}
+
+// QuestionReference 题目在教材中的出处
+type QuestionReference struct {
+	Chapter string `json:"chapter,omitempty"` // 章，如 "第三章 坚持党的全面领导"
+	Section string `json:"section,omitempty"` // 节，如 "第一节"
+	Page    string `json:"page,omitempty"`    // 页码，如 "56" 或 "56-57"
+}
+
+// QuestionExplanation 旁路文件中一道题的拆解与出处
+type QuestionExplanation struct {
+	Explanation string             `json:"explanation,omitempty"`
+	Reference   *QuestionReference `json:"reference,omitempty"`
+	UpdatedAt   time.Time          `json:"updated_at"`
 }
 
 type UserIncorrectQuestion struct {
@@ -54,32 +108,197 @@ type UserIncorrectQuestion struct {
 	Options         map[string]string `json:"options"`
 	CorrectAnswer   string            `json:"correct_answer"`
 	OriginalChapter string            `json:"original_chapter"`
-	UserAnswer      string            `json:"user_answer,omitempty"` // 用户在答错时的答案
-	Timestamp       time.Time         `json:"timestamp"`             // 答错的时间
-	DeletedAt       time.Time         `json:"deleted_at,omitempty"`  // 题目被删除的时间
+	OriginalCourse  string            `json:"original_course,omitempty"` // 来源课程，旧数据可能为空
+	UserAnswer      string            `json:"user_answer,omitempty"`     // 用户在答错时的答案
+	Timestamp       time.Time         `json:"timestamp"`                 // 答错的时间
+	DeletedAt       time.Time         `json:"deleted_at,omitempty"`      // 题目被删除的时间
 }
 
 type UserQuestionStat struct {
+	OriginalCourse         string    `json:"original_course,omitempty"` // 来源课程，旧数据可能为空
 	OriginalChapterKey     string    `json:"original_chapter_key"`
 	OriginalQuestionNumber string    `json:"original_question_number"`
 	CorrectCount           int       `json:"correct_count"`
 	ErrorCount             int       `json:"error_count"`
 	LastAnswered           time.Time `json:"last_answered"`
+	TimedCount             int       `json:"timed_count,omitempty"`   // 有用时记录的作答次数
+	TotalTimeMs            int64     `json:"total_time_ms,omitempty"` // 有用时记录的作答累计用时（毫秒）
+	LastTimeMs             int64     `json:"last_time_ms,omitempty"`  // 最近一次有用时记录的作答用时（毫秒）
+}
+
+// VirtualCourseMember 描述虚拟课程中的一个组成部分
+type VirtualCourseMember struct {
+	Course   string   // 实体课程键，如 "xigai_li"
+	Chapters []string // 包含的章节键，为空表示该课程的全部章节
+}
+
+// ChapterMeta 描述课程中一个可选章节的元数据
+type ChapterMeta struct {
+	Key           string         `json:"key"`            // 选择章节时使用的键；虚拟课程为 "课程:章节"
+	Title         string         `json:"title"`          // 章节标题
+	Order         int            `json:"order"`          // 在课程中的展示顺序 (0-based)
+	Course        string         `json:"course"`         // 题目所属的实体课程
+	ChapterKey    string         `json:"chapter_key"`    // 在实体课程中的章节键
+	QuestionCount int            `json:"question_count"` // 章节题目总数
+	TypeCounts    map[string]int `json:"type_counts"`    // 各题型的题目数，如 {"单选题": 40}
+}
+
+// CourseMeta 描述一门课程（实体或虚拟）及其章节
+type CourseMeta struct {
+	Key           string         `json:"key"`
+	Title         string         `json:"title"`
+	Virtual       bool           `json:"virtual"` // 是否为由多个实体课程组合的虚拟课程
+	QuestionCount int            `json:"question_count"`
+	TypeCounts    map[string]int `json:"type_counts"`
+	Chapters      []ChapterMeta  `json:"chapters"`
+}
+
+// QuestionReport 用户对一道题提交的纠错报告
+type QuestionReport struct {
+	ID             string    `json:"id"`
+	QuestionID     string    `json:"question_id"` // 题目的唯一ID (课程_章节_索引)
+	UserID         string    `json:"user_id"`
+	Reason         string    `json:"reason"`
+	ProposedAnswer string    `json:"proposed_answer,omitempty"` // 用户认为正确的答案，如 "AC"
+	Status         string    `json:"status"`                    // "open", "resolved" 或 "dismissed"
+	CreatedAt      time.Time `json:"created_at"`                // 首次报告的时间
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`      // 最近一次补充或修改报告的时间
+	ResolvedAt     time.Time `json:"resolved_at,omitempty"`
+}
+
+// QuestionReportSummary 同一道题的纠错报告汇总，供管理员审核
+type QuestionReportSummary struct {
+	QuestionID      string         `json:"question_id"`
+	Course          string         `json:"course"`
+	Chapter         string         `json:"chapter"`
+	QuestionNumber  string         `json:"question_number"`
+	QuestionText    string         `json:"question_text"`
+	CurrentAnswer   string         `json:"current_answer"` // 当前生效的答案（已应用修正）
+	Corrected       bool           `json:"corrected"`      // 是否已有修正
+	ReportCount     int            `json:"report_count"`
+	Reasons         []string       `json:"reasons"`
+	ProposedAnswers map[string]int `json:"proposed_answers"` // 建议答案 -> 提出次数
+	Reporters       []string       `json:"reporters"`
+	FirstReportedAt time.Time      `json:"first_reported_at"` // 最早一份报告的首次报告时间
+	LastReportedAt  time.Time      `json:"last_reported_at"`  // 最近一次提交或修改报告的时间
+}
+
+// QuestionCorrection 管理员对一道题的修正，以旁路文件覆盖嵌入题库中的字段，空字段表示不修改
+type QuestionCorrection struct {
+	QuestionType  string            `json:"question_type,omitempty"`
+	QuestionText  string            `json:"question_text,omitempty"`
+	Options       map[string]string `json:"options,omitempty"`
+	CorrectAnswer string            `json:"correct_answer,omitempty"`
+	Comment       string            `json:"comment,omitempty"` // 修正说明
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// TagRule 自动推荐标签的关键词规则：题干或选项包含任一关键词时推荐该标签
+type TagRule struct {
+	Tag      string   `json:"tag"`
+	Keywords []string `json:"keywords"`
+}
+
+// TagSuggestion 自动推荐给一道题的新标签
+type TagSuggestion struct {
+	QuestionID    string   `json:"question_id"`
+	QuestionText  string   `json:"question_text"`
+	CurrentTags   []string `json:"current_tags"`
+	SuggestedTags []string `json:"suggested_tags"` // 不包含已有的标签
+}
+
+// TagSummary 标签及其覆盖的题目数
+type TagSummary struct {
+	Tag           string `json:"tag"`
+	QuestionCount int    `json:"question_count"`
+}
+
+// TagAccuracy 用户在某个知识点标签上的答题统计
+type TagAccuracy struct {
+	Tag            string  `json:"tag"`
+	QuestionCount  int     `json:"question_count"`  // 答过的带该标签的题目数
+	CorrectCount   int     `json:"correct_count"`   // 累计答对次数
+	ErrorCount     int     `json:"error_count"`     // 累计答错次数
+	Accuracy       float64 `json:"accuracy"`        // 答对次数 / 作答次数
+	TotalQuestions int     `json:"total_questions"` // 题库中带该标签的题目数
+}
+
+// AnswerCounts 一组题目的作答统计
+type AnswerCounts struct {
+	QuestionCount     int     `json:"question_count"`     // 题库中的题目数
+	AnsweredQuestions int     `json:"answered_questions"` // 答过的题目数
+	CorrectCount      int     `json:"correct_count"`      // 累计答对次数
+	ErrorCount        int     `json:"error_count"`        // 累计答错次数
+	Accuracy          float64 `json:"accuracy"`           // 答对次数 / 作答次数
+}
+
+// ChapterStatsSummary 用户在一个章节的作答统计
+type ChapterStatsSummary struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	AnswerCounts
+}
+
+// CourseStatsSummary 用户在一门实体课程的作答统计
+type CourseStatsSummary struct {
+	Course         string `json:"course"`
+	Title          string `json:"title"`
+	IncorrectCount int    `json:"incorrect_count"` // 错题本中的题目数
+	AnswerCounts
+	Chapters []ChapterStatsSummary `json:"chapters"`
+}
+
+// UserStatsSummary 用户的作答统计汇总
+type UserStatsSummary struct {
+	UserID         string    `json:"user_id"`
+	IncorrectCount int       `json:"incorrect_count"`
+	LastAnswered   time.Time `json:"last_answered"` // 最近一次作答时间，从未作答时为零值
+	AnswerCounts
+	Courses []CourseStatsSummary `json:"courses"`
+}
+
+// UserSummary 用户数据目录中一个用户的概况
+type UserSummary struct {
+	UserID            string    `json:"user_id"`
+	AnsweredQuestions int       `json:"answered_questions"`
+	IncorrectCount    int       `json:"incorrect_count"`
+	NoteCount         int       `json:"note_count"`
+	LastAnswered      time.Time `json:"last_answered"`
+}
+
+// BankIssue 题库校验发现的问题
+type BankIssue struct {
+	QuestionID     string `json:"question_id"` // 题目的唯一ID (课程_章节_索引)
+	Course         string `json:"course"`
+	Chapter        string `json:"chapter"`
+	QuestionNumber string `json:"question_number"`
+	Severity       string `json:"severity"` // "error" 或 "warning"
+	Problem        string `json:"problem"`
+}
+
+// UserQuestionNote 用户对一道题的笔记与收藏，与错题本相互独立
+type UserQuestionNote struct {
+	QuestionID string    `json:"question_id"` // 题目的唯一ID (课程_章节_索引)
+	Bookmarked bool      `json:"bookmarked"`
+	Note       string    `json:"note,omitempty"` // 如助记口诀 "三个代表 → 江"
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // UserSession 存储用户当前会话状态
 type UserSession struct {
-	UserID string
+	UserID string `json:"user_id"`
 	// CurrentQuestions is used if frontend logic relies on server to step through questions,
 	// e.g. for a simplified /api/review/next. If frontend receives all questions
 	// from /start endpoints and manages navigation itself, this might be less critical
 	// for those modes. For this iteration, we keep it for potential use with /api/review/next.
-	CurrentQuestions     []QuestionOutput
-	OriginalIncorrect    []UserIncorrectQuestion // Store the full incorrect questions for retrieval
-	CurrentQuestionIndex int                     // Index for session.CurrentQuestions (e.g., /api/review/next)
-	CurrentMode          string                  // "review", "quiz", "incorrect_review"
-	CurrentCourse        string                  // "maogai", "xigai_li" 或 "xigai_yang" - 当前选择的课程
+	CurrentQuestions     []QuestionOutput        `json:"current_questions,omitempty"`
+	OriginalIncorrect    []UserIncorrectQuestion `json:"original_incorrect,omitempty"` // Store the full incorrect questions for retrieval
+	CurrentQuestionIndex int                     `json:"current_question_index"`       // Index for session.CurrentQuestions (e.g., /api/review/next)
+	CurrentMode          string                  `json:"current_mode"`                 // "review", "quiz", "incorrect_review"
+	CurrentCourse        string                  `json:"current_course"`               // "maogai", "xigai_li", "xigai_yang" 或虚拟课程 (如 "xigai_all") - 当前选择的课程
+	ServedAt             map[string]time.Time    `json:"served_at,omitempty"`          // 答题模式下每道题的出题时间 (quiz_question_id -> 时间)，用于服务端计时
 	mu                   sync.Mutex              // 保护会话内部数据
+	lastActive           atomic.Int64            // 最近一次使用会话的时间 (Unix 纳秒)，用于统计活跃会话
 }
 
 // --- 请求结构体 ---
@@ -89,9 +308,12 @@ type InitSessionRequest struct {
 
 type StartModeRequest struct {
 	UserID        string   `json:"user_id" vd:"required"`
-	Course        string   `json:"course" vd:"required"`         // "maogai", "xigai_li" 或 "xigai_yang"
-	ChapterChoice []string `json:"chapter_choice" vd:"required"` // 例如 ["0", "1", "all"]
+	Course        string   `json:"course" vd:"required"`         // "maogai", "xigai_li", "xigai_yang" 或虚拟课程 "xigai_all"
+	ChapterChoice []string `json:"chapter_choice" vd:"required"` // 章节键，见 /api/courses，例如 ["0", "1"]；虚拟课程如 "xigai_yang:3"；"all" 表示全部章节
 	OrderChoice   string   `json:"order_choice" vd:"required"`   // "sequential" 或 "random"
+	Source        string   `json:"source"`                       // 题目来源: 空或 "all" 表示所选章节的全部题目，"bookmarked" 表示只取收藏的题目
+	Tags          []string `json:"tags"`                         // 可选，只取带有任一标签的题目，如 ["新发展理念"]
+	Seed          int64    `json:"seed"`                         // 可选，随机种子；为 0 时生成新的种子，使用的种子会在响应中返回
 }
 
 type GetNextQuestionRequest struct {
@@ -101,6 +323,7 @@ type GetNextQuestionRequest struct {
 type StartIncorrectReviewRequest struct {
 	UserID string `json:"user_id" vd:"required"`
 	Course string `json:"course" vd:"required"`
+	Seed   int64  `json:"seed"` // 可选，打乱错题顺序的随机种子，为 0 时生成新的种子
 }
 
 type SubmitAnswerRequest struct {
@@ -108,10 +331,633 @@ type SubmitAnswerRequest struct {
 	QuizQuestionID string `json:"quiz_question_id" vd:"required"` // 题目在当前测验中的ID
 	UserAnswer     string `json:"user_answer" vd:"required"`      // 用户选择的答案
 	WasCorrect     bool   `json:"was_correct"`                    // 由前端判断并发送该答案是否正确
+	TimeSpentMs    int64  `json:"time_spent_ms"`                  // 可选，前端计时的用时（毫秒），服务端没有该题的出题时间时使用
+	AssignmentID   string `json:"assignment_id"`                  // 可选，正在做的作业，答案同时记入作业
+}
+
+type ServeQuestionRequest struct {
+	UserID         string `json:"user_id" vd:"required"`
+	QuizQuestionID string `json:"quiz_question_id" vd:"required"` // 正在展示的题目
+}
+
+type UpsertExplanationRequest struct {
+	QuestionID  string             `json:"question_id" vd:"required"` // "课程_章节_索引"，也接受 "quiz_" 前缀的题目ID
+	Explanation string             `json:"explanation"`               // 为空且没有出处时删除该题的拆解
+	Reference   *QuestionReference `json:"reference"`
+}
+
+// TagsListRequest 查询知识点标签，GET 请求使用查询参数
+type TagsListRequest struct {
+	Course string `query:"course" json:"course"` // 可选，只统计该课程（含虚拟课程）的题目
+}
+
+type UserNotesListRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+type UpsertNoteRequest struct {
+	UserID     string  `json:"user_id" vd:"required"`
+	QuestionID string  `json:"question_id" vd:"required"` // "课程_章节_索引"，也接受 "quiz_" 前缀的题目ID
+	Bookmarked *bool   `json:"bookmarked"`                // 为空时保持原值
+	Note       *string `json:"note"`                      // 为空时保持原值
+}
+
+type DeleteNoteRequest struct {
+	UserID     string `json:"user_id" vd:"required"`
+	QuestionID string `json:"question_id" vd:"required"`
+}
+
+type ReportQuestionRequest struct {
+	UserID         string `json:"user_id" vd:"required"`
+	QuestionID     string `json:"question_id" vd:"required"` // "课程_章节_索引"，也接受 "quiz_" 前缀的题目ID
+	Reason         string `json:"reason" vd:"required"`
+	ProposedAnswer string `json:"proposed_answer"` // 可选
+}
+
+type ResolveReportsRequest struct {
+	QuestionID string `json:"question_id" vd:"required"`
+	Status     string `json:"status" vd:"required"` // "resolved" 或 "dismissed"
+}
+
+type ApplyCorrectionRequest struct {
+	QuestionID     string            `json:"question_id" vd:"required"`
+	QuestionType   string            `json:"question_type"`
+	QuestionText   string            `json:"question_text"`
+	Options        map[string]string `json:"options"`
+	CorrectAnswer  string            `json:"correct_answer"`
+	Comment        string            `json:"comment"`
+	ResolveReports bool              `json:"resolve_reports"` // 同时将该题未处理的报告标记为已解决
+}
+
+type DeleteCorrectionRequest struct {
+	QuestionID string `json:"question_id" vd:"required"`
+}
+
+type SetTagsRequest struct {
+	QuestionID string   `json:"question_id" vd:"required"`
+	Tags       []string `json:"tags"` // 题目的全部标签，为空时清除
+}
+
+type SuggestTagsRequest struct {
+	Course string `json:"course"` // 可选，只处理该课程（含虚拟课程）的题目
+	Apply  bool   `json:"apply"`  // 为 true 时把推荐的标签合并保存到标签文件
+}
+
+type TagStatsRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+	Course string `query:"course" json:"course"` // 可选，只统计该课程（含虚拟课程）的题目
+}
+
+type UserStatsRequest struct {
+	UserID string `json:"user_id" vd:"required"`
 }
 
 type DeleteIncorrectQuestionRequest struct {
 	UserID                 string `json:"user_id" vd:"required"`
+	Course                 string `query:"course" json:"course"` // 可选，题目的来源课程；为空时使用会话中的当前课程
 	OriginalChapter        string `json:"original_chapter" vd:"required"`
 	OriginalQuestionNumber string `json:"original_question_number" vd:"required"`
 }
+
+type ClearUserDataRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+// ExportSelection 导出题目的范围，GET 请求使用查询参数
+type ExportSelection struct {
+	Course        string   `query:"course" json:"course"`                 // 课程（含虚拟课程），导出错题本时可为空表示全部课程
+	ChapterChoice []string `query:"chapter_choice" json:"chapter_choice"` // 可选，章节键，可重复或用逗号分隔，默认全部章节
+	UserID        string   `query:"user_id" json:"user_id"`               // 可选，指定时导出该用户的错题本
+}
+
+// WorksheetExportRequest 生成打印试卷的参数，GET 请求使用查询参数
+type WorksheetExportRequest struct {
+	ExportSelection
+	QuestionTypes []string `query:"question_type" json:"question_type"` // 可选，只出这些题型，可重复或用逗号分隔
+	Count         int      `query:"count" json:"count"`                 // 可选，题数，0 表示全部
+	OrderChoice   string   `query:"order_choice" json:"order_choice"`   // "sequential"（默认）或 "random"
+	Seed          int64    `query:"seed" json:"seed"`                   // 可选，试卷编号（随机种子），为 0 时生成新的编号
+	Title         string   `query:"title" json:"title"`                 // 可选，试卷标题
+	AnswerKey     string   `query:"answer_key" json:"answer_key"`       // "append"（默认，另起一页附参考答案）、"none" 或 "only"
+}
+
+type SpeedStatsRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+	Course string `query:"course" json:"course"` // 可选，只统计该课程（含虚拟课程）的题目
+}
+
+// SpeedSummary 一组作答的用时汇总
+type SpeedSummary struct {
+	TimedAnswers   int     `json:"timed_answers"`   // 有用时记录的作答次数
+	AverageSeconds float64 `json:"average_seconds"` // 平均每次作答的用时（秒）
+}
+
+// TypeSpeed 按题型汇总的用时
+type TypeSpeed struct {
+	QuestionType string `json:"question_type"`
+	SpeedSummary
+}
+
+// ChapterSpeed 按章节汇总的用时
+type ChapterSpeed struct {
+	Course  string `json:"course"`
+	Chapter string `json:"chapter"`
+	Title   string `json:"title"`
+	SpeedSummary
+}
+
+// SlowQuestion 最近一次作答用时超过题型阈值的题目（无论是否答对）
+type SlowQuestion struct {
+	QuestionID     string  `json:"question_id"`
+	Course         string  `json:"course"`
+	Chapter        string  `json:"chapter"`
+	QuestionNumber string  `json:"question_number"`
+	QuestionType   string  `json:"question_type"`
+	QuestionText   string  `json:"question_text"`
+	LastSeconds    float64 `json:"last_seconds"`    // 最近一次作答的用时（秒）
+	AverageSeconds float64 `json:"average_seconds"` // 平均用时（秒）
+	CorrectCount   int     `json:"correct_count"`
+	ErrorCount     int     `json:"error_count"`
+}
+
+// SpeedStats 用户的作答用时统计
+type SpeedStats struct {
+	UserID        string         `json:"user_id"`
+	Overall       SpeedSummary   `json:"overall"`
+	ByType        []TypeSpeed    `json:"by_type"`
+	ByChapter     []ChapterSpeed `json:"by_chapter"`
+	SlowQuestions []SlowQuestion `json:"slow_questions"` // 按最近一次用时从长到短排列
+}
+
+// StudyActivitySlot 一个 15 分钟时段 (UTC) 内的作答汇总
+type StudyActivitySlot struct {
+	Answers int                         `json:"answers"`
+	TimeMs  int64                       `json:"time_ms,omitempty"` // 有用时记录的作答累计用时（毫秒）
+	Courses map[string]StudyCourseCount `json:"courses,omitempty"` // 答题模式下按课程的作答次数（不含错题回顾）
+}
+
+// StudyCourseCount 一门课程的作答次数
+type StudyCourseCount struct {
+	Answers int `json:"answers"`
+	Correct int `json:"correct"`
+}
+
+// StudyGoals 每日学习目标，0 表示不设该项目标
+type StudyGoals struct {
+	QuestionsPerDay int `json:"questions_per_day"`
+	MinutesPerDay   int `json:"minutes_per_day"`
+}
+
+type StudyGoalsRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+// UpdateStudyGoalsRequest 修改每日学习目标，未提供的项保持不变
+type UpdateStudyGoalsRequest struct {
+	UserID          string `json:"user_id" vd:"required"`
+	QuestionsPerDay *int   `json:"questions_per_day"`
+	MinutesPerDay   *int   `json:"minutes_per_day"`
+}
+
+type StudyActivityRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+// ActivityDay 一天的学习情况
+type ActivityDay struct {
+	Date    string  `json:"date"`     // 所在时区的日期，如 2025-06-01
+	Count   int     `json:"count"`    // 作答题数
+	Minutes float64 `json:"minutes"`  // 作答用时（分钟，仅统计有用时记录的作答）
+	GoalMet bool    `json:"goal_met"` // 是否完成每日目标（未设目标时答过题即算完成）
+}
+
+// StudyActivity 用户的学习日历和连续学习天数
+type StudyActivity struct {
+	UserID        string        `json:"user_id"`
+	Timezone      string        `json:"timezone"`
+	Goals         StudyGoals    `json:"goals"`
+	Today         ActivityDay   `json:"today"`
+	CurrentStreak int           `json:"current_streak"` // 截至今天（今天还没完成时截至昨天）连续完成目标的天数
+	LongestStreak int           `json:"longest_streak"`
+	ActiveDays    int           `json:"active_days"` // 日历范围内答过题的天数
+	Calendar      []ActivityDay `json:"calendar"`    // 过去一年每天一项（含今天），按日期升序
+}
+
+// LeaderboardSettings 用户的排行榜设置，默认不参加
+type LeaderboardSettings struct {
+	OptIn    bool   `json:"opt_in"`          // 是否出现在排行榜上
+	HideName bool   `json:"hide_name"`       // 是否用匿名代号代替用户名
+	Alias    string `json:"alias,omitempty"` // 匿名代号，首次匿名时生成
+}
+
+// LeaderboardSettingsRequest 获取或修改排行榜设置，未提供的项保持不变
+type LeaderboardSettingsRequest struct {
+	UserID   string `json:"user_id" vd:"required"`
+	OptIn    *bool  `json:"opt_in"`
+	HideName *bool  `json:"hide_name"`
+}
+
+// LeaderboardRequest 查询排行榜，GET 请求使用查询参数
+type LeaderboardRequest struct {
+	Course string `query:"course" json:"course"` // 可选，课程（含虚拟课程），为空表示全部课程
+	Period string `query:"period" json:"period"` // "week"（默认，本周一起）或 "all"
+}
+
+// LeaderboardEntry 排行榜上的一名用户
+type LeaderboardEntry struct {
+	Rank     int     `json:"rank"`
+	Name     string  `json:"name"`
+	Value    float64 `json:"value"`
+	Attempts int     `json:"attempts,omitempty"` // 正确率榜的作答次数
+}
+
+// Leaderboard 一项指标的排行榜
+type Leaderboard struct {
+	Metric  string             `json:"metric"` // answered, accuracy, streak, cleared
+	Title   string             `json:"title"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardResult 一门课程一个时段的全部排行榜
+type LeaderboardResult struct {
+	Course       string        `json:"course"`
+	Period       string        `json:"period"`
+	PeriodStart  *time.Time    `json:"period_start,omitempty"` // 本周开始时间，全部时间时为空
+	MinAttempts  int           `json:"min_attempts"`           // 进入正确率榜所需的最少作答次数
+	Participants int           `json:"participants"`           // 参加排行榜的用户数
+	GeneratedAt  time.Time     `json:"generated_at"`
+	Boards       []Leaderboard `json:"boards"`
+}
+
+// Assignment 老师布置的作业：按课程、章节、题型选题，用固定的随机种子打乱后取前若干题，所有学生题目相同
+type Assignment struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Course        string    `json:"course"`
+	ChapterChoice []string  `json:"chapter_choice"`
+	QuestionTypes []string  `json:"question_types,omitempty"` // 为空表示全部题型
+	Count         int       `json:"count"`                    // 题数，0 表示所选范围的全部题目
+	Seed          int64     `json:"seed"`
+	QuestionIDs   []string  `json:"question_ids,omitempty"` // 布置时选出的题目ID（按作答顺序），之后题库变化也不影响作业的题目
+	Due           time.Time `json:"due"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CreateAssignmentRequest 布置作业
+type CreateAssignmentRequest struct {
+	Title         string   `json:"title" vd:"required"`
+	Course        string   `json:"course" vd:"required"`
+	ChapterChoice []string `json:"chapter_choice" vd:"required"`
+	QuestionTypes []string `json:"question_types"`
+	Count         int      `json:"count"`
+	Seed          int64    `json:"seed"`              // 可选，为 0 时生成新的种子
+	Due           string   `json:"due" vd:"required"` // 截止时间：日期（当天结束，按学习日时区）或 RFC 3339 时间
+}
+
+type DeleteAssignmentRequest struct {
+	ID string `json:"id" vd:"required"`
+}
+
+// AssignmentResultsRequest 查看作业完成情况，GET 请求使用查询参数
+type AssignmentResultsRequest struct {
+	ID     string `query:"id" json:"id"`
+	Format string `query:"format" json:"format"` // "json"（默认）或 "csv"
+	Table  string `query:"table" json:"table"`   // CSV 导出的表："students"（默认）或 "missed"
+}
+
+type StudentAssignmentsRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+type StartAssignmentRequest struct {
+	UserID       string `json:"user_id" vd:"required"`
+	AssignmentID string `json:"assignment_id" vd:"required"`
+}
+
+// AssignmentAnswer 学生对作业中一道题的作答（只保留第一次作答）
+type AssignmentAnswer struct {
+	Answer     string    `json:"answer"`
+	Correct    bool      `json:"correct"` // 由服务端按题库答案判断
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+// AssignmentSubmission 学生的一份作业作答记录
+type AssignmentSubmission struct {
+	StartedAt   time.Time                   `json:"started_at"`
+	CompletedAt time.Time                   `json:"completed_at,omitempty"`
+	Answers     map[string]AssignmentAnswer `json:"answers"` // 题目ID -> 作答
+}
+
+// AssignmentProgress 学生的作业进度
+type AssignmentProgress struct {
+	Answered  int  `json:"answered"`
+	Correct   int  `json:"correct"`
+	Total     int  `json:"total"`
+	Completed bool `json:"completed"`
+	Late      bool `json:"late"` // 截止后才完成
+}
+
+// StudentAssignment 学生看到的作业及自己的进度
+type StudentAssignment struct {
+	Assignment
+	Overdue  bool               `json:"overdue"`
+	Progress AssignmentProgress `json:"progress"`
+}
+
+// AssignmentStudentResult 结果表中的一名学生
+type AssignmentStudentResult struct {
+	UserID      string    `json:"user_id"`
+	Status      string    `json:"status"` // not_started, in_progress, completed
+	Answered    int       `json:"answered"`
+	Correct     int       `json:"correct"`
+	Score       float64   `json:"score"` // 答对题数占作业总题数的百分比
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+	Late        bool      `json:"late"`
+}
+
+// AssignmentMissedQuestion 结果表中答错较多的题目
+type AssignmentMissedQuestion struct {
+	QuestionID   string `json:"question_id"`
+	QuestionText string `json:"question_text"`
+	Answered     int    `json:"answered"`
+	Missed       int    `json:"missed"`
+	TopWrong     string `json:"top_wrong,omitempty"` // 最多人选的错误答案
+}
+
+// AssignmentResults 一份作业的完成情况
+type AssignmentResults struct {
+	Assignment     Assignment                 `json:"assignment"`
+	TotalQuestions int                        `json:"total_questions"`
+	Completed      int                        `json:"completed"`
+	Students       []AssignmentStudentResult  `json:"students"`
+	MostMissed     []AssignmentMissedQuestion `json:"most_missed"`
+}
+
+// GlobalDifficulty 由所有用户的首次作答汇总出的题目难度（每人每题只计一次）
+type GlobalDifficulty struct {
+	Respondents     int      `json:"respondents"`              // 作答人数
+	CorrectCount    int      `json:"correct_count"`            // 首次作答答对的人数
+	DifficultyIndex float64  `json:"difficulty_index"`         // 难度指数：首次作答答对的比例 (0~1)，越低越难
+	Level           string   `json:"level"`                    // 容易 / 中等 / 较难
+	Discrimination  *float64 `json:"discrimination,omitempty"` // 区分度：高分组与低分组答对比例之差 (-1~1)，作答人数不足时为空
+}
+
+// QuestionDifficultyRequest 查询题目难度 (?course=&sort=hardest|easiest|discrimination&limit=)
+type QuestionDifficultyRequest struct {
+	Course string `query:"course" json:"course"` // 可选，课程（含虚拟课程），为空表示全部课程
+	Sort   string `query:"sort" json:"sort"`     // hardest（默认）、easiest 或 discrimination（区分度从低到高）
+	Limit  int    `query:"limit" json:"limit"`   // 最多返回的题数，0 表示全部
+}
+
+// QuestionDifficultyEntry 难度列表中的一道题
+type QuestionDifficultyEntry struct {
+	QuestionID     string           `json:"question_id"`
+	OriginalCourse string           `json:"original_course"`
+	Chapter        string           `json:"chapter"`
+	QuestionNumber string           `json:"question_number"`
+	QuestionType   string           `json:"question_type"`
+	QuestionText   string           `json:"question_text"`
+	Difficulty     GlobalDifficulty `json:"difficulty"`
+}
+
+// CreateBattleRequest 创建对战房间
+type CreateBattleRequest struct {
+	UserID          string   `json:"user_id" vd:"required"`
+	Course          string   `json:"course" vd:"required"`
+	ChapterChoice   []string `json:"chapter_choice" vd:"required"`
+	Count           int      `json:"count"`            // 题数，默认 20
+	Seed            int64    `json:"seed"`             // 可选，随机种子，为 0 时由服务端生成
+	QuestionSeconds int      `json:"question_seconds"` // 每题限时（秒），默认 30
+}
+
+// BattleRoomRequest 加入或开始对战
+type BattleRoomRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+	Code   string `json:"code" vd:"required"` // 房间码
+}
+
+// BattleEventsRequest 订阅对战房间的事件流 (?code=&user_id=)
+type BattleEventsRequest struct {
+	UserID string `query:"user_id" vd:"required"`
+	Code   string `query:"code" vd:"required"`
+}
+
+// BattleAnswerRequest 提交对战中一道题的答案
+type BattleAnswerRequest struct {
+	UserID        string `json:"user_id" vd:"required"`
+	Code          string `json:"code" vd:"required"`
+	QuestionIndex int    `json:"question_index"` // 题目在对战中的序号 (0-based)，防止把上一题的答案记到下一题
+	UserAnswer    string `json:"user_answer"`
+}
+
+// BattleHistoryRequest 查询用户的对战记录
+type BattleHistoryRequest struct {
+	UserID string `json:"user_id" vd:"required"`
+}
+
+// BattlePlayerScore 计分板上的一名玩家
+type BattlePlayerScore struct {
+	UserID    string `json:"user_id"`
+	Rank      int    `json:"rank"`
+	Score     int    `json:"score"`     // 答对得 100 分，再按剩余时间加最多 100 分
+	Correct   int    `json:"correct"`   // 答对题数
+	Answered  int    `json:"answered"`  // 作答题数
+	TimeMs    int64  `json:"time_ms"`   // 作答累计用时（毫秒）
+	Connected bool   `json:"connected"` // 是否正在接收事件
+}
+
+// BattleRoom 对战房间的状态，加入房间和订阅事件时返回
+type BattleRoom struct {
+	Code            string              `json:"code"`
+	Host            string              `json:"host"`
+	Course          string              `json:"course"`
+	ChapterChoice   []string            `json:"chapter_choice"`
+	Seed            int64               `json:"seed"`
+	QuestionSeconds int                 `json:"question_seconds"`
+	State           string              `json:"state"`         // waiting, playing, finished
+	CurrentIndex    int                 `json:"current_index"` // 当前题目的序号，未开始时为 -1
+	Total           int                 `json:"total"`
+	Players         []BattlePlayerScore `json:"players"`
+}
+
+// BattleQuestion 对战中揭晓的一道题（不含答案）
+type BattleQuestion struct {
+	Index    int            `json:"index"`
+	Total    int            `json:"total"`
+	Deadline time.Time      `json:"deadline"`
+	Question QuestionOutput `json:"question"`
+}
+
+// BattleAnswerStatus 某位玩家已作答（不透露对错，揭晓答案时一起公布）
+type BattleAnswerStatus struct {
+	Index    int    `json:"index"`
+	UserID   string `json:"user_id"`
+	Answered int    `json:"answered"` // 本题已作答人数
+	Players  int    `json:"players"`
+}
+
+// BattlePlayerAnswer 一位玩家在一道题上的作答
+type BattlePlayerAnswer struct {
+	UserID  string `json:"user_id"`
+	Answer  string `json:"answer"` // 未作答时为空
+	Correct bool   `json:"correct"`
+	Points  int    `json:"points"`
+	TimeMs  int64  `json:"time_ms"`
+}
+
+// BattleQuestionResult 一道题结束后公布的答案、各玩家作答和计分板
+type BattleQuestionResult struct {
+	Index         int                  `json:"index"`
+	CorrectAnswer string               `json:"correct_answer"`
+	Answers       []BattlePlayerAnswer `json:"answers"`
+	Scoreboard    []BattlePlayerScore  `json:"scoreboard"`
+}
+
+// BattleResult 保存在用户数据中的一场对战的最终结果
+type BattleResult struct {
+	Code       string              `json:"code"`
+	Course     string              `json:"course"`
+	Seed       int64               `json:"seed"`
+	Total      int                 `json:"total"`
+	Rank       int                 `json:"rank"`
+	Score      int                 `json:"score"`
+	Correct    int                 `json:"correct"`
+	FinishedAt time.Time           `json:"finished_at"`
+	Scoreboard []BattlePlayerScore `json:"scoreboard"`
+}
+
+// --- 接口响应 ---
+
+// APIError /api/v1 接口统一的错误内容
+type APIError struct {
+	Code    string                 `json:"code"`              // 稳定的错误码，如 invalid_request、not_found
+	Message string                 `json:"message"`           // 适合展示给用户的提示
+	Details map[string]interface{} `json:"details,omitempty"` // 可选，出错的字段等补充信息
+}
+
+// APIErrorResponse /api/v1 接口出错时的响应体
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// MessageResponse 只带提示信息的响应
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// CoursesResponse 课程、章节及题目数量
+type CoursesResponse struct {
+	Courses        []*CourseMeta `json:"courses"`
+	AllChaptersKey string        `json:"all_chapters_key"` // 表示全部章节的章节键
+}
+
+// SessionResponse 初始化会话的结果
+type SessionResponse struct {
+	UserID      string              `json:"user_id"`
+	Message     string              `json:"message"`
+	IsNewUser   bool                `json:"is_new_user"`
+	Assignments []StudentAssignment `json:"assignments,omitempty"` // 启用作业时为尚未完成的作业
+}
+
+// QuestionSetResponse 开始速刷、答题或错题回顾时返回的全部题目
+type QuestionSetResponse struct {
+	Message        string           `json:"message"`
+	TotalQuestions int              `json:"total_questions"`
+	Questions      []QuestionOutput `json:"questions"`
+	Seed           int64            `json:"seed"` // 本轮使用的随机种子，相同的种子和选择可以重现同样的顺序
+}
+
+// AssignmentStartResponse 开始做作业时返回的作业和题目
+type AssignmentStartResponse struct {
+	Message        string           `json:"message"`
+	Assignment     Assignment       `json:"assignment"`
+	TotalQuestions int              `json:"total_questions"`
+	Questions      []QuestionOutput `json:"questions"`
+}
+
+// AnswerFeedback 提交答案后的反馈：拆解与出处、本题用时和作业进度
+type AnswerFeedback struct {
+	Message            string              `json:"message"`
+	Explanation        string              `json:"explanation,omitempty"`
+	Reference          *QuestionReference  `json:"reference,omitempty"`
+	TimeSpentMs        int64               `json:"time_spent_ms,omitempty"` // 没有用时记录时省略
+	Slow               *bool               `json:"slow,omitempty"`          // 有用时记录时表示是否答得慢
+	AssignmentProgress *AssignmentProgress `json:"assignment_progress,omitempty"`
+}
+
+// TagListResponse 知识点标签及题目数
+type TagListResponse struct {
+	Total int          `json:"total"`
+	Tags  []TagSummary `json:"tags"`
+}
+
+// TagStatsResponse 用户按知识点标签的正确率
+type TagStatsResponse struct {
+	UserID string        `json:"user_id"`
+	Tags   []TagAccuracy `json:"tags"`
+}
+
+// NotesResponse 用户的全部笔记与收藏
+type NotesResponse struct {
+	Total int                `json:"total"`
+	Notes []UserQuestionNote `json:"notes"`
+}
+
+// NoteResponse 保存后的一条笔记
+type NoteResponse struct {
+	Message string           `json:"message"`
+	Note    UserQuestionNote `json:"note"`
+}
+
+// QuestionDifficultyResponse 按难度或区分度排序的题目
+type QuestionDifficultyResponse struct {
+	MinRespondents               int                       `json:"min_respondents"`                // 计算难度所需的最少作答人数
+	DiscriminationMinRespondents int                       `json:"discrimination_min_respondents"` // 计算区分度所需的最少作答人数
+	Total                        int                       `json:"total"`
+	Questions                    []QuestionDifficultyEntry `json:"questions"`
+}
+
+// AssignmentListResponse 学生的全部作业及进度
+type AssignmentListResponse struct {
+	Assignments []StudentAssignment `json:"assignments"`
+}
+
+// BattleAnswerResponse 对战中提交答案的确认
+type BattleAnswerResponse struct {
+	Message       string `json:"message"`
+	QuestionIndex int    `json:"question_index"`
+}
+
+// BattleHistoryResponse 用户的对战记录
+type BattleHistoryResponse struct {
+	Total   int            `json:"total"`
+	Battles []BattleResult `json:"battles"`
+}
+
+// ReadinessResponse 服务是否可以接收请求，以及各项检查的结果
+type ReadinessResponse struct {
+	Status string         `json:"status"` // "ready"、"not_ready" 或 "shutting_down"
+	Bank   BankReadiness  `json:"bank"`
+	Data   []DirReadiness `json:"data_dirs"`
+}
+
+// BankReadiness 启动时的题库加载情况
+type BankReadiness struct {
+	OK      bool               `json:"ok"`
+	Courses []CourseBankStatus `json:"courses"`
+}
+
+// CourseBankStatus 一门实体课程的题库加载情况
+type CourseBankStatus struct {
+	Course          string   `json:"course"`
+	QuestionCount   int      `json:"question_count"`
+	MissingChapters []string `json:"missing_chapters,omitempty"` // 没有题库文件的章节，不影响就绪
+	FailedChapters  []string `json:"failed_chapters,omitempty"`  // 题库文件无法读取或解析的章节
+}
+
+// DirReadiness 数据目录是否可写
+type DirReadiness struct {
+	Path  string `json:"path"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 题目来源：在所选章节的题目中进一步筛选
+const (
+	questionSourceAll        = "all"        // 所选章节的全部题目（默认）
+	questionSourceBookmarked = "bookmarked" // 只取当前用户收藏的题目
+	questionSourceSlow       = "slow"       // 速度训练：只取当前用户最近一次答得慢的题目（无论是否答对）
+	questionSourceHardest    = "hardest"    // 只取全局难度指数最低的题目（所有用户首次作答正确率最低）
+)
+
+// loadUserNotes 加载用户的笔记与收藏，键为题目ID
+func loadUserNotes(userID string) (map[string]UserQuestionNote, error) {
+	notes := make(map[string]UserQuestionNote)
+	if err := loadUserJSONData(userID, questionNotesFile, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// withUserNotes 为题目输出附加当前用户的笔记与收藏状态。加载失败时只记录日志，不影响题目返回。
+func withUserNotes(userID string, output []QuestionOutput) []QuestionOutput {
+	notes, err := loadUserNotes(userID)
+	if err != nil {
+		slog.Warn("加载笔记失败，题目将不附带笔记", logKeyUserID, userID, "error", err)
+		return output
+	}
+	for i := range output {
+		if note, ok := notes[output[i].QuestionID]; ok {
+			output[i].Bookmarked = note.Bookmarked
+			output[i].Note = note.Note
+		}
+	}
+	return output
+}
+
+// applyQuestionSource 按题目来源筛选题目，保持原有顺序
+func applyQuestionSource(userID, source string, questions []Question) ([]Question, error) {
+	switch source {
+	case "", questionSourceAll:
+		return questions, nil
+	case questionSourceBookmarked:
+		notes, err := loadUserNotes(userID)
+		if err != nil {
+			return nil, &userDataError{Message: "加载用户笔记失败", Err: err}
+		}
+		filtered := []Question{}
+		for _, q := range questions {
+			if notes[questionIDOf(q)].Bookmarked {
+				filtered = append(filtered, q)
+			}
+		}
+		return filtered, nil
+	case questionSourceSlow:
+		return filterSlowQuestions(userID, questions)
+	case questionSourceHardest:
+		return filterHardestQuestions(questions), nil
+	default:
+		return nil, newOptionError("source", source, questionSourceAll, questionSourceBookmarked, questionSourceSlow, questionSourceHardest)
+	}
+}
+
+// normalizeQuestionID 接受 "课程_章节_索引" 或带 "quiz_" 前缀的题目ID，返回存在的题目ID
+func normalizeQuestionID(questionID string) (string, bool) {
+	id := strings.TrimPrefix(questionID, "quiz_")
+	_, ok := questionMapByID[id]
+	return id, ok
+}
+
+// UserNotesListHandler 返回用户的全部笔记与收藏
+func UserNotesListHandler(ctx context.Context, c *app.RequestContext) {
+	var req UserNotesListRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := listUserNotes(ctx, req.UserID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// listUserNotes 返回用户的全部笔记与收藏，按修改时间从新到旧排列
+func listUserNotes(ctx context.Context, userID string) (NotesResponse, error) {
+	notes, err := loadUserNotes(userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "加载笔记失败", logKeyUserID, userID, "error", err)
+		return NotesResponse{}, &userDataError{Message: "加载用户笔记失败", Err: err}
+	}
+	list := make([]UserQuestionNote, 0, len(notes))
+	for _, note := range notes {
+		list = append(list, note)
+	}
+	// 最近修改的排在前面，修改时间相同时按题目ID排序，保证每次返回的顺序一致
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].UpdatedAt.Equal(list[j].UpdatedAt) {
+			return list[i].UpdatedAt.After(list[j].UpdatedAt)
+		}
+		return list[i].QuestionID < list[j].QuestionID
+	})
+	return NotesResponse{Total: len(list), Notes: list}, nil
+}
+
+// UpsertNoteHandler 新增或修改用户对一道题的笔记与收藏。
+// 笔记为空且未收藏时删除该记录。
+func UpsertNoteHandler(ctx context.Context, c *app.RequestContext) {
+	var req UpsertNoteRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	note, err := upsertUserNote(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, NoteResponse{Message: "笔记已保存", Note: note})
+}
+
+// upsertUserNote 保存用户对一道题的笔记与收藏，题目不存在时返回 errUnknownQuestion
+func upsertUserNote(ctx context.Context, req UpsertNoteRequest) (UserQuestionNote, error) {
+	questionID, ok := normalizeQuestionID(req.QuestionID)
+	if !ok {
+		return UserQuestionNote{}, errUnknownQuestion.with(req.QuestionID)
+	}
+
+	notes, err := loadUserNotes(req.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "加载笔记失败", logKeyUserID, req.UserID, "error", err)
+		return UserQuestionNote{}, &userDataError{Message: "加载用户笔记失败", Err: err}
+	}
+
+	note, exists := notes[questionID]
+	if !exists {
+		note = UserQuestionNote{QuestionID: questionID}
+	}
+	if req.Bookmarked != nil {
+		note.Bookmarked = *req.Bookmarked
+	}
+	if req.Note != nil {
+		note.Note = strings.TrimSpace(*req.Note)
+	}
+	note.UpdatedAt = time.Now()
+
+	if !note.Bookmarked && note.Note == "" {
+		delete(notes, questionID)
+	} else {
+		notes[questionID] = note
+	}
+
+	if err := saveUserJSONData(req.UserID, questionNotesFile, notes); err != nil {
+		slog.ErrorContext(ctx, "保存笔记失败", logKeyUserID, req.UserID, "error", err)
+		return UserQuestionNote{}, &userDataError{Message: "保存用户笔记失败", Err: err}
+	}
+	return note, nil
+}
+
+// DeleteNoteHandler 删除用户对一道题的笔记与收藏
+func DeleteNoteHandler(ctx context.Context, c *app.RequestContext) {
+	var req DeleteNoteRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	if err := deleteUserNote(ctx, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(consts.StatusNoContent)
+}
+
+// deleteUserNote 删除用户对一道题的笔记与收藏，没有记录时不做任何事
+func deleteUserNote(ctx context.Context, req DeleteNoteRequest) error {
+	questionID := strings.TrimPrefix(req.QuestionID, "quiz_")
+
+	notes, err := loadUserNotes(req.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "加载笔记失败", logKeyUserID, req.UserID, "error", err)
+		return &userDataError{Message: "加载用户笔记失败", Err: err}
+	}
+	if _, ok := notes[questionID]; !ok {
+		return nil
+	}
+	delete(notes, questionID)
+	if err := saveUserJSONData(req.UserID, questionNotesFile, notes); err != nil {
+		slog.ErrorContext(ctx, "保存笔记失败", logKeyUserID, req.UserID, "error", err)
+		return &userDataError{Message: "保存用户笔记失败", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// --- 题库旁路文件帮助函数 ---
+// 旁路文件保存在 bankOverlayDir 下，以题目ID ("课程_章节_索引") 为键覆盖或补充嵌入题库的内容，
+// 这样清洗后的题库文件可以保持原样。
+
+// getOverlayPath 获取旁路文件的完整路径
+func getOverlayPath(fileName string) string {
+	return filepath.Join(bankOverlayDir, fileName)
+}
+
+// loadOverlayJSON 加载旁路JSON文件到指定的结构体。文件不存在或为空时保持目标不变。
+func loadOverlayJSON(fileName string, target interface{}) error {
+	filePath := getOverlayPath(fileName)
+	data, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil // 文件不存在表示还没有旁路数据
+	}
+	if err != nil {
+		return fmt.Errorf("读取旁路文件 %s 失败: %w", filePath, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, target)
+}
+
+// saveOverlayJSON 将旁路数据序列化为JSON并保存到文件
+func saveOverlayJSON(fileName string, data interface{}) error {
+	if err := os.MkdirAll(bankOverlayDir, os.ModePerm); err != nil {
+		countStorageWriteFailure(storeOverlay)
+		return err
+	}
+	filePath := getOverlayPath(fileName)
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON序列化旁路数据到 %s 失败: %w", filePath, err)
+	}
+	if err := writeFileAtomic(filePath, jsonData); err != nil {
+		countStorageWriteFailure(storeOverlay)
+		return err
+	}
+	return nil
+}
+
+// getQuestionID 返回题目的唯一ID，格式为 "课程_章节号_题目在文件中的索引"
+func getQuestionID(course, chapterKey string, index int) string {
+	return fmt.Sprintf("%s_%s_%d", course, chapterKey, index)
+}
+
+// questionIDOf 返回已加载题目的唯一ID
+func questionIDOf(q Question) string {
+	return getQuestionID(q.OriginalCourse, q.OriginalChapterKey, q.OriginalIndex)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 纠错报告状态
+const (
+	reportStatusOpen      = "open"
+	reportStatusResolved  = "resolved"
+	reportStatusDismissed = "dismissed"
+)
+
+const maxReportReasonLength = 500 // 报告理由的最大长度（字符）
+
+var (
+	questionReports []QuestionReport // 全部纠错报告（来自旁路文件）
+	reportsMu       sync.Mutex       // 保护 questionReports 及其文件
+)
+
+// loadQuestionReports 从旁路文件加载纠错报告
+func loadQuestionReports() {
+	reports := []QuestionReport{}
+	if err := loadOverlayJSON(questionReportsFile, &reports); err != nil {
+		slog.Error("加载纠错报告文件失败", "error", err)
+		reports = []QuestionReport{}
+	}
+	reportsMu.Lock()
+	questionReports = reports
+	reportsMu.Unlock()
+	slog.Info("加载纠错报告", "count", len(reports))
+}
+
+// saveQuestionReportsLocked 保存纠错报告，调用方需持有 reportsMu
+func saveQuestionReportsLocked(reports []QuestionReport) error {
+	if err := saveOverlayJSON(questionReportsFile, reports); err != nil {
+		return err
+	}
+	questionReports = reports
+	return nil
+}
+
+// resolveQuestionReports 将一道题所有未处理的报告标记为指定状态，返回处理的报告数
+func resolveQuestionReports(questionID, status string) (int, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+
+	updated := make([]QuestionReport, len(questionReports))
+	copy(updated, questionReports)
+	count := 0
+	now := time.Now()
+	for i := range updated {
+		if updated[i].QuestionID == questionID && updated[i].Status == reportStatusOpen {
+			updated[i].Status = status
+			updated[i].ResolvedAt = now
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, saveQuestionReportsLocked(updated)
+}
+
+// ReportQuestionHandler 处理用户对题目的纠错报告。
+// 同一用户对同一道题未处理的报告只保留一条，再次提交会更新理由和建议答案。
+func ReportQuestionHandler(ctx context.Context, c *app.RequestContext) {
+	var req ReportQuestionRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if req.UserID == "" || reason == "" {
+		writeError(c, newAppError(codeMissingField, "user_id, reason"))
+		return
+	}
+	if len([]rune(reason)) > maxReportReasonLength {
+		writeError(c, newAppError(codeTooLong, "理由", maxReportReasonLength))
+		return
+	}
+	questionID, ok := normalizeQuestionID(req.QuestionID)
+	if !ok {
+		writeError(c, errUnknownQuestion.with(req.QuestionID))
+		return
+	}
+	proposed := normalizeAnswer(req.ProposedAnswer)
+	if q, _ := lookupQuestion(questionID); req.ProposedAnswer != "" && !answerMatchesOptions(proposed, q.Options) {
+		writeError(c, newAppError(codeAnswerMismatch, req.ProposedAnswer))
+		return
+	}
+
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+
+	updated := make([]QuestionReport, len(questionReports), len(questionReports)+1)
+	copy(updated, questionReports)
+	now := time.Now()
+	var report *QuestionReport
+	for i := range updated {
+		if updated[i].QuestionID == questionID && updated[i].UserID == req.UserID && updated[i].Status == reportStatusOpen {
+			report = &updated[i]
+			break
+		}
+	}
+	if report == nil {
+		updated = append(updated, QuestionReport{
+			ID:         fmt.Sprintf("r%d", now.UnixNano()),
+			QuestionID: questionID,
+			UserID:     req.UserID,
+			Status:     reportStatusOpen,
+			CreatedAt:  now,
+		})
+		report = &updated[len(updated)-1]
+	}
+	report.Reason = reason
+	report.ProposedAnswer = proposed
+	report.UpdatedAt = now // 同一用户对同一道题的未处理报告只保留一份，修改时保留首次报告的时间
+
+	if err := saveQuestionReportsLocked(updated); err != nil {
+		slog.ErrorContext(ctx, "保存纠错报告失败", logKeyUserID, req.UserID, "question_id", questionID, "error", err)
+		writeError(c, &userDataError{Message: "保存纠错报告失败", Err: err})
+		return
+	}
+	slog.InfoContext(ctx, "用户报告题目有误", logKeyUserID, req.UserID, "question_id", questionID, "proposed_answer", proposed)
+	c.JSON(consts.StatusOK, utils.H{"message": "感谢反馈，报告已提交", "report": *report})
+}
+
+// ReportsListHandler 按题目汇总纠错报告，供管理员审核。
+// 查询参数 status 默认为 "open"，传 "all" 返回全部状态的报告。
+func ReportsListHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	status := c.DefaultQuery("status", reportStatusOpen)
+
+	reportsMu.Lock()
+	reports := make([]QuestionReport, len(questionReports))
+	copy(reports, questionReports)
+	reportsMu.Unlock()
+
+	summaries := make(map[string]*QuestionReportSummary)
+	for _, report := range reports {
+		if status != "all" && report.Status != status {
+			continue
+		}
+		summary, ok := summaries[report.QuestionID]
+		if !ok {
+			q, _ := lookupQuestion(report.QuestionID)
+			summary = &QuestionReportSummary{
+				QuestionID:      report.QuestionID,
+				Course:          q.OriginalCourse,
+				Chapter:         q.OriginalChapterKey,
+				QuestionNumber:  q.QuestionNumber,
+				QuestionText:    q.QuestionText,
+				CurrentAnswer:   q.CorrectAnswer,
+				Corrected:       isQuestionCorrected(report.QuestionID),
+				ProposedAnswers: make(map[string]int),
+				FirstReportedAt: report.CreatedAt,
+			}
+			summaries[report.QuestionID] = summary
+		}
+		summary.ReportCount++
+		summary.Reasons = append(summary.Reasons, report.Reason)
+		summary.Reporters = append(summary.Reporters, report.UserID)
+		if report.ProposedAnswer != "" {
+			summary.ProposedAnswers[report.ProposedAnswer]++
+		}
+		if report.CreatedAt.Before(summary.FirstReportedAt) {
+			summary.FirstReportedAt = report.CreatedAt
+		}
+		lastReportedAt := report.UpdatedAt
+		if lastReportedAt.IsZero() {
+			lastReportedAt = report.CreatedAt // 旧数据没有修改时间
+		}
+		if lastReportedAt.After(summary.LastReportedAt) {
+			summary.LastReportedAt = lastReportedAt
+		}
+	}
+
+	list := make([]*QuestionReportSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		list = append(list, summary)
+	}
+	// 被报告次数多的排在前面，次数相同时最近报告的在前
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ReportCount != list[j].ReportCount {
+			return list[i].ReportCount > list[j].ReportCount
+		}
+		return list[i].LastReportedAt.After(list[j].LastReportedAt)
+	})
+	c.JSON(consts.StatusOK, utils.H{"status": status, "total_questions": len(list), "reports": list})
+}
+
+// ResolveReportsHandler 将一道题所有未处理的报告标记为已解决或驳回
+func ResolveReportsHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req ResolveReportsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	if req.Status != reportStatusResolved && req.Status != reportStatusDismissed {
+		writeError(c, newOptionError("status", req.Status, reportStatusResolved, reportStatusDismissed))
+		return
+	}
+	questionID := strings.TrimPrefix(req.QuestionID, "quiz_")
+	count, err := resolveQuestionReports(questionID, req.Status)
+	if err != nil {
+		slog.ErrorContext(ctx, "更新纠错报告失败", "question_id", questionID, "error", err)
+		writeError(c, &userDataError{Message: "保存纠错报告失败", Err: err})
+		return
+	}
+	c.JSON(consts.StatusOK, utils.H{"message": "报告状态已更新", "question_id": questionID, "updated": count})
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 服务层 ---
+// HTTP 接口和命令行子命令共用的业务逻辑。这里的函数不依赖请求上下文，
+// 读写用户数据失败时返回 *userDataError，由调用方决定如何展示。
+
+// physicalCourses 返回全部实体课程，按目录顺序
+func physicalCourses() []string {
+	var courses []string
+	for _, key := range courseOrder {
+		if !isVirtualCourse(key) {
+			courses = append(courses, key)
+		}
+	}
+	return courses
+}
+
+// add 把一道题的统计累加到汇总中
+func (a *AnswerCounts) add(stat UserQuestionStat, answered bool) {
+	a.QuestionCount++
+	if answered && stat.CorrectCount+stat.ErrorCount > 0 {
+		a.AnsweredQuestions++
+		a.CorrectCount += stat.CorrectCount
+		a.ErrorCount += stat.ErrorCount
+	}
+}
+
+// finish 计算正确率
+func (a *AnswerCounts) finish() {
+	if attempts := a.CorrectCount + a.ErrorCount; attempts > 0 {
+		a.Accuracy = float64(a.CorrectCount) / float64(attempts)
+	}
+}
+
+// computeUserStats 按实体课程和章节汇总用户的作答统计
+func computeUserStats(userID string) (UserStatsSummary, error) {
+	summary := UserStatsSummary{UserID: userID, Courses: []CourseStatsSummary{}}
+	userStats, err := loadUserStats(userID)
+	if err != nil {
+		return summary, err
+	}
+	for _, stat := range userStats {
+		if stat.LastAnswered.After(summary.LastAnswered) {
+			summary.LastAnswered = stat.LastAnswered
+		}
+	}
+
+	for _, course := range physicalCourses() {
+		meta := courseCatalog[course]
+		courseSummary := CourseStatsSummary{Course: course, Title: meta.Title, Chapters: []ChapterStatsSummary{}}
+		questionsByChapter, _, _ := getCourseQuestionBank(course)
+		for _, chapter := range meta.Chapters {
+			chapterSummary := ChapterStatsSummary{Key: chapter.Key, Title: chapter.Title}
+			for _, q := range questionsByChapter[chapter.ChapterKey] {
+				stat, answered := userStatFor(userStats, course, q.OriginalChapterKey, q.QuestionNumber)
+				chapterSummary.add(stat, answered)
+				courseSummary.add(stat, answered)
+				summary.add(stat, answered)
+			}
+			chapterSummary.finish()
+			courseSummary.Chapters = append(courseSummary.Chapters, chapterSummary)
+		}
+		incorrect, err := loadUserIncorrectForCourse(userID, course)
+		if err != nil {
+			return summary, &userDataError{Message: "加载用户错题本失败", Err: err}
+		}
+		courseSummary.IncorrectCount = len(incorrect)
+		summary.IncorrectCount += len(incorrect)
+		courseSummary.finish()
+		summary.Courses = append(summary.Courses, courseSummary)
+	}
+	summary.finish()
+	return summary, nil
+}
+
+// computeTagAccuracy 按知识点标签统计用户的正确率，course 为空时统计所有实体课程。
+// 正确率低的排在前面，方便找出薄弱知识点；没答过的排在最后。
+func computeTagAccuracy(userID, course string) ([]TagAccuracy, error) {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return nil, err
+	}
+
+	userStats, err := loadUserStats(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accuracy := make(map[string]*TagAccuracy)
+	for _, q := range questions {
+		tags := getQuestionTags(questionIDOf(q))
+		if len(tags) == 0 {
+			continue
+		}
+		stat, answered := userStatFor(userStats, q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+		for _, tag := range tags {
+			entry, ok := accuracy[tag]
+			if !ok {
+				entry = &TagAccuracy{Tag: tag}
+				accuracy[tag] = entry
+			}
+			entry.TotalQuestions++
+			if answered && stat.CorrectCount+stat.ErrorCount > 0 {
+				entry.QuestionCount++
+				entry.CorrectCount += stat.CorrectCount
+				entry.ErrorCount += stat.ErrorCount
+			}
+		}
+	}
+
+	list := make([]TagAccuracy, 0, len(accuracy))
+	for _, entry := range accuracy {
+		if attempts := entry.CorrectCount + entry.ErrorCount; attempts > 0 {
+			entry.Accuracy = float64(entry.CorrectCount) / float64(attempts)
+		}
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].QuestionCount == 0) != (list[j].QuestionCount == 0) {
+			return list[j].QuestionCount == 0
+		}
+		if list[i].Accuracy != list[j].Accuracy {
+			return list[i].Accuracy < list[j].Accuracy
+		}
+		return list[i].Tag < list[j].Tag
+	})
+	return list, nil
+}
+
+// listUsers 列出用户数据目录中的全部用户
+func listUsers() ([]UserSummary, error) {
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil {
+		return nil, &userDataError{Message: "读取用户数据目录失败", Err: err}
+	}
+	users := []UserSummary{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		user := UserSummary{UserID: userID}
+		userStats := make(map[string]UserQuestionStat)
+		if err := loadUserJSONData(userID, questionStatsFile, &userStats); err != nil {
+			slog.Warn("读取统计数据失败", logKeyUserID, userID, "error", err)
+		}
+		for _, stat := range userStats {
+			if stat.CorrectCount+stat.ErrorCount > 0 {
+				user.AnsweredQuestions++
+			}
+			if stat.LastAnswered.After(user.LastAnswered) {
+				user.LastAnswered = stat.LastAnswered
+			}
+		}
+		for _, course := range physicalCourses() {
+			incorrect, err := loadUserIncorrectForCourse(userID, course)
+			if err != nil {
+				slog.Warn("读取错题本失败", logKeyUserID, userID, "course", course, "error", err)
+			}
+			user.IncorrectCount += len(incorrect)
+		}
+		if notes, err := loadUserNotes(userID); err == nil {
+			user.NoteCount = len(notes)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// loadUserIncorrectForCourses 加载用户的错题，course 为空时合并所有实体课程的错题本
+func loadUserIncorrectForCourses(userID, course string) ([]UserIncorrectQuestion, error) {
+	courses := []string{course}
+	if course == "" {
+		courses = physicalCourses()
+	}
+	result := []UserIncorrectQuestion{}
+	for _, key := range courses {
+		incorrect, err := loadUserIncorrectForCourse(userID, key)
+		if err != nil {
+			return nil, &userDataError{Message: "加载用户错题本失败", Err: err}
+		}
+		result = append(result, incorrect...)
+	}
+	return result, nil
+}
+
+// backupUserFile 把用户数据文件重命名为带时间戳的 .bak 文件，相当于清空。文件不存在时返回 false。
+func backupUserFile(userID, fileName string) (bool, error) {
+	path := getUserDataPath(userID, fileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		slog.Error("检查用户文件失败", logKeyUserID, userID, "file", fileName, "error", err)
+		return false, err
+	}
+	backupPath := path + time.Now().Format(".2006_01_02_15_04_05.bak")
+	if err := os.Rename(path, backupPath); err != nil {
+		slog.Error("清理用户文件失败", logKeyUserID, userID, "file", fileName, "error", err)
+		return false, err
+	}
+	slog.Info("用户文件已清理", logKeyUserID, userID, "file", fileName, "backup", filepath.Base(backupPath))
+	return true, nil
+}
+
+// clearUserIncorrect 清空用户的错题本（保留备份），course 为空时清空所有课程（含旧版统一的习概错题文件）。
+// 某个文件失败时继续处理其余文件，返回清理掉的文件名。
+func clearUserIncorrect(userID, course string) ([]string, error) {
+	var fileNames []string
+	if course == "" {
+		fileNames = []string{maogaiIncorrectQuestionsFile, xigaiLiIncorrectQuestionsFile, xigaiYangIncorrectQuestionsFile, xigaiIncorrectQuestionsFile}
+	} else {
+		for _, member := range resolveCourseMembers(course) {
+			fileNames = append(fileNames, getIncorrectQuestionsFileName(member.Course))
+		}
+	}
+
+	cleared := []string{}
+	var errs []error
+	for _, fileName := range fileNames {
+		ok, err := backupUserFile(userID, fileName)
+		if err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			cleared = append(cleared, fileName)
+		}
+	}
+	if len(errs) > 0 {
+		return cleared, &userDataError{Message: "清理用户错题本时发生部分或全部失败", Err: errors.Join(errs...)}
+	}
+	return cleared, nil
+}
+
+// 题库中已知的题型
+var knownQuestionTypes = map[string]bool{"单选题": true, "多选题": true, "不定项": true, "判断题": true}
+
+// validateQuestionBank 校验课程（已应用修正）的题目，course 为空时校验所有实体课程
+func validateQuestionBank(course string) ([]BankIssue, error) {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return nil, err
+	}
+	return validateQuestions(questions), nil
+}
+
+// validateQuestions 检查题目的题干、题型、选项和答案，以及同一章节内的重复题号和同一课程内的重复题干
+func validateQuestions(questions []Question) []BankIssue {
+	issues := []BankIssue{}
+	report := func(q Question, severity, format string, args ...interface{}) {
+		issues = append(issues, BankIssue{
+			QuestionID:     questionIDOf(q),
+			Course:         q.OriginalCourse,
+			Chapter:        q.OriginalChapterKey,
+			QuestionNumber: q.QuestionNumber,
+			Severity:       severity,
+			Problem:        fmt.Sprintf(format, args...),
+		})
+	}
+
+	seenNumbers := make(map[string]string) // 课程_章节_题号 -> 题目ID
+	seenTexts := make(map[string]string)   // 课程 + 题干 -> 题目ID
+	for _, q := range questions {
+		numberKey := getQuestionStatKey(q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+		if other, ok := seenNumbers[numberKey]; ok {
+			report(q, "error", "题号 %s 与 %s 重复，统计和错题会混在一起", q.QuestionNumber, other)
+		} else {
+			seenNumbers[numberKey] = questionIDOf(q)
+		}
+		if strings.TrimSpace(q.QuestionText) == "" {
+			report(q, "error", "题干为空")
+		} else {
+			textKey := q.OriginalCourse + "\x00" + strings.TrimSpace(q.QuestionText)
+			if other, ok := seenTexts[textKey]; ok {
+				report(q, "warning", "题干与 %s 相同", other)
+			} else {
+				seenTexts[textKey] = questionIDOf(q)
+			}
+		}
+		if !knownQuestionTypes[q.QuestionType] {
+			report(q, "warning", "未知的题型: %q", q.QuestionType)
+		}
+		if len(q.Options) < 2 {
+			report(q, "error", "选项少于两个")
+		}
+		for letter, text := range q.Options {
+			if strings.TrimSpace(text) == "" {
+				report(q, "warning", "选项 %s 为空", letter)
+			}
+		}
+
+		answer := normalizeAnswer(q.CorrectAnswer)
+		switch {
+		case answer == "":
+			report(q, "error", "缺少答案")
+		case !answerMatchesOptions(answer, q.Options):
+			report(q, "error", "答案 %s 不在选项中", q.CorrectAnswer)
+		case q.QuestionType == "单选题" && len(answer) > 1:
+			report(q, "error", "单选题有多个答案: %s", q.CorrectAnswer)
+		case q.QuestionType == "多选题" && len(answer) < 2:
+			report(q, "warning", "多选题只有一个答案: %s", q.CorrectAnswer)
+		}
+		if answer != "" && answer != q.CorrectAnswer {
+			report(q, "warning", "答案格式不规范: %q，应为 %q", q.CorrectAnswer, answer)
+		}
+	}
+	return issues
+}
+
+// exportQuestionBank 把课程的题目（已应用修正，并带上旁路文件中的拆解）按 "课程/章节.json" 写入目录，
+// 导出的目录可以直接作为外部题库目录使用。course 为空时导出所有实体课程，返回写入的文件路径。
+func exportQuestionBank(course, dir string) ([]string, error) {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return nil, err
+	}
+	for i := range questions {
+		questions[i].Explanation, questions[i].Reference = getQuestionExplanation(questions[i])
+	}
+	return writeChapterFiles(dir, questions)
+}
+
+// writeChapterFiles 把题目按来源课程和章节分组，以 "课程/章节.json" 写入目录，返回写入的文件路径
+func writeChapterFiles(dir string, questions []Question) ([]string, error) {
+	var order []string
+	chapters := make(map[string][]Question)
+	for _, q := range questions {
+		key := q.OriginalCourse + "/" + q.OriginalChapterKey
+		if _, ok := chapters[key]; !ok {
+			order = append(order, key)
+		}
+		chapters[key] = append(chapters[key], q)
+	}
+
+	files := []string{}
+	for _, key := range order {
+		path := filepath.Join(dir, filepath.FromSlash(key)+".json")
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return files, err
+		}
+		data, err := json.MarshalIndent(chapters[key], "", "  ")
+		if err != nil {
+			return files, err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// UserStatsHandler 返回用户按课程和章节汇总的作答统计
+func UserStatsHandler(ctx context.Context, c *app.RequestContext) {
+	var req UserStatsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	summary, err := computeUserStats(req.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "汇总统计失败", logKeyUserID, req.UserID, "error", err)
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, summary)
+}
+
+// BankValidateHandler 校验题库并返回发现的问题 (?course=)
+func BankValidateHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	issues, err := validateQuestionBank(c.Query("course"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, utils.H{"total_issues": len(issues), "issues": issues})
+}
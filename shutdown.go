@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// shutdownPollInterval 等待请求和写入完成时的检查间隔
+const shutdownPollInterval = 50 * time.Millisecond
+
+var (
+	activeRequests atomic.Int64 // 正在处理的请求数（包括 SSE 长连接）
+	shuttingDown   atomic.Bool  // 已开始退出，就绪检查返回 503
+	stopping       = make(chan struct{})
+	stopOnce       sync.Once
+	startedAt      = time.Now()
+
+	// 开始退出时被中断的对战数和关闭的事件流数，用于退出汇总
+	interruptedBattles, closedStreams int
+)
+
+// trackActiveRequests 统计正在处理的请求数，退出时等待其归零
+func trackActiveRequests(ctx context.Context, c *app.RequestContext) {
+	activeRequests.Add(1)
+	defer activeRequests.Add(-1)
+	c.Next(ctx)
+}
+
+// beginShutdown 开始退出：标记为未就绪、通知后台任务停止，并关闭对战事件流，让长连接尽快结束。
+// 由 Hertz 的 OnShutdown 钩子在停止接收新连接时调用，可以重复调用。
+func beginShutdown() {
+	stopOnce.Do(func() {
+		shuttingDown.Store(true)
+		close(stopping)
+		interruptedBattles, closedStreams = shutdownBattles()
+		slog.Info("开始退出，不再接收新请求", "interrupted_battles", interruptedBattles, "closed_streams", closedStreams)
+	})
+}
+
+// waitForZero 等待计数归零，超过截止时间时返回剩余的数量
+func waitForZero(counter *atomic.Int64, deadline time.Time) int64 {
+	for {
+		n := counter.Load()
+		if n <= 0 || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+}
+
+// finishShutdown 在 Hertz 停止后收尾：等待仍在处理的请求、保存内存会话和全局作答统计、等待数据写入完成，最后打印退出汇总
+func finishShutdown(timeout time.Duration, background *sync.WaitGroup) {
+	beginShutdown()
+	deadline := time.Now().Add(timeout)
+
+	unfinishedRequests := waitForZero(&activeRequests, deadline)
+	background.Wait()
+
+	savedSessions, sessionErr := persistSessions()
+	if sessionErr != nil {
+		slog.Error("保存内存会话失败", "error", sessionErr)
+	}
+	if err := flushGlobalStats(); err != nil {
+		slog.Error("保存全局作答统计失败", "error", err)
+	}
+	unfinishedWrites := waitForZero(&activeWrites, deadline)
+
+	var totalRequests uint64
+	serverMetrics.mu.Lock()
+	for _, count := range serverMetrics.requests {
+		totalRequests += count
+	}
+	serverMetrics.mu.Unlock()
+
+	slog.Info("退出汇总", "uptime", time.Since(startedAt).Round(time.Second), "requests", totalRequests, "unfinished_requests", unfinishedRequests,
+		"saved_sessions", savedSessions, "interrupted_battles", interruptedBattles, "unfinished_writes", unfinishedWrites)
+	if unfinishedRequests > 0 || unfinishedWrites > 0 {
+		slog.Warn("等待超时后仍有请求或写入没有完成，相关数据可能没有保存", "timeout", timeout)
+	}
+}
+
+// persistSessions 把有进行中模式或计时的内存会话保存到各用户的 session.json，下次启动时恢复
+func persistSessions() (int, error) {
+	sessionsMu.RLock()
+	sessions := make([]*UserSession, 0, len(userSessions))
+	for _, session := range userSessions {
+		sessions = append(sessions, session)
+	}
+	sessionsMu.RUnlock()
+
+	saved := 0
+	var firstErr error
+	for _, session := range sessions {
+		session.mu.Lock()
+		if session.CurrentMode == "" && len(session.ServedAt) == 0 {
+			session.mu.Unlock()
+			continue
+		}
+		err := saveUserJSONData(session.UserID, sessionFile, session)
+		session.mu.Unlock()
+		if err != nil {
+			slog.Error("保存会话失败", logKeyUserID, session.UserID, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		saved++
+	}
+	return saved, firstErr
+}
+
+// restoreSessions 恢复上次退出时保存的会话，恢复后删除文件，避免之后异常退出时恢复过时的会话
+func restoreSessions() int {
+	entries, err := os.ReadDir(userDataBaseDir)
+	if err != nil {
+		slog.Warn("读取用户数据目录失败，跳过恢复会话", "error", err)
+		return 0
+	}
+	restored := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID := entry.Name()
+		filePath := getUserDataPath(userID, sessionFile)
+		if _, err := os.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		session := &UserSession{}
+		if err := loadUserJSONData(userID, sessionFile, session); err != nil {
+			slog.Warn("恢复会话失败", logKeyUserID, userID, "error", err)
+			continue
+		}
+		session.UserID = userID
+		sessionsMu.Lock()
+		userSessions[userID] = session
+		sessionsMu.Unlock()
+		if err := os.Remove(filePath); err != nil {
+			slog.Warn("删除已恢复的会话文件失败", logKeyUserID, userID, "error", err)
+		}
+		restored++
+	}
+	return restored
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var (
+	questionTags map[string][]string // 题目ID -> 知识点标签（来自旁路文件）
+	tagRules     []TagRule           // 自动推荐标签的关键词规则
+	tagsMu       sync.RWMutex        // 保护 questionTags 和 tagRules
+)
+
+// defaultTagRules 内置的关键词规则，可以用 bank_overlays/tag_rules.json 覆盖
+var defaultTagRules = []TagRule{
+	{Tag: "毛泽东思想", Keywords: []string{"毛泽东思想"}},
+	{Tag: "新民主主义革命", Keywords: []string{"新民主主义"}},
+	{Tag: "社会主义改造", Keywords: []string{"社会主义改造", "一化三改", "过渡时期总路线"}},
+	{Tag: "邓小平理论", Keywords: []string{"邓小平理论", "社会主义初级阶段"}},
+	{Tag: "“三个代表”重要思想", Keywords: []string{"三个代表"}},
+	{Tag: "科学发展观", Keywords: []string{"科学发展观"}},
+	{Tag: "实事求是", Keywords: []string{"实事求是"}},
+	{Tag: "改革开放", Keywords: []string{"改革开放"}},
+	{Tag: "社会主要矛盾", Keywords: []string{"主要矛盾"}},
+	{Tag: "党的十九大", Keywords: []string{"十九大"}},
+	{Tag: "党的二十大", Keywords: []string{"二十大"}},
+	{Tag: "两个确立", Keywords: []string{"两个确立"}},
+	{Tag: "中国式现代化", Keywords: []string{"中国式现代化"}},
+	{Tag: "新发展理念", Keywords: []string{"新发展理念", "创新、协调、绿色、开放、共享"}},
+	{Tag: "高质量发展", Keywords: []string{"高质量发展"}},
+	{Tag: "共同富裕", Keywords: []string{"共同富裕"}},
+	{Tag: "全过程人民民主", Keywords: []string{"全过程人民民主"}},
+	{Tag: "全面依法治国", Keywords: []string{"依法治国", "法治"}},
+	{Tag: "文化自信", Keywords: []string{"文化自信", "文化强国"}},
+	{Tag: "生态文明", Keywords: []string{"生态文明", "绿水青山"}},
+	{Tag: "总体国家安全观", Keywords: []string{"总体国家安全观", "国家安全"}},
+	{Tag: "强军思想", Keywords: []string{"强军", "国防和军队"}},
+	{Tag: "一国两制", Keywords: []string{"一国两制", "祖国统一", "祖国完全统一"}},
+	{Tag: "人类命运共同体", Keywords: []string{"人类命运共同体"}},
+	{Tag: "全面从严治党", Keywords: []string{"从严治党", "自我革命"}},
+}
+
+// loadQuestionTags 从旁路文件加载题目标签和关键词规则
+func loadQuestionTags() {
+	tags := make(map[string][]string)
+	if err := loadOverlayJSON(tagsFile, &tags); err != nil {
+		slog.Error("加载题目标签文件失败", "error", err)
+		tags = make(map[string][]string)
+	}
+	rules := []TagRule{}
+	if err := loadOverlayJSON(tagRulesFile, &rules); err != nil {
+		slog.Error("加载标签规则文件失败，将使用内置规则", "error", err)
+		rules = nil
+	}
+	if len(rules) == 0 {
+		rules = defaultTagRules
+	}
+	tagsMu.Lock()
+	questionTags = tags
+	tagRules = rules
+	tagsMu.Unlock()
+	slog.Info("加载题目标签", "questions", len(tags), "rules", len(rules))
+}
+
+// getQuestionTags 返回题目的知识点标签
+func getQuestionTags(questionID string) []string {
+	tagsMu.RLock()
+	defer tagsMu.RUnlock()
+	return questionTags[questionID]
+}
+
+// normalizeTags 去除空白和重复的标签并排序
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// filterQuestionsByTags 只保留带有任一指定标签的题目；tags 为空时原样返回
+func filterQuestionsByTags(questions []Question, tags []string) []Question {
+	wanted := make(map[string]bool)
+	for _, tag := range normalizeTags(tags) {
+		wanted[tag] = true
+	}
+	if len(wanted) == 0 {
+		return questions
+	}
+	filtered := []Question{}
+	for _, q := range questions {
+		for _, tag := range getQuestionTags(questionIDOf(q)) {
+			if wanted[tag] {
+				filtered = append(filtered, q)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// suggestTagsForQuestion 根据关键词规则为题目推荐标签（不包含已有的标签）
+func suggestTagsForQuestion(q Question, rules []TagRule, current []string) []string {
+	existing := make(map[string]bool)
+	for _, tag := range current {
+		existing[tag] = true
+	}
+	var text strings.Builder
+	text.WriteString(q.QuestionText)
+	for _, option := range q.Options {
+		text.WriteString("\n")
+		text.WriteString(option)
+	}
+	content := text.String()
+
+	suggested := []string{}
+	for _, rule := range rules {
+		if existing[rule.Tag] {
+			continue
+		}
+		for _, keyword := range rule.Keywords {
+			if keyword != "" && strings.Contains(content, keyword) {
+				suggested = append(suggested, rule.Tag)
+				existing[rule.Tag] = true
+				break
+			}
+		}
+	}
+	return suggested
+}
+
+// saveQuestionTagsLocked 保存标签文件并更新内存，调用方需持有 tagsMu 写锁
+func saveQuestionTagsLocked(tags map[string][]string) error {
+	if err := saveOverlayJSON(tagsFile, tags); err != nil {
+		return err
+	}
+	questionTags = tags
+	return nil
+}
+
+// copyQuestionTagsLocked 复制当前的标签映射，调用方需持有 tagsMu
+func copyQuestionTagsLocked() map[string][]string {
+	tags := make(map[string][]string, len(questionTags))
+	for id, list := range questionTags {
+		tags[id] = list
+	}
+	return tags
+}
+
+// TagsListHandler 返回所有知识点标签及其题目数，可用 ?course= 限定课程
+func TagsListHandler(ctx context.Context, c *app.RequestContext) {
+	resp, err := listTags(c.Query("course"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// listTags 统计课程（含虚拟课程，为空表示全部课程）中各标签的题目数，按题目数从多到少排序
+func listTags(course string) (TagListResponse, error) {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return TagListResponse{}, err
+	}
+	counts := make(map[string]int)
+	for _, q := range questions {
+		for _, tag := range getQuestionTags(questionIDOf(q)) {
+			counts[tag]++
+		}
+	}
+	list := make([]TagSummary, 0, len(counts))
+	for tag, count := range counts {
+		list = append(list, TagSummary{Tag: tag, QuestionCount: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].QuestionCount != list[j].QuestionCount {
+			return list[i].QuestionCount > list[j].QuestionCount
+		}
+		return list[i].Tag < list[j].Tag
+	})
+	return TagListResponse{Total: len(list), Tags: list}, nil
+}
+
+// SetTagsHandler 设置一道题的全部标签（管理接口）
+func SetTagsHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req SetTagsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	questionID, ok := normalizeQuestionID(req.QuestionID)
+	if !ok {
+		writeError(c, errUnknownQuestion.with(req.QuestionID))
+		return
+	}
+
+	tags := normalizeTags(req.Tags)
+	tagsMu.Lock()
+	defer tagsMu.Unlock()
+	updated := copyQuestionTagsLocked()
+	if len(tags) == 0 {
+		delete(updated, questionID)
+	} else {
+		updated[questionID] = tags
+	}
+	if err := saveQuestionTagsLocked(updated); err != nil {
+		slog.ErrorContext(ctx, "保存题目标签文件失败", "error", err)
+		writeError(c, &userDataError{Message: "保存题目标签失败", Err: err})
+		return
+	}
+	slog.InfoContext(ctx, "题目标签已更新", "question_id", questionID, "tags", tags)
+	c.JSON(consts.StatusOK, utils.H{"message": "标签已保存", "question_id": questionID, "tags": tags})
+}
+
+// SuggestTagsHandler 按关键词规则为题目推荐标签（管理接口）。
+// 默认只返回推荐结果供审核，apply 为 true 时把推荐的标签合并保存。
+func SuggestTagsHandler(ctx context.Context, c *app.RequestContext) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req SuggestTagsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	questions, err := questionsInScope(req.Course)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	tagsMu.Lock()
+	defer tagsMu.Unlock()
+	suggestions := []TagSuggestion{}
+	for _, q := range questions {
+		questionID := questionIDOf(q)
+		current := questionTags[questionID]
+		suggested := suggestTagsForQuestion(q, tagRules, current)
+		if len(suggested) == 0 {
+			continue
+		}
+		suggestions = append(suggestions, TagSuggestion{
+			QuestionID:    questionID,
+			QuestionText:  q.QuestionText,
+			CurrentTags:   current,
+			SuggestedTags: suggested,
+		})
+	}
+
+	if req.Apply && len(suggestions) > 0 {
+		updated := copyQuestionTagsLocked()
+		for _, suggestion := range suggestions {
+			updated[suggestion.QuestionID] = normalizeTags(append(append([]string{}, suggestion.CurrentTags...), suggestion.SuggestedTags...))
+		}
+		if err := saveQuestionTagsLocked(updated); err != nil {
+			slog.ErrorContext(ctx, "保存题目标签文件失败", "error", err)
+			writeError(c, &userDataError{Message: "保存题目标签失败", Err: err})
+			return
+		}
+		slog.InfoContext(ctx, "已应用推荐标签", "questions", len(suggestions))
+	}
+	c.JSON(consts.StatusOK, utils.H{
+		"applied":     req.Apply,
+		"total":       len(suggestions),
+		"suggestions": suggestions,
+	})
+}
+
+// TagStatsHandler 按知识点标签汇总用户的答题统计
+func TagStatsHandler(ctx context.Context, c *app.RequestContext) {
+	var req TagStatsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	list, err := computeTagAccuracy(req.UserID, req.Course)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, TagStatsResponse{UserID: req.UserID, Tags: list})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 作答用时 ---
+// 答题模式下前端每展示一道题就通知服务端 (/api/quiz/serve)，提交答案时用服务端记录的出题时间计算用时；
+// 没有出题记录时（如旧页面或服务重启后）使用前端上报的用时。用时记录在用户统计中，
+// 用于统计各题型、各章节的平均用时，找出答得慢的题目，以及"速度训练"（题目来源 slow）。
+
+const (
+	maxAnswerDuration          = 10 * time.Minute // 超过该时长视为中途离开，不记录用时
+	defaultSlowAnswerThreshold = 30 * time.Second // 未列出的题型答得慢的阈值
+	slowQuestionsLimit         = 50               // 用时统计中最多列出的慢题数
+)
+
+// slowAnswerThresholds 各题型答得慢的阈值：超过阈值即视为犹豫，无论是否答对
+var slowAnswerThresholds = map[string]time.Duration{
+	"单选题": 20 * time.Second,
+	"判断题": 15 * time.Second,
+	"多选题": 40 * time.Second,
+	"不定项": 40 * time.Second,
+}
+
+// isSlowAnswer 判断用时是否超过该题型的阈值
+func isSlowAnswer(questionType string, spent time.Duration) bool {
+	threshold, ok := slowAnswerThresholds[questionType]
+	if !ok {
+		threshold = defaultSlowAnswerThreshold
+	}
+	return spent > threshold
+}
+
+// measureAnswerTime 计算一道题的作答用时并清除出题记录（调用方需持有会话锁）。
+// 优先使用服务端记录的出题时间，其次是前端上报的用时；都不可用或超过上限时返回 0 表示不记录。
+func measureAnswerTime(session *UserSession, quizQuestionID string, clientHintMs int64) (time.Duration, string) {
+	if servedAt, ok := session.ServedAt[quizQuestionID]; ok {
+		delete(session.ServedAt, quizQuestionID)
+		if spent := time.Since(servedAt); spent <= maxAnswerDuration {
+			return spent, "server"
+		}
+	}
+	if hint := time.Duration(clientHintMs) * time.Millisecond; hint > 0 && hint <= maxAnswerDuration {
+		return hint, "client"
+	}
+	return 0, ""
+}
+
+// newSpeedSummary 根据作答次数和累计用时计算平均用时
+func newSpeedSummary(timedAnswers int, totalTimeMs int64) SpeedSummary {
+	summary := SpeedSummary{TimedAnswers: timedAnswers}
+	if timedAnswers > 0 {
+		summary.AverageSeconds = float64(totalTimeMs) / float64(timedAnswers) / 1000
+	}
+	return summary
+}
+
+// loadUserStats 加载用户的作答统计
+func loadUserStats(userID string) (map[string]UserQuestionStat, error) {
+	userStats := make(map[string]UserQuestionStat)
+	if err := loadUserJSONData(userID, questionStatsFile, &userStats); err != nil {
+		return nil, &userDataError{Message: "加载用户统计数据失败", Err: err}
+	}
+	return userStats, nil
+}
+
+// computeSpeedStats 统计用户在课程（含虚拟课程，为空表示全部实体课程）中的作答用时
+func computeSpeedStats(userID, course string) (SpeedStats, error) {
+	questions, err := questionsInScope(course)
+	if err != nil {
+		return SpeedStats{}, err
+	}
+	userStats, err := loadUserStats(userID)
+	if err != nil {
+		return SpeedStats{}, err
+	}
+
+	type accumulator struct {
+		answers int
+		totalMs int64
+	}
+	var overall accumulator
+	byType := make(map[string]*accumulator)
+	byChapter := make(map[string]*accumulator)
+	var chapterOrder []ChapterSpeed
+	result := SpeedStats{UserID: userID, ByType: []TypeSpeed{}, ByChapter: []ChapterSpeed{}, SlowQuestions: []SlowQuestion{}}
+
+	for _, q := range questions {
+		stat, _ := userStatFor(userStats, q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+		if stat.TimedCount == 0 {
+			continue
+		}
+		overall.answers += stat.TimedCount
+		overall.totalMs += stat.TotalTimeMs
+		if byType[q.QuestionType] == nil {
+			byType[q.QuestionType] = &accumulator{}
+		}
+		byType[q.QuestionType].answers += stat.TimedCount
+		byType[q.QuestionType].totalMs += stat.TotalTimeMs
+		chapterKey := q.OriginalCourse + "/" + q.OriginalChapterKey
+		if byChapter[chapterKey] == nil {
+			byChapter[chapterKey] = &accumulator{}
+			chapterOrder = append(chapterOrder, ChapterSpeed{Course: q.OriginalCourse, Chapter: q.OriginalChapterKey, Title: getChapterTitle(q.OriginalCourse, q.OriginalChapterKey)})
+		}
+		byChapter[chapterKey].answers += stat.TimedCount
+		byChapter[chapterKey].totalMs += stat.TotalTimeMs
+
+		if isSlowAnswer(q.QuestionType, time.Duration(stat.LastTimeMs)*time.Millisecond) {
+			result.SlowQuestions = append(result.SlowQuestions, SlowQuestion{
+				QuestionID:     questionIDOf(q),
+				Course:         q.OriginalCourse,
+				Chapter:        q.OriginalChapterKey,
+				QuestionNumber: q.QuestionNumber,
+				QuestionType:   q.QuestionType,
+				QuestionText:   q.QuestionText,
+				LastSeconds:    float64(stat.LastTimeMs) / 1000,
+				AverageSeconds: newSpeedSummary(stat.TimedCount, stat.TotalTimeMs).AverageSeconds,
+				CorrectCount:   stat.CorrectCount,
+				ErrorCount:     stat.ErrorCount,
+			})
+		}
+	}
+
+	result.Overall = newSpeedSummary(overall.answers, overall.totalMs)
+	for questionType, acc := range byType {
+		result.ByType = append(result.ByType, TypeSpeed{QuestionType: questionType, SpeedSummary: newSpeedSummary(acc.answers, acc.totalMs)})
+	}
+	sort.Slice(result.ByType, func(i, j int) bool { return result.ByType[i].AverageSeconds > result.ByType[j].AverageSeconds })
+	for _, chapter := range chapterOrder {
+		acc := byChapter[chapter.Course+"/"+chapter.Chapter]
+		chapter.SpeedSummary = newSpeedSummary(acc.answers, acc.totalMs)
+		result.ByChapter = append(result.ByChapter, chapter)
+	}
+	sort.SliceStable(result.SlowQuestions, func(i, j int) bool {
+		return result.SlowQuestions[i].LastSeconds > result.SlowQuestions[j].LastSeconds
+	})
+	if len(result.SlowQuestions) > slowQuestionsLimit {
+		result.SlowQuestions = result.SlowQuestions[:slowQuestionsLimit]
+	}
+	return result, nil
+}
+
+// filterSlowQuestions 只保留用户最近一次作答用时超过题型阈值的题目（速度训练），保持原有顺序
+func filterSlowQuestions(userID string, questions []Question) ([]Question, error) {
+	userStats, err := loadUserStats(userID)
+	if err != nil {
+		return nil, err
+	}
+	filtered := []Question{}
+	for _, q := range questions {
+		stat, _ := userStatFor(userStats, q.OriginalCourse, q.OriginalChapterKey, q.QuestionNumber)
+		if stat.TimedCount > 0 && isSlowAnswer(q.QuestionType, time.Duration(stat.LastTimeMs)*time.Millisecond) {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered, nil
+}
+
+// ServeQuestionHandler 记录答题模式下一道题的出题时间，提交答案时据此计算用时
+func ServeQuestionHandler(ctx context.Context, c *app.RequestContext) {
+	var req ServeQuestionRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	if err := markQuestionServed(req); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, MessageResponse{Message: "已记录出题时间"})
+}
+
+// markQuestionServed 记录一道题的出题时间，会话不在答题模式时返回 wrong_mode 错误
+func markQuestionServed(req ServeQuestionRequest) error {
+	session := getOrCreateUserSession(req.UserID)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.CurrentMode != "quiz" {
+		return newAppError(codeWrongMode, "答题")
+	}
+	if session.ServedAt == nil {
+		session.ServedAt = make(map[string]time.Time)
+	}
+	session.ServedAt[req.QuizQuestionID] = time.Now()
+	return nil
+}
+
+// SpeedStatsHandler 返回用户按题型和章节的平均用时，以及答得慢的题目
+func SpeedStatsHandler(ctx context.Context, c *app.RequestContext) {
+	var req SpeedStatsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		writeError(c, invalidRequest(err))
+		return
+	}
+	stats, err := computeSpeedStats(req.UserID, req.Course)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, stats)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 终端客户端的模式
+const (
+	tuiModeReview    = "review"    // 速刷：直接展示题目和答案
+	tuiModeQuiz      = "quiz"      // 答题：作答后判分并记录统计与错题
+	tuiModeIncorrect = "incorrect" // 错题回顾：答对后可从错题本删除
+)
+
+// tuiOptions 终端客户端的启动参数，未指定的项会在运行时询问
+type tuiOptions struct {
+	userID   string
+	mode     string
+	course   string
+	chapters string
+	order    string
+	seed     int64
+}
+
+// terminalUI 终端交互：从输入逐行读取，向输出打印
+type terminalUI struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// runTUI 运行终端答题客户端 (quiz tui)。与网页端使用相同的题库、用户数据和答题记录逻辑，进度互通。
+func runTUI(args []string) {
+	fs, cf := newCommandFlags("tui")
+	var opts tuiOptions
+	fs.StringVar(&opts.userID, "user", "", "用户名，与网页端相同即可共享进度")
+	fs.StringVar(&opts.mode, "mode", "", "模式: review (速刷), quiz (答题), incorrect (错题回顾)")
+	fs.StringVar(&opts.course, "course", "", "课程: "+strings.Join(courseOrder, ", "))
+	fs.StringVar(&opts.chapters, "chapters", "", "章节键，逗号分隔，all 表示全部章节，见 /api/courses")
+	fs.StringVar(&opts.order, "order", "", "顺序: sequential 或 random")
+	fs.Int64Var(&opts.seed, "seed", 0, "随机种子，相同的种子和选择可以重现同样的顺序 (与网页端通用)")
+	if positional := setupCommand(fs, cf, args); len(positional) > 0 {
+		slog.Error("tui 不接受位置参数", "args", strings.Join(positional, " "))
+		os.Exit(1)
+	}
+
+	ui := &terminalUI{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	err := ui.run(opts)
+	if flushErr := flushGlobalStats(); flushErr != nil {
+		slog.Error("保存全局作答统计失败", "error", flushErr)
+	}
+	if err != nil && err != io.EOF {
+		slog.Error("终端客户端出错", "error", err)
+		os.Exit(1)
+	}
+	ui.printf("\n下次再来刷题喵~\n")
+}
+
+// run 选择用户后进入主菜单；通过参数指定了模式时只运行一次
+func (ui *terminalUI) run(opts tuiOptions) error {
+	ui.printf("=== 喵喵学习小助手 (终端版) ===\n")
+	userID := opts.userID
+	for userID == "" {
+		line, err := ui.prompt("请输入用户名 (与网页端相同即可同步进度): ")
+		if err != nil {
+			return err
+		}
+		userID = line
+	}
+	if strings.ContainsAny(userID, `/\`) || userID == "." || userID == ".." {
+		return fmt.Errorf("无效的用户名: %s", userID)
+	}
+	if err := ensureUserDir(userID); err != nil {
+		return fmt.Errorf("无法初始化用户数据存储区: %w", err)
+	}
+	ui.printf("你好，%s！\n", userID)
+
+	if opts.mode != "" {
+		return ui.runMode(userID, opts)
+	}
+	for {
+		ui.printf("\n[1] 速刷  [2] 答题  [3] 错题回顾  [q] 退出\n")
+		choice, err := ui.prompt("请选择模式: ")
+		if err != nil {
+			return err
+		}
+		runOpts := opts
+		switch choice {
+		case "1":
+			runOpts.mode = tuiModeReview
+		case "2":
+			runOpts.mode = tuiModeQuiz
+		case "3":
+			runOpts.mode = tuiModeIncorrect
+		case "q", "Q":
+			return nil
+		default:
+			ui.printf("没有这个选项哦\n")
+			continue
+		}
+		if err := ui.runMode(userID, runOpts); err != nil {
+			if err == io.EOF {
+				return err
+			}
+			ui.printf("喵呜！%v\n", err)
+		}
+	}
+}
+
+// runMode 选择课程和章节后运行一轮
+func (ui *terminalUI) runMode(userID string, opts tuiOptions) error {
+	course := opts.course
+	if course == "" {
+		var err error
+		if course, err = ui.chooseCourse(); err != nil {
+			return err
+		}
+	}
+	if _, ok := courseCatalog[course]; !ok {
+		return newAppError(codeUnknownCourse, course)
+	}
+
+	switch opts.mode {
+	case tuiModeIncorrect:
+		return ui.runIncorrectReview(userID, course, opts.seed)
+	case tuiModeReview, tuiModeQuiz:
+	default:
+		return fmt.Errorf("未知的模式: %s", opts.mode)
+	}
+
+	var chapterChoices []string
+	if opts.chapters != "" {
+		chapterChoices = strings.Split(opts.chapters, ",")
+	} else {
+		var err error
+		if chapterChoices, err = ui.chooseChapters(course); err != nil {
+			return err
+		}
+	}
+	order := opts.order
+	if order == "" {
+		line, err := ui.prompt("随机顺序吗? (y/N): ")
+		if err != nil {
+			return err
+		}
+		order = "sequential"
+		if strings.EqualFold(line, "y") {
+			order = "random"
+		}
+	}
+
+	rng, seed := newRunRNG(opts.seed)
+	questions, err := _getQuestionsForProcessing(course, chapterChoices, order, rng)
+	if err != nil {
+		return err
+	}
+	if isRandomOrder(order) {
+		ui.printf("本轮随机种子: %d (使用 -seed %d 可以重现同样的顺序)\n", seed, seed)
+	}
+	if len(questions) == 0 {
+		ui.printf("所选范围没有题目。\n")
+		return nil
+	}
+	if opts.mode == tuiModeReview {
+		return ui.runReview(questions)
+	}
+	return ui.runQuiz(userID, questions)
+}
+
+// chooseCourse 列出课程供选择
+func (ui *terminalUI) chooseCourse() (string, error) {
+	for {
+		ui.printf("\n")
+		for i, key := range courseOrder {
+			if meta, ok := courseCatalog[key]; ok {
+				ui.printf("[%d] %s (%d 题)\n", i+1, meta.Title, meta.QuestionCount)
+			}
+		}
+		line, err := ui.prompt("请选择课程: ")
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(courseOrder) {
+			return courseOrder[n-1], nil
+		}
+		if _, ok := courseCatalog[line]; ok {
+			return line, nil
+		}
+		ui.printf("没有这门课程哦\n")
+	}
+}
+
+// chooseChapters 列出章节供选择，支持 "1 3 5"、"1,3"、"2-4"，直接回车表示全部章节
+func (ui *terminalUI) chooseChapters(course string) ([]string, error) {
+	chapters := courseCatalog[course].Chapters
+	for {
+		ui.printf("\n")
+		for i, chapter := range chapters {
+			ui.printf("[%d] %s (%d 题)\n", i+1, chapter.Title, chapter.QuestionCount)
+		}
+		line, err := ui.prompt("请选择章节 (如 1 3 或 2-4，回车为全部): ")
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || line == allChaptersChoice {
+			return []string{allChaptersChoice}, nil
+		}
+		indices, ok := parseIndexSelection(line, len(chapters))
+		if !ok {
+			ui.printf("章节序号无效，请重新输入\n")
+			continue
+		}
+		keys := make([]string, len(indices))
+		for i, idx := range indices {
+			keys[i] = chapters[idx].Key
+		}
+		return keys, nil
+	}
+}
+
+// parseIndexSelection 解析 1-based 的序号列表和范围，返回 0-based 序号
+func parseIndexSelection(input string, count int) ([]int, bool) {
+	var indices []int
+	fields := strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' || r == '，' })
+	for _, field := range fields {
+		from, to := field, field
+		if a, b, isRange := strings.Cut(field, "-"); isRange {
+			from, to = a, b
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || start < 1 || end > count || start > end {
+			return nil, false
+		}
+		for n := start; n <= end; n++ {
+			indices = append(indices, n-1)
+		}
+	}
+	return indices, len(indices) > 0
+}
+
+// runReview 速刷：依次展示题目、答案和拆解
+func (ui *terminalUI) runReview(questions []Question) error {
+	for i, q := range questions {
+		ui.printQuestion(q, i+1, len(questions))
+		ui.printf("答案: %s\n", q.CorrectAnswer)
+		ui.printExplanation(q)
+		if i == len(questions)-1 {
+			break
+		}
+		line, err := ui.prompt("回车下一题，q 结束: ")
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(line, "q") {
+			break
+		}
+	}
+	ui.printf("\n速刷完成!\n")
+	return nil
+}
+
+// runQuiz 答题：判分后通过与网页端相同的 recordQuizAnswer 记录统计和错题
+func (ui *terminalUI) runQuiz(userID string, questions []Question) error {
+	answered, correct := 0, 0
+	for i, q := range questions {
+		ui.printQuestion(q, i+1, len(questions))
+		servedAt := time.Now()
+		answer, err := ui.readAnswer(q)
+		if err != nil {
+			return err
+		}
+		if answer == "q" {
+			break
+		}
+		if answer == "s" {
+			continue
+		}
+		timeSpent := time.Since(servedAt)
+		if timeSpent > maxAnswerDuration {
+			timeSpent = 0 // 中途离开，不记录用时
+		}
+		wasCorrect := answer == normalizeAnswer(q.CorrectAnswer)
+		addedToIncorrect, recordErr := recordQuizAnswer(context.Background(), userID, q, answer, wasCorrect, timeSpent)
+		answered++
+		switch {
+		case wasCorrect:
+			correct++
+			ui.printf("✔ 回答正确！\n")
+		case addedToIncorrect:
+			ui.printf("✘ 回答错误，正确答案: %s (已加入错题本)\n", q.CorrectAnswer)
+		default:
+			ui.printf("✘ 回答错误，正确答案: %s\n", q.CorrectAnswer)
+		}
+		if recordErr != nil {
+			ui.printf("喵呜！%s，本题未记录\n", userDataErrorMessage(recordErr))
+		}
+		ui.printExplanation(q)
+	}
+	ui.printf("\n本轮作答 %d 题，答对 %d 题", answered, correct)
+	if answered > 0 {
+		ui.printf("，正确率 %.1f%%", float64(correct)*100/float64(answered))
+	}
+	ui.printf("\n")
+	return nil
+}
+
+// runIncorrectReview 错题回顾：答对后可以选择从错题本删除该题
+func (ui *terminalUI) runIncorrectReview(userID, course string, seed int64) error {
+	incorrect, err := loadUserIncorrectForCourse(userID, course)
+	if err != nil {
+		return fmt.Errorf("加载用户错题本失败: %w", err)
+	}
+	if len(incorrect) == 0 {
+		ui.printf("错题簿是空的哦！太棒了！\n")
+		return nil
+	}
+	rng, seed := newRunRNG(seed)
+	ui.printf("本轮随机种子: %d (使用 -seed %d 可以重现同样的顺序)\n", seed, seed)
+	rng.Shuffle(len(incorrect), func(i, j int) {
+		incorrect[i], incorrect[j] = incorrect[j], incorrect[i]
+	})
+
+	removed := 0
+	for i, iq := range incorrect {
+		q := incorrectToQuestion(iq)
+		ui.printQuestion(q, i+1, len(incorrect))
+		answer, err := ui.readAnswer(q)
+		if err != nil {
+			return err
+		}
+		if answer == "q" {
+			break
+		}
+		if answer == "s" {
+			continue
+		}
+		if err := recordStudyActivity(userID, time.Now(), 0, "", false); err != nil {
+			slog.Warn("记录学习日历失败", logKeyUserID, userID, "error", err)
+		}
+		if answer != normalizeAnswer(q.CorrectAnswer) {
+			ui.printf("✘ 还是错了，正确答案: %s (上次选了 %s)\n", q.CorrectAnswer, iq.UserAnswer)
+			ui.printExplanation(q)
+			continue
+		}
+		ui.printf("✔ 回答正确！\n")
+		ui.printExplanation(q)
+		line, err := ui.prompt("从错题本中删除这道题吗? (y/N): ")
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(line, "y") {
+			found, err := deleteIncorrectQuestion(context.Background(), userID, iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber)
+			if err != nil {
+				ui.printf("喵呜！%s\n", userDataErrorMessage(err))
+			} else if found {
+				removed++
+				ui.printf("已从错题本删除\n")
+			}
+		}
+	}
+	ui.printf("\n错题回顾结束，本轮删除 %d 道错题\n", removed)
+	return nil
+}
+
+// incorrectToQuestion 优先使用题库中的题目（已应用修正），找不到时使用错题本中保存的内容
+func incorrectToQuestion(iq UserIncorrectQuestion) Question {
+	if q, ok := findQuestionByNumber(iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber); ok {
+		return q
+	}
+	return Question{
+		QuestionNumber:     iq.QuestionNumber,
+		QuestionType:       iq.QuestionType,
+		QuestionText:       iq.QuestionText,
+		Options:            iq.Options,
+		CorrectAnswer:      iq.CorrectAnswer,
+		OriginalCourse:     iq.OriginalCourse,
+		OriginalChapterKey: iq.OriginalChapter,
+	}
+}
+
+// readAnswer 读取答案并规范化，多选题可输入多个字母 (如 "ABD" 或 "a b d")。
+// 返回 "s" 表示跳过，"q" 表示结束本轮。
+func (ui *terminalUI) readAnswer(q Question) (string, error) {
+	multi := q.QuestionType != "单选题" // 多选题和不定项都可以选多个
+	hint := "请选择一个答案"
+	if multi {
+		hint = "请选择全部正确答案 (如 ABD)"
+	}
+	for {
+		line, err := ui.prompt(hint + "，s 跳过，q 结束: ")
+		if err != nil {
+			return "", err
+		}
+		switch strings.ToLower(line) {
+		case "s", "q":
+			return strings.ToLower(line), nil
+		}
+		answer := normalizeAnswer(line)
+		if !answerMatchesOptions(answer, q.Options) {
+			ui.printf("请输入题目中的选项字母\n")
+			continue
+		}
+		if !multi && len(answer) > 1 {
+			ui.printf("这是单选题，只能选一个答案\n")
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// printQuestion 打印题目和选项
+func (ui *terminalUI) printQuestion(q Question, number, total int) {
+	chapterTitle := q.OriginalChapterKey
+	if meta, ok := courseCatalog[q.OriginalCourse]; ok {
+		for _, chapter := range meta.Chapters {
+			if chapter.ChapterKey == q.OriginalChapterKey {
+				chapterTitle = chapter.Title
+				break
+			}
+		}
+	}
+	ui.printf("\n──────── 第 %d/%d 题 [%s] %s · %s ────────\n", number, total, q.QuestionType, courseShortTitles[q.OriginalCourse], chapterTitle)
+	ui.printf("%s\n", q.QuestionText)
+	letters := make([]string, 0, len(q.Options))
+	for letter := range q.Options {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+	for _, letter := range letters {
+		ui.printf("  %s. %s\n", letter, q.Options[letter])
+	}
+}
+
+// printExplanation 打印拆解和教材出处（如果有）
+func (ui *terminalUI) printExplanation(q Question) {
+	explanation, reference := getQuestionExplanation(q)
+	if explanation != "" {
+		ui.printf("拆解: %s\n", explanation)
+	}
+	if reference != nil {
+		parts := []string{}
+		for _, part := range []string{reference.Chapter, reference.Section} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if reference.Page != "" {
+			parts = append(parts, "第 "+reference.Page+" 页")
+		}
+		ui.printf("出处: %s\n", strings.Join(parts, " "))
+	}
+}
+
+// prompt 打印提示并读取一行输入（去除首尾空白），输入结束时返回 io.EOF
+func (ui *terminalUI) prompt(message string) (string, error) {
+	ui.printf("%s", message)
+	line, err := ui.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (ui *terminalUI) printf(format string, args ...interface{}) {
+	fmt.Fprintf(ui.out, format, args...)
+}
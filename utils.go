@@ -3,16 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -29,9 +32,8 @@ var (
 	sessionsMu                  sync.RWMutex            // 保护 userSessions 映射
 )
 
-// init 在程序启动时执行初始化操作
-func init() {
-	rand.Seed(time.Now().UnixNano()) // 初始化随机数生成器
+// initializeApp 在配置加载后执行初始化操作：创建数据目录并加载题库与旁路文件
+func initializeApp() {
 	maogaiQuestionsByChapter = make(map[string][]Question)
 	xigaiLiQuestionsByChapter = make(map[string][]Question)
 	xigaiYangQuestionsByChapter = make(map[string][]Question)
@@ -40,67 +42,91 @@ func init() {
 
 	// 确保用户数据根目录存在
 	if err := os.MkdirAll(userDataBaseDir, os.ModePerm); err != nil {
-		log.Fatalf("无法创建用户数据目录 %s: %v", userDataBaseDir, err)
+		slog.Error("无法创建用户数据目录", "dir", userDataBaseDir, "error", err)
+		os.Exit(1)
 	}
-	loadAllQuestionsGlobal() // 加载所有题目到内存
+	loadAllQuestionsGlobal()    // 加载所有题目到内存
+	loadQuestionCorrections()   // 加载管理员对题目的修正（需在构建目录前，题型统计以修正后为准）
+	buildCourseCatalog()        // 根据题库构建课程与章节目录
+	loadQuestionExplanations()  // 加载旁路文件中的题目拆解
+	loadQuestionReports()       // 加载用户提交的纠错报告
+	loadQuestionTags()          // 加载题目的知识点标签
+	migrateAllLegacyUserStats() // 把旧版本不带课程的统计键改写为带课程的键（需在加载修正后，回填全局统计前）
+	loadAssignments()           // 加载老师布置的作业
+	loadGlobalStats()           // 加载全局作答统计（需在构建目录后，首次启用时由用户统计回填）
 }
 
 // loadAllQuestionsGlobal 从嵌入文件系统加载所有章节的题目到全局变量
 func loadAllQuestionsGlobal() {
-	log.Println("喵~ 正在努力加载全局题库中...")
+	slog.Info("正在加载全局题库")
+	resetBankLoadIssues()
 
 	// 加载毛概题库
-	log.Println("加载毛概题库...")
+	slog.Info("加载毛概题库")
 	for i := 0; i <= maogaiMaxChapterIndex; i++ {
 		chapterKey := strconv.Itoa(i)
-		// 嵌入文件系统使用正斜杠，不使用filepath.Join
-		filePath := maogaiQuestionSourceDir + "/" + chapterKey + ".json"
-		loadChapterQuestions(filePath, chapterKey, "maogai", maogaiQuestionsByChapter)
+		loadChapterQuestions(maogaiQuestionSourceDir, chapterKey, "maogai", maogaiQuestionsByChapter)
 	}
 
 	// 加载习概题库
-	log.Println("加载习概题库...")
+	slog.Info("加载习概题库")
 	// 李老师的习概题库（通常只有一个章节）
 	for i := 0; i <= xigaiLiMaxChapterIndex; i++ {
 		chapterKey := strconv.Itoa(i)
-		filePath := xigaiLiQuestionSourceDir + "/" + chapterKey + ".json"
-		loadChapterQuestions(filePath, chapterKey, "xigai_li", xigaiLiQuestionsByChapter)
+		loadChapterQuestions(xigaiLiQuestionSourceDir, chapterKey, "xigai_li", xigaiLiQuestionsByChapter)
 	}
 	// 杨老师的习概题库（导论 + 多章节）
 	for i := 0; i <= xigaiYangMaxChapterIndex; i++ {
 		chapterKey := strconv.Itoa(i)
-		filePath := xigaiYangQuestionSourceDir + "/" + chapterKey + ".json"
-		loadChapterQuestions(filePath, chapterKey, "xigai_yang", xigaiYangQuestionsByChapter)
+		loadChapterQuestions(xigaiYangQuestionSourceDir, chapterKey, "xigai_yang", xigaiYangQuestionsByChapter)
 	}
 
-	log.Println("喵~ 全局题库加载完毕！")
+	slog.Info("全局题库加载完毕")
+}
+
+// courseBankSource 返回课程题库所在的文件系统和目录：优先使用配置的外部题库目录，
+// 其次是通过上传安装到数据目录的题库，否则使用嵌入的题库
+func courseBankSource(course, embeddedDir string) (fs.FS, string) {
+	if dir, ok := appConfig.BankDirs[course]; ok {
+		return os.DirFS(dir), "."
+	}
+	if dir, ok := installedBankDir(course); ok {
+		return os.DirFS(dir), "."
+	}
+	return embeddedFS, embeddedDir
 }
 
 // loadChapterQuestions 加载单个章节的题目
-func loadChapterQuestions(filePath, chapterKey, course string, targetMap map[string][]Question) {
-	fileData, err := embeddedFS.ReadFile(filePath)
+func loadChapterQuestions(embeddedDir, chapterKey, course string, targetMap map[string][]Question) {
+	bankFS, dir := courseBankSource(course, embeddedDir)
+	// fs.FS 使用正斜杠，不使用filepath.Join
+	filePath := path.Join(dir, chapterKey+".json")
+	fileData, err := fs.ReadFile(bankFS, filePath)
 	if err != nil {
-		log.Printf("喵~ 提示：章节 %s (%s) 的题库文件 (%s) 没找到呢,跳过这个章节啦。错误: %v", chapterKey, course, filePath, err)
+		slog.Info("章节题库文件没有找到，已跳过", "course", course, "chapter", chapterKey, "file", filePath, "error", err)
+		recordBankLoadIssue(course, chapterKey, errors.Is(err, fs.ErrNotExist))
 		targetMap[chapterKey] = []Question{} // 即使文件不存在,也初始化为空列表
 		return
 	}
 
 	var questionsInChapter []Question
 	if err := json.Unmarshal(fileData, &questionsInChapter); err != nil {
-		log.Printf("喵呜！错误：解析章节 %s (%s) 的题库文件 (%s) 失败了。错误: %v", chapterKey, course, filePath, err)
+		slog.Error("解析章节题库文件失败", "course", course, "chapter", chapterKey, "file", filePath, "error", err)
+		recordBankLoadIssue(course, chapterKey, false)
 		targetMap[chapterKey] = []Question{} // 解析失败也初始化为空列表
 		return
 	}
 
 	for idx := range questionsInChapter {
+		questionsInChapter[idx].OriginalCourse = course
 		questionsInChapter[idx].OriginalChapterKey = chapterKey
 		questionsInChapter[idx].OriginalIndex = idx
 		// 使用 "课程_章节号_题目在文件中的索引" 作为唯一ID
-		questionID := fmt.Sprintf("%s_%s_%d", course, chapterKey, idx)
+		questionID := getQuestionID(course, chapterKey, idx)
 		questionMapByID[questionID] = questionsInChapter[idx]
 	}
 	targetMap[chapterKey] = questionsInChapter
-	log.Printf("加载了 %d 道 %s 第%s章题目", len(questionsInChapter), course, chapterKey)
+	slog.Info("加载章节题目", "course", course, "chapter", chapterKey, "count", len(questionsInChapter))
 }
 
 // --- 用户数据持久化帮助函数 ---
@@ -147,9 +173,11 @@ func loadUserJSONData(userID, fileName string, target interface{}) error {
 			*v = []UserIncorrectQuestion{}
 		case *map[string]UserQuestionStat:
 			*v = make(map[string]UserQuestionStat)
+		case *map[string]UserQuestionNote:
+			*v = make(map[string]UserQuestionNote)
 		default:
 			// 对于其他类型，可以返回错误或尝试其他初始化，但通常是空切片/映射
-			log.Printf("加载用户数据: 文件 %s 不存在，目标类型 %T 将保持默认零值或需要特定处理", filePath, target)
+			slog.Debug("用户数据文件不存在，保持默认零值", logKeyUserID, userID, "file", fileName, "type", fmt.Sprintf("%T", target))
 		}
 		return nil // 文件不存在不是一个错误，表示用户还没有这类数据
 	}
@@ -165,8 +193,10 @@ func loadUserJSONData(userID, fileName string, target interface{}) error {
 			*v = []UserIncorrectQuestion{}
 		case *map[string]UserQuestionStat:
 			*v = make(map[string]UserQuestionStat)
+		case *map[string]UserQuestionNote:
+			*v = make(map[string]UserQuestionNote)
 		default:
-			log.Printf("加载用户数据: 文件 %s 为空，目标类型 %T 将保持默认零值或需要特定处理", filePath, target)
+			slog.Debug("用户数据文件为空，保持默认零值", logKeyUserID, userID, "file", fileName, "type", fmt.Sprintf("%T", target))
 		}
 		return nil // 空文件也表示没有数据
 	}
@@ -177,6 +207,7 @@ func loadUserJSONData(userID, fileName string, target interface{}) error {
 // saveUserJSONData 将用户数据（通常是结构体或映射）序列化为JSON并保存到文件
 func saveUserJSONData(userID, fileName string, data interface{}) error {
 	if err := ensureUserDir(userID); err != nil { // 确保用户目录存在
+		countStorageWriteFailure(storeUserData)
 		return err
 	}
 	filePath := getUserDataPath(userID, fileName)
@@ -184,7 +215,41 @@ func saveUserJSONData(userID, fileName string, data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("JSON序列化用户数据到 %s 失败: %w", filePath, err)
 	}
-	return ioutil.WriteFile(filePath, jsonData, 0644) // 0644 文件权限
+	if err := writeFileAtomic(filePath, jsonData); err != nil {
+		countStorageWriteFailure(storeUserData)
+		return err
+	}
+	return nil
+}
+
+// activeWrites 正在写入的数据文件数，退出时等待其归零
+var activeWrites atomic.Int64
+
+// writeFileAtomic 先写入同目录下的临时文件再改名替换，进程中途退出时不会留下只写了一半的文件
+func writeFileAtomic(filePath string, data []byte) error {
+	activeWrites.Add(1)
+	defer activeWrites.Add(-1)
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644) // 0644 文件权限
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入 %s 失败: %w", filePath, err)
+	}
+	return nil
 }
 
 // --- 会话管理 ---
@@ -195,6 +260,7 @@ func getOrCreateUserSession(userID string) *UserSession {
 	session, exists := userSessions[userID]
 	sessionsMu.RUnlock()
 	if exists {
+		session.lastActive.Store(time.Now().UnixNano())
 		return session
 	}
 
@@ -203,6 +269,7 @@ func getOrCreateUserSession(userID string) *UserSession {
 	// 再次检查，防止在获取写锁期间其他goroutine已创建会话 (双重检查锁定模式)
 	session, exists = userSessions[userID]
 	if exists {
+		session.lastActive.Store(time.Now().UnixNano())
 		return session
 	}
 
@@ -210,129 +277,138 @@ func getOrCreateUserSession(userID string) *UserSession {
 		UserID: userID,
 		// CurrentQuestions, OriginalIncorrect, CurrentQuestionIndex, CurrentMode 会在特定模式开始时设置
 	}
+	newSession.lastActive.Store(time.Now().UnixNano())
 	userSessions[userID] = newSession
 	return newSession
 }
 
-// _getQuestionsForProcessing 根据章节和顺序选择,从全局题库中筛选和排序题目
-func _getQuestionsForProcessing(course string, chapterChoices []string, orderChoice string) []Question {
-	var questionsToProcess []Question
-	var targetChapterKeys []string
-	isSelectAll := false
-
-	// 根据课程选择题库和最大章节索引
-	var questionsByChapter map[string][]Question
-	var maxChapterIdx int
+// newRunSeed 生成一个新的随机种子。种子取六位数，方便口头分享（"种子 482107"）
+func newRunSeed() int64 {
+	return rand.Int63n(900000) + 100000
+}
 
-	switch course {
-	case "maogai":
-		questionsByChapter = maogaiQuestionsByChapter
-		maxChapterIdx = maogaiMaxChapterIndex
-	case "xigai_li":
-		questionsByChapter = xigaiLiQuestionsByChapter
-		maxChapterIdx = xigaiLiMaxChapterIndex
-	case "xigai_yang":
-		questionsByChapter = xigaiYangQuestionsByChapter
-		maxChapterIdx = xigaiYangMaxChapterIndex
-	default:
-		// 默认回退为毛概
-		questionsByChapter = maogaiQuestionsByChapter
-		maxChapterIdx = maogaiMaxChapterIndex
+// newRunRNG 返回本轮使用的随机数生成器和实际使用的种子，seed 为 0 时生成新的种子。
+// 每轮刷题使用独立的生成器，相同的种子和选择总能得到相同的顺序。
+func newRunRNG(seed int64) (*rand.Rand, int64) {
+	if seed == 0 {
+		seed = newRunSeed()
 	}
+	return rand.New(rand.NewSource(seed)), seed
+}
 
-	for _, choice := range chapterChoices {
-		if strings.ToLower(choice) == "all" || choice == "9" { // "9" 作为 "all" 的别名兼容旧版或简化输入
-			isSelectAll = true
-			break
-		}
-	}
+// isRandomOrder 判断顺序选择是否为随机
+func isRandomOrder(orderChoice string) bool {
+	return orderChoice == "random" || orderChoice == "1" // "1" 作为 "random" 的别名
+}
 
-	if isSelectAll {
-		for i := 0; i <= maxChapterIdx; i++ { // 加载0到maxChapterIdx的所有章节
-			targetChapterKeys = append(targetChapterKeys, strconv.Itoa(i))
-		}
-		// 确保章节键按数字顺序排列
-		sort.SliceStable(targetChapterKeys, func(i, j int) bool {
-			numI, _ := strconv.Atoi(targetChapterKeys[i])
-			numJ, _ := strconv.Atoi(targetChapterKeys[j])
-			return numI < numJ
-		})
-	} else {
-		// 只加载用户选择的特定章节
-		for _, choice := range chapterChoices {
-			if _, err := strconv.Atoi(choice); err == nil { // 确保 choice 是一个有效的数字字符串
-				if _, ok := questionsByChapter[choice]; ok { // 确保章节数据存在
-					targetChapterKeys = append(targetChapterKeys, choice)
-				} else {
-					log.Printf("警告: 请求的章节 %s (%s) 在题库中不存在,已跳过。", choice, course)
-				}
-			} else {
-				log.Printf("警告: 无效的章节选择 %s,已跳过。", choice)
-			}
-		}
+// _getQuestionsForProcessing 根据章节和顺序选择,从全局题库中筛选和排序题目。
+// 章节选择必须使用课程目录中的章节键（或 "all"），无效的选择会返回错误。
+// 随机顺序使用 rng 打乱，顺序选择时 rng 可以为 nil。
+func _getQuestionsForProcessing(course string, chapterChoices []string, orderChoice string, rng *rand.Rand) ([]Question, error) {
+	chapters, err := resolveChapterChoices(course, chapterChoices)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, chapKey := range targetChapterKeys {
-		chapterQuestions, ok := questionsByChapter[chapKey]
-		if ok {
-			questionsToProcess = append(questionsToProcess, chapterQuestions...)
+	var questionsToProcess []Question
+	for _, chapter := range chapters {
+		questionsByChapter, _, ok := getCourseQuestionBank(chapter.Course)
+		if !ok {
+			continue
+		}
+		for _, q := range questionsByChapter[chapter.ChapterKey] {
+			questionsToProcess = append(questionsToProcess, getCorrectedQuestion(q)) // 应用管理员修正
 		}
 	}
 
 	if len(questionsToProcess) == 0 {
-		return []Question{} // 如果没有选出任何题目,返回空切片
+		return []Question{}, nil // 如果没有选出任何题目,返回空切片
 	}
 
 	// 根据选择的顺序处理题目
-	if orderChoice == "random" || orderChoice == "1" { // "1" 作为 "random" 的别名
-		rand.Shuffle(len(questionsToProcess), func(i, j int) {
+	if isRandomOrder(orderChoice) {
+		if rng == nil {
+			rng, _ = newRunRNG(0)
+		}
+		rng.Shuffle(len(questionsToProcess), func(i, j int) {
 			questionsToProcess[i], questionsToProcess[j] = questionsToProcess[j], questionsToProcess[i]
 		})
 	}
 	// 如果是 "sequential" 或 "0" (或任何其他值),则按原始顺序（章节顺序,章节内题目顺序）
-	return questionsToProcess
+	return questionsToProcess, nil
+}
+
+// selectQuestionsForStart 根据开始请求选出题目：章节与顺序、题目来源、知识点标签，并返回本轮使用的随机种子
+func selectQuestionsForStart(ctx context.Context, req StartModeRequest) ([]Question, int64, error) {
+	rng, seed := newRunRNG(req.Seed)
+	selectedQuestions, err := _getQuestionsForProcessing(req.Course, req.ChapterChoice, req.OrderChoice, rng)
+	if err != nil {
+		return nil, 0, err
+	}
+	selectedQuestions, err = applyQuestionSource(req.UserID, req.Source, selectedQuestions)
+	if err != nil {
+		var dataErr *userDataError
+		if errors.As(err, &dataErr) {
+			slog.ErrorContext(ctx, "按来源筛选题目失败", logKeyUserID, req.UserID, "source", req.Source, "error", err)
+		}
+		return nil, 0, err // 未知来源为请求错误，其余为读取用户数据失败
+	}
+	return filterQuestionsByTags(selectedQuestions, req.Tags), seed, nil
 }
 
 // --- DTO转换函数 ---
 
 // convertQuestionsToOutput 将原始 Question 结构体列表转换为 QuestionOutput 列表，用于API响应。
-// 始终包含答案。
-func convertQuestionsToOutput(questions []Question, sessionIndexOffset int, course string) []QuestionOutput {
+// 始终包含答案和全局答对、答错人数，作答人数足够时附带全局难度。题目ID中的课程始终是题目的来源课程，因此虚拟课程下的题目也能正确归属。
+func convertQuestionsToOutput(questions []Question, sessionIndexOffset int) []QuestionOutput {
 	output := make([]QuestionOutput, len(questions))
 	for i, q := range questions {
+		q = withGlobalCounts(q)
 		output[i] = QuestionOutput{
-			QuizQuestionID:         fmt.Sprintf("quiz_%s_%s_%d", course, q.OriginalChapterKey, q.OriginalIndex), // 唯一ID，格式: quiz_课程_章节_原始索引
-			DisplayNumber:          sessionIndexOffset + i + 1,                                                  // 基于最终列表的显示序号 (1-based)
+			QuizQuestionID:         fmt.Sprintf("quiz_%s_%s_%d", q.OriginalCourse, q.OriginalChapterKey, q.OriginalIndex), // 唯一ID，格式: quiz_课程_章节_原始索引
+			DisplayNumber:          sessionIndexOffset + i + 1,                                                            // 基于最终列表的显示序号 (1-based)
+			QuestionID:             questionIDOf(q),
+			OriginalCourse:         q.OriginalCourse,
 			OriginalChapter:        q.OriginalChapterKey,
 			OriginalQuestionNumber: q.QuestionNumber,
 			QuestionType:           q.QuestionType,
 			QuestionText:           q.QuestionText,
 			Options:                q.Options,
 			CorrectAnswer:          q.CorrectAnswer, // 始终包含答案
+			Tags:                   getQuestionTags(questionIDOf(q)),
+			GlobalCorrectCount:     q.GlobalCorrectCount,
+			GlobalErrorCount:       q.GlobalErrorCount,
 		}
 	}
-	return output
+	return withGlobalDifficulty(output)
 }
 
 // convertUserIncorrectToOutput 将用户错题列表 UserIncorrectQuestion 转换为 QuestionOutput 列表。
-// 始终包含答案。
-func convertUserIncorrectToOutput(incorrectQs []UserIncorrectQuestion, sessionIndexOffset int, course string) []QuestionOutput {
+// 始终包含答案。错题应已通过 OriginalCourse 标记来源课程。
+func convertUserIncorrectToOutput(incorrectQs []UserIncorrectQuestion, sessionIndexOffset int) []QuestionOutput {
 	output := make([]QuestionOutput, len(incorrectQs))
 	for i, iq := range incorrectQs {
 		// 为错题生成一个唯一的 QuizQuestionID，可以加上时间戳或随机数以区分同一道题的多次回顾（如果需要）
-		// 这里简化处理，基于原始章节和题号，加上列表索引
+		// 这里简化处理，基于来源课程、原始章节和题号，加上列表索引
+		questionID := ""
+		if q, ok := findQuestionByNumber(iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber); ok {
+			questionID = questionIDOf(q)
+		}
 		output[i] = QuestionOutput{
-			QuizQuestionID:         fmt.Sprintf("incorrect_%s_%s_%s_%d", course, iq.OriginalChapter, iq.QuestionNumber, sessionIndexOffset+i),
+			QuizQuestionID:         fmt.Sprintf("incorrect_%s_%s_%s_%d", iq.OriginalCourse, iq.OriginalChapter, iq.QuestionNumber, sessionIndexOffset+i),
+			QuestionID:             questionID,
 			DisplayNumber:          sessionIndexOffset + i + 1,
+			OriginalCourse:         iq.OriginalCourse,
 			OriginalChapter:        iq.OriginalChapter,
 			OriginalQuestionNumber: iq.QuestionNumber,
 			QuestionType:           iq.QuestionType,
 			QuestionText:           iq.QuestionText,
 			Options:                iq.Options,
 			CorrectAnswer:          iq.CorrectAnswer, // 始终包含答案
+			Tags:                   getQuestionTags(questionID),
 		}
 	}
-	return output
+	return withGlobalDifficulty(output)
 }
 
 // --- API 处理函数 ---
@@ -342,12 +418,19 @@ func convertUserIncorrectToOutput(incorrectQs []UserIncorrectQuestion, sessionIn
 func InitSessionHandler(ctx context.Context, c *app.RequestContext) {
 	var req InitSessionRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效的请求: user_id 是必需的。 " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
+	resp, err := initUserSession(ctx, req.UserID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
 
-	userID := req.UserID
-
+// initUserSession 建立用户会话，首次使用时创建用户目录
+func initUserSession(ctx context.Context, userID string) (SessionResponse, error) {
 	// 检查用户数据目录是否存在，以判断是新用户还是返回用户
 	userDir := filepath.Join(userDataBaseDir, userID)
 	isNewUser := false
@@ -355,16 +438,14 @@ func InitSessionHandler(ctx context.Context, c *app.RequestContext) {
 		isNewUser = true
 		// 确保为新用户创建目录
 		if errDir := ensureUserDir(userID); errDir != nil {
-			log.Printf("错误: 为用户 %s 创建目录 %s 失败: %v", userID, userDir, errDir)
-			c.JSON(consts.StatusInternalServerError, utils.H{"error": "无法初始化用户数据存储区"})
-			return
+			slog.ErrorContext(ctx, "创建用户目录失败", logKeyUserID, userID, "error", errDir)
+			return SessionResponse{}, &userDataError{Message: "无法初始化用户数据存储区", Err: errDir}
 		}
-		log.Printf("信息: 新用户 %s 首次使用，已创建用户目录: %s", userID, userDir)
+		slog.InfoContext(ctx, "新用户首次使用，已创建用户目录", logKeyUserID, userID)
 	} else if err != nil {
 		// 其他 os.Stat 错误
-		log.Printf("错误: 检查用户目录 %s 时发生错误: %v", userDir, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "检查用户数据时出错"})
-		return
+		slog.ErrorContext(ctx, "检查用户目录失败", logKeyUserID, userID, "error", err)
+		return SessionResponse{}, &userDataError{Message: "检查用户数据时出错", Err: err}
 	}
 
 	session := getOrCreateUserSession(userID) // 获取或创建内存中的会话
@@ -372,15 +453,22 @@ func InitSessionHandler(ctx context.Context, c *app.RequestContext) {
 	message := "用户会话已建立"
 	if isNewUser {
 		message = "新用户会话已创建并初始化成功"
-		log.Printf("会话: 新用户 %s 的会话已在内存中创建。", userID)
-	} else {
-		log.Printf("会话: 用户 %s 的会话已从内存中获取或新建。", userID)
 	}
+	slog.DebugContext(ctx, "用户会话已建立", logKeyUserID, userID, "new_user", isNewUser)
 
 	// 可以在这里预加载一些用户数据到会话中，如果需要的话
 	// 例如: session.SomeData = loadSpecificDataForUser(userID)
 
-	c.JSON(consts.StatusOK, utils.H{"user_id": session.UserID, "message": message, "is_new_user": isNewUser})
+	resp := SessionResponse{UserID: session.UserID, Message: message, IsNewUser: isNewUser}
+	if appConfig.Features.Assignments {
+		// 登录时告诉学生还有哪些作业没完成；读取失败不影响登录
+		if pending, err := studentAssignments(userID, true); err != nil {
+			slog.WarnContext(ctx, "读取作业失败", logKeyUserID, userID, "error", err)
+		} else {
+			resp.Assignments = pending
+		}
+	}
+	return resp, nil
 }
 
 // QuickReviewStartHandler 处理开始速刷模式的请求。
@@ -388,34 +476,47 @@ func InitSessionHandler(ctx context.Context, c *app.RequestContext) {
 func QuickReviewStartHandler(ctx context.Context, c *app.RequestContext) {
 	var req StartModeRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
+	resp, err := startQuickReview(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
 
+// startQuickReview 开始速刷模式，返回所有选定问题及拆解
+func startQuickReview(ctx context.Context, req StartModeRequest) (QuestionSetResponse, error) {
 	session := getOrCreateUserSession(req.UserID)
 	session.mu.Lock() // 如果要修改会话状态（如 CurrentMode），则加锁
 	defer session.mu.Unlock()
 
-	selectedQuestions := _getQuestionsForProcessing(req.Course, req.ChapterChoice, req.OrderChoice)
+	selectedQuestions, seed, err := selectQuestionsForStart(ctx, req)
+	if err != nil {
+		return QuestionSetResponse{}, err
+	}
 	if len(selectedQuestions) == 0 {
-		c.JSON(consts.StatusOK, utils.H{"message": "所选范围没有题目。", "total_questions": 0, "questions": []QuestionOutput{}})
-		return
+		return QuestionSetResponse{Message: "所选范围没有题目。", Questions: []QuestionOutput{}, Seed: seed}, nil
 	}
 
-	outputQuestions := convertQuestionsToOutput(selectedQuestions, 0, req.Course) // 0 表示从列表开头计数
-	session.CurrentMode = "review"                                                // 设置当前模式
-	session.CurrentCourse = req.Course                                            // 设置当前课程
+	outputQuestions := withExplanations(convertQuestionsToOutput(selectedQuestions, 0), selectedQuestions) // 0 表示从列表开头计数；速刷模式直接附带拆解
+	outputQuestions = withUserNotes(req.UserID, outputQuestions)
+	session.CurrentMode = "review"     // 设置当前模式
+	session.CurrentCourse = req.Course // 设置当前课程
 	// 如果 /api/review/next 仍然用于逐步获取，则需要存储这些问题
 	// 否则，如果前端一次性处理所有问题，这一步可以省略或用于其他目的
 	session.CurrentQuestions = outputQuestions
 	session.CurrentQuestionIndex = 0 // 从第一题开始
 
-	log.Printf("用户 %s 开始速刷模式，课程: %s, 章节: %v, 顺序: %s, 返回 %d 题", req.UserID, req.Course, req.ChapterChoice, req.OrderChoice, len(outputQuestions))
-	c.JSON(consts.StatusOK, utils.H{
-		"message":         "速刷模式开始",
-		"total_questions": len(outputQuestions),
-		"questions":       outputQuestions, // 发送所有问题给前端
-	})
+	slog.InfoContext(ctx, "开始速刷模式", logKeyUserID, req.UserID, "course", req.Course, "chapters", req.ChapterChoice, "order", req.OrderChoice, "seed", seed, "count", len(outputQuestions))
+	return QuestionSetResponse{
+		Message:        "速刷模式开始",
+		TotalQuestions: len(outputQuestions),
+		Questions:      outputQuestions, // 发送所有问题给前端
+		Seed:           seed,            // 本轮使用的随机种子，相同的种子和选择可以重现同样的顺序
+	}, nil
 }
 
 // GetNextQuestionHandler 处理获取速刷模式下一题的请求。
@@ -424,7 +525,7 @@ func QuickReviewStartHandler(ctx context.Context, c *app.RequestContext) {
 func GetNextQuestionHandler(ctx context.Context, c *app.RequestContext) {
 	var req GetNextQuestionRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
 	session := getOrCreateUserSession(req.UserID)
@@ -432,7 +533,7 @@ func GetNextQuestionHandler(ctx context.Context, c *app.RequestContext) {
 	defer session.mu.Unlock()
 
 	if session.CurrentMode != "review" { // 确保当前是速刷模式
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "当前不处于速刷模式 (或会话模式不匹配)"})
+		writeError(c, newAppError(codeWrongMode, "速刷"))
 		return
 	}
 
@@ -445,7 +546,7 @@ func GetNextQuestionHandler(ctx context.Context, c *app.RequestContext) {
 	}
 
 	nextQuestionOutput := session.CurrentQuestions[session.CurrentQuestionIndex]
-	log.Printf("用户 %s 在速刷模式下通过API获取下一题, 序号 %d (原始问题ID: %s)", req.UserID, nextQuestionOutput.DisplayNumber, nextQuestionOutput.QuizQuestionID)
+	slog.DebugContext(ctx, "速刷模式获取下一题", logKeyUserID, req.UserID, "number", nextQuestionOutput.DisplayNumber, "question_id", nextQuestionOutput.QuizQuestionID)
 	c.JSON(consts.StatusOK, utils.H{
 		"question":       nextQuestionOutput,
 		"quiz_completed": false,
@@ -457,33 +558,48 @@ func GetNextQuestionHandler(ctx context.Context, c *app.RequestContext) {
 func QuizStartHandler(ctx context.Context, c *app.RequestContext) {
 	var req StartModeRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := startQuiz(ctx, req)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// startQuiz 开始答题模式，返回所有选定问题（含答案，由前端在提交前隐藏）
+func startQuiz(ctx context.Context, req StartModeRequest) (QuestionSetResponse, error) {
 	session := getOrCreateUserSession(req.UserID)
 	session.mu.Lock()
 	defer session.mu.Unlock()
 
-	selectedQuestions := _getQuestionsForProcessing(req.Course, req.ChapterChoice, req.OrderChoice)
+	selectedQuestions, seed, err := selectQuestionsForStart(ctx, req)
+	if err != nil {
+		return QuestionSetResponse{}, err
+	}
 	if len(selectedQuestions) == 0 {
-		c.JSON(consts.StatusOK, utils.H{"message": "所选范围没有题目。", "total_questions": 0, "questions": []QuestionOutput{}})
-		return
+		return QuestionSetResponse{Message: "所选范围没有题目。", Questions: []QuestionOutput{}, Seed: seed}, nil
 	}
 
-	outputQuestions := convertQuestionsToOutput(selectedQuestions, 0, req.Course)
+	outputQuestions := withUserNotes(req.UserID, convertQuestionsToOutput(selectedQuestions, 0))
 	session.CurrentMode = "quiz"       // 设置模式，用于提交答案时的上下文
 	session.CurrentCourse = req.Course // 设置当前课程
+	// 新一轮答题，清空出题时间
+	session.ServedAt = make(map[string]time.Time)
 
 	// 如果前端完全管理题目列表和导航，则不在会话中存储 CurrentQuestions 和 CurrentQuestionIndex
 	// session.CurrentQuestions = outputQuestions
 	// session.CurrentQuestionIndex = 0
 
-	log.Printf("用户 %s 开始答题模式，课程: %s, 章节: %v, 顺序: %s, 返回 %d 题", req.UserID, req.Course, req.ChapterChoice, req.OrderChoice, len(outputQuestions))
-	c.JSON(consts.StatusOK, utils.H{
-		"message":         "答题模式开始",
-		"total_questions": len(outputQuestions),
-		"questions":       outputQuestions, // 发送所有问题给前端
-	})
+	slog.InfoContext(ctx, "开始答题模式", logKeyUserID, req.UserID, "course", req.Course, "chapters", req.ChapterChoice, "order", req.OrderChoice, "seed", seed, "count", len(outputQuestions))
+	return QuestionSetResponse{
+		Message:        "答题模式开始",
+		TotalQuestions: len(outputQuestions),
+		Questions:      outputQuestions, // 发送所有问题给前端
+		Seed:           seed,            // 本轮使用的随机种子
+	}, nil
 }
 
 // SubmitAnswerHandler 处理用户在答题模式下提交的答案。
@@ -491,163 +607,233 @@ func QuizStartHandler(ctx context.Context, c *app.RequestContext) {
 func SubmitAnswerHandler(ctx context.Context, c *app.RequestContext) {
 	var req SubmitAnswerRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		log.Printf("[DEBUG] SubmitAnswerHandler 绑定请求失败: %v", err)
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		slog.DebugContext(ctx, "答题提交请求绑定失败", "error", err)
+		writeError(c, invalidRequest(err))
+		return
+	}
+	resp, err := submitQuizAnswer(ctx, req)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
-	log.Printf("[DEBUG] SubmitAnswerHandler 收到请求: UserID=%s, QuizQuestionID=%s, UserAnswer=%s, WasCorrect=%t",
-		req.UserID, req.QuizQuestionID, req.UserAnswer, req.WasCorrect)
+	c.JSON(consts.StatusOK, resp)
+}
+
+// submitQuizAnswer 记录答题模式下的一次作答，返回拆解与出处（如果有）、本题用时和作业进度。
+// 题目ID无法解析为请求错误，题库中没有该题时返回 errUnknownQuestion。
+func submitQuizAnswer(ctx context.Context, req SubmitAnswerRequest) (AnswerFeedback, error) {
+	slog.DebugContext(ctx, "收到答题提交", logKeyUserID, req.UserID, "question_id", req.QuizQuestionID, "answer", req.UserAnswer, "correct", req.WasCorrect)
+
+	// 从 QuizQuestionID 中解析出原始题目信息 (课程、章节号和原始索引)
+	// 题目ID中携带来源课程，因此即使会话处于虚拟课程，统计和错题也归属到来源课程
+	coursePart, chapterPart, indexPart, err := parseQuizQuestionID(req.QuizQuestionID)
+	if err != nil {
+		return AnswerFeedback{}, err // 客户端传来的ID有误
+	}
+	originalQuestionIDKey := fmt.Sprintf("%s_%s_%s", coursePart, chapterPart, indexPart) // 重组为 "course_chapter_index"
+	originalQuestion, ok := lookupQuestion(originalQuestionIDKey)                        // 已应用管理员修正
+	if !ok {
+		slog.WarnContext(ctx, "找不到答题对应的原始题目", "question_id", req.QuizQuestionID, "key", originalQuestionIDKey)
+		return AnswerFeedback{}, errUnknownQuestion.with(req.QuizQuestionID)
+	}
 
-	// 从用户 session 获取当前课程
 	session := getOrCreateUserSession(req.UserID)
 	session.mu.Lock()
-	currentCourse := session.CurrentCourse
+	timeSpent, timeSource := measureAnswerTime(session, req.QuizQuestionID, req.TimeSpentMs)
 	session.mu.Unlock()
 
-	if currentCourse == "" {
-		log.Printf("错误: 用户 %s 的 session 中没有课程信息", req.UserID)
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "会话中缺少课程信息"})
-		return
+	if _, err := recordQuizAnswer(ctx, req.UserID, originalQuestion, req.UserAnswer, req.WasCorrect, timeSpent); err != nil {
+		return AnswerFeedback{}, err
 	}
 
-	// 从 QuizQuestionID 中解析出原始题目信息 (课程、章节号和原始索引)
-	// QuizQuestionID 格式为 "quiz_<course>_<chapter>_<index>"，其中 course 可能包含下划线（例如 xigai_li）
-	raw := strings.TrimPrefix(req.QuizQuestionID, "quiz_")
-	parts := strings.Split(raw, "_")
-	if len(parts) < 3 { // 至少需要课程、章节和索引三部分
-		log.Printf("错误: 无法从 QuizQuestionID %s 解析原始题目信息 (答题模式)", req.QuizQuestionID)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "内部服务器错误，无法解析题目ID (quiz_submit)"})
-		return
+	slog.DebugContext(ctx, "答题已记录", logKeyUserID, req.UserID, "question_id", req.QuizQuestionID, "answer", req.UserAnswer, "correct", req.WasCorrect,
+		"time_spent", timeSpent.Round(time.Millisecond), "time_source", timeSource)
+	// 后端不再指示下一题或完成状态，前端基于其完整的题目列表进行管理
+	// 答题后返回拆解与出处（如果有），以及本题用时
+	resp := answerFeedback(originalQuestion, "答案已记录 (前端校验)")
+	if timeSpent > 0 {
+		slow := isSlowAnswer(originalQuestion.QuestionType, timeSpent)
+		resp.TimeSpentMs = timeSpent.Milliseconds()
+		resp.Slow = &slow
 	}
-	// course 可能有多个下划线段，章节是倒数第二段，索引是最后一段
-	coursePart := strings.Join(parts[:len(parts)-2], "_")
-	chapterPart := parts[len(parts)-2]
-	indexPart := parts[len(parts)-1]
-	originalQuestionIDKey := fmt.Sprintf("%s_%s_%s", coursePart, chapterPart, indexPart) // 重组为 "course_chapter_index"
-	originalQuestion, ok := questionMapByID[originalQuestionIDKey]
-	if !ok {
-		log.Printf("错误: 找不到 QuizQuestionID %s (解析为Key: %s) 对应的原始题目 (答题模式)", req.QuizQuestionID, originalQuestionIDKey)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "内部服务器错误，找不到原始题目 (quiz_submit_map)"})
-		return
+	if req.AssignmentID != "" {
+		// 作业中的题目同时记入作业进度；作业记录失败不影响本次答题的统计
+		progress, err := recordAssignmentAnswer(req.UserID, req.AssignmentID, originalQuestion, req.UserAnswer)
+		if err != nil {
+			slog.WarnContext(ctx, "记录作业作答失败", logKeyUserID, req.UserID, "assignment_id", req.AssignmentID, "error", err)
+		} else {
+			resp.AssignmentProgress = &progress
+		}
 	}
+	return resp, nil
+}
 
+// recordQuizAnswer 记录一次答题：更新题目统计，答错时加入来源课程的错题本，返回这道题是否新加入了错题本
+// （已在错题本中的题不会重复加入）。网页端的答题提交和终端客户端共用此逻辑。
+func recordQuizAnswer(ctx context.Context, userID string, q Question, userAnswer string, wasCorrect bool, timeSpent time.Duration) (bool, error) {
 	// 加载或初始化用户统计数据
-	userStats := make(map[string]UserQuestionStat)
-	if err := loadUserJSONData(req.UserID, questionStatsFile, &userStats); err != nil {
-		log.Printf("错误: 用户 %s 加载统计数据失败 (答题提交): %v", req.UserID, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "加载用户统计数据失败"})
-		return
+	userStats, err := loadUserStats(userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "加载统计数据失败", logKeyUserID, userID, "error", err)
+		return false, err
 	}
 
-	statKey := fmt.Sprintf("%s_%s", originalQuestion.OriginalChapterKey, originalQuestion.QuestionNumber) // 统计文件中的键
+	currentCourse := q.OriginalCourse
+	statKey := getQuestionStatKey(currentCourse, q.OriginalChapterKey, q.QuestionNumber) // 统计文件中的键
 	statEntry, statExists := userStats[statKey]
+	if !statExists {
+		// 仍保留旧键、能确定属于本课程的统计，改用带课程的键；无法确定课程的旧统计保持不变
+		if legacy, ok := legacyStatFor(userStats, currentCourse, q.OriginalChapterKey, q.QuestionNumber); ok {
+			statEntry, statExists = legacy, true
+			statEntry.OriginalCourse = currentCourse
+			delete(userStats, legacyQuestionStatKey(q.OriginalChapterKey, q.QuestionNumber))
+		}
+	}
 	if !statExists {
 		statEntry = UserQuestionStat{
-			OriginalChapterKey:     originalQuestion.OriginalChapterKey,
-			OriginalQuestionNumber: originalQuestion.QuestionNumber,
+			OriginalCourse:         currentCourse,
+			OriginalChapterKey:     q.OriginalChapterKey,
+			OriginalQuestionNumber: q.QuestionNumber,
 		}
 	}
 
-	if req.WasCorrect {
+	addedToIncorrect := false
+	if wasCorrect {
 		statEntry.CorrectCount++
 	} else {
 		statEntry.ErrorCount++
 		// 如果答错，则记录到课程特定的错题本
 		incorrectFileName := getIncorrectQuestionsFileName(currentCourse)
 		userIncorrect := []UserIncorrectQuestion{}
-		if err := loadUserJSONData(req.UserID, incorrectFileName, &userIncorrect); err != nil {
-			log.Printf("错误: 用户 %s 加载错题本失败 (答题提交): %v", req.UserID, err)
-			c.JSON(consts.StatusInternalServerError, utils.H{"error": "加载用户错题本失败"})
-			return
+		if err := loadUserJSONData(userID, incorrectFileName, &userIncorrect); err != nil {
+			slog.ErrorContext(ctx, "加载错题本失败", logKeyUserID, userID, "course", currentCourse, "error", err)
+			return false, &userDataError{Message: "加载用户错题本失败", Err: err}
 		}
 
 		// 检查是否重复添加 (基于题目文本和原始章节，避免同一道题记录多次)
 		isDuplicate := false
 		for _, iq := range userIncorrect {
-			if iq.QuestionText == originalQuestion.QuestionText && iq.OriginalChapter == originalQuestion.OriginalChapterKey {
+			if iq.QuestionText == q.QuestionText && iq.OriginalChapter == q.OriginalChapterKey {
 				isDuplicate = true
-				log.Printf("信息: 用户 %s 题目 %s (章节 %s, 课程 %s) 已在错题本中，不再重复添加。", req.UserID, originalQuestion.QuestionNumber, originalQuestion.OriginalChapterKey, currentCourse)
+				slog.DebugContext(ctx, "题目已在错题本中，不再重复添加", logKeyUserID, userID, "course", currentCourse, "chapter", q.OriginalChapterKey, "question_number", q.QuestionNumber)
 				break
 			}
 		}
 		if !isDuplicate {
 			userIncorrect = append(userIncorrect, UserIncorrectQuestion{
-				QuestionNumber:  originalQuestion.QuestionNumber,
-				QuestionType:    originalQuestion.QuestionType,
-				QuestionText:    originalQuestion.QuestionText,
-				Options:         originalQuestion.Options,
-				CorrectAnswer:   originalQuestion.CorrectAnswer,
-				OriginalChapter: originalQuestion.OriginalChapterKey,
-				UserAnswer:      req.UserAnswer, // 记录用户当时的错误答案
+				QuestionNumber:  q.QuestionNumber,
+				QuestionType:    q.QuestionType,
+				QuestionText:    q.QuestionText,
+				Options:         q.Options,
+				CorrectAnswer:   q.CorrectAnswer,
+				OriginalChapter: q.OriginalChapterKey,
+				OriginalCourse:  currentCourse,
+				UserAnswer:      userAnswer, // 记录用户当时的错误答案
 				Timestamp:       time.Now(),
 			})
-			if err := saveUserJSONData(req.UserID, incorrectFileName, userIncorrect); err != nil {
-				log.Printf("错误: 用户 %s 保存错题本失败 (答题提交): %v", req.UserID, err)
-				c.JSON(consts.StatusInternalServerError, utils.H{"error": "保存用户错题本失败"})
-				return
+			if err := saveUserJSONData(userID, incorrectFileName, userIncorrect); err != nil {
+				slog.ErrorContext(ctx, "保存错题本失败", logKeyUserID, userID, "course", currentCourse, "error", err)
+				return false, &userDataError{Message: "保存用户错题本失败", Err: err}
 			}
-			log.Printf("信息: 用户 %s 错题 %s (章节 %s) 已添加至错题本。", req.UserID, originalQuestion.QuestionNumber, originalQuestion.OriginalChapterKey)
+			addedToIncorrect = true
+			slog.DebugContext(ctx, "错题已加入错题本", logKeyUserID, userID, "course", currentCourse, "chapter", q.OriginalChapterKey, "question_number", q.QuestionNumber)
 		}
 	}
 	statEntry.LastAnswered = time.Now()
+	if timeSpent > 0 {
+		statEntry.TimedCount++
+		statEntry.TotalTimeMs += timeSpent.Milliseconds()
+		statEntry.LastTimeMs = timeSpent.Milliseconds()
+	}
 	userStats[statKey] = statEntry
 
-	if err := saveUserJSONData(req.UserID, questionStatsFile, userStats); err != nil {
-		log.Printf("错误: 用户 %s 保存统计数据失败 (答题提交): %v", req.UserID, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "保存用户统计数据失败"})
-		return
+	if err := saveUserJSONData(userID, questionStatsFile, userStats); err != nil {
+		slog.ErrorContext(ctx, "保存统计数据失败", logKeyUserID, userID, "error", err)
+		return false, &userDataError{Message: "保存用户统计数据失败", Err: err}
+	}
+	countAnswerSubmitted(q.OriginalCourse)    // 统计保存成功后才计入提交次数
+	recordGlobalAnswer(userID, q, wasCorrect) // 全局统计只影响题目难度，定时写入文件
+	// 学习记录只影响日历和连续天数，失败时不影响本次答题
+	if err := recordStudyActivity(userID, statEntry.LastAnswered, timeSpent, currentCourse, wasCorrect); err != nil {
+		slog.WarnContext(ctx, "记录学习日历失败", logKeyUserID, userID, "error", err)
 	}
+	return addedToIncorrect, nil
+}
 
-	log.Printf("用户 %s 答题模式提交: QID %s, 用户答案 %s, 是否正确 (前端判断): %t. 统计和错题记录已更新。", req.UserID, req.QuizQuestionID, req.UserAnswer, req.WasCorrect)
-	c.JSON(consts.StatusOK, utils.H{
-		"message": "答案已记录 (前端校验)",
-		// 后端不再指示下一题或完成状态，前端基于其完整的题目列表进行管理
-	})
+// userDataError 表示读写数据文件失败，Message 为返回给客户端的提示（错误码为 storage_error，译文见 messageBundles）
+type userDataError struct {
+	Message string
+	Err     error
 }
 
+func (e *userDataError) Error() string { return e.Message + ": " + e.Err.Error() }
+
+func (e *userDataError) Unwrap() error { return e.Err }
+
+// userDataErrorMessage 返回适合展示给用户的错误提示
+func userDataErrorMessage(err error) string {
+	var dataErr *userDataError
+	if errors.As(err, &dataErr) {
+		return dataErr.Message
+	}
+	return "保存用户数据失败"
+}
+
+// errUnknownQuestion 题目ID格式正确，但题库中没有对应的题目
+var errUnknownQuestion = &appError{Code: codeQuestionNotFound}
+
 // IncorrectQuestionsReviewStartHandler 处理开始错题回顾模式的请求。
 // 返回用户的所有错题及其答案。
 func IncorrectQuestionsReviewStartHandler(ctx context.Context, c *app.RequestContext) {
 	var req StartIncorrectReviewRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
+	resp, err := startIncorrectReview(ctx, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// startIncorrectReview 开始错题回顾，返回打乱顺序后的全部错题
+func startIncorrectReview(ctx context.Context, req StartIncorrectReviewRequest) (QuestionSetResponse, error) {
 	session := getOrCreateUserSession(req.UserID)
 	session.mu.Lock()
 	defer session.mu.Unlock()
 
-	incorrectFileName := getIncorrectQuestionsFileName(req.Course)
-	userIncorrectRaw := []UserIncorrectQuestion{}
-	if err := loadUserJSONData(req.UserID, incorrectFileName, &userIncorrectRaw); err != nil {
-		log.Printf("用户 %s 加载 %s 课程错题本失败 (回顾模式开始): %v", req.UserID, req.Course, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "加载用户错题本失败"})
-		return
+	userIncorrectRaw, err := loadUserIncorrectForCourse(req.UserID, req.Course)
+	if err != nil {
+		slog.ErrorContext(ctx, "加载错题本失败", logKeyUserID, req.UserID, "course", req.Course, "error", err)
+		return QuestionSetResponse{}, &userDataError{Message: "加载用户错题本失败", Err: err}
 	}
 
+	rng, seed := newRunRNG(req.Seed)
 	if len(userIncorrectRaw) == 0 {
-		c.JSON(consts.StatusOK, utils.H{"message": "错题簿是空的哦！太棒了！", "total_questions": 0, "questions": []QuestionOutput{}})
-		return
+		return QuestionSetResponse{Message: "错题簿是空的哦！太棒了！", Questions: []QuestionOutput{}, Seed: seed}, nil
 	}
 
 	// 将错题随机打乱顺序
-	rand.Shuffle(len(userIncorrectRaw), func(i, j int) {
+	rng.Shuffle(len(userIncorrectRaw), func(i, j int) {
 		userIncorrectRaw[i], userIncorrectRaw[j] = userIncorrectRaw[j], userIncorrectRaw[i]
 	})
 
-	outputQuestions := convertUserIncorrectToOutput(userIncorrectRaw, 0, req.Course) // 转换为API输出格式
+	outputQuestions := withUserNotes(req.UserID, convertUserIncorrectToOutput(userIncorrectRaw, 0)) // 转换为API输出格式
 	session.CurrentMode = "incorrect_review"
 	session.CurrentCourse = req.Course // 设置当前课程
 	// 如果前端需要服务器逐步推送，则存储
 	// session.CurrentQuestions = outputQuestions
 	// session.CurrentQuestionIndex = 0
 
-	log.Printf("用户 %s 开始错题回顾模式, 返回 %d 题", req.UserID, len(outputQuestions))
-	c.JSON(consts.StatusOK, utils.H{
-		"message":         "错题回顾模式开始",
-		"total_questions": len(outputQuestions),
-		"questions":       outputQuestions, // 发送所有错题给前端
-	})
+	slog.InfoContext(ctx, "开始错题回顾模式", logKeyUserID, req.UserID, "course", req.Course, "seed", seed, "count", len(outputQuestions))
+	return QuestionSetResponse{
+		Message:        "错题回顾模式开始",
+		TotalQuestions: len(outputQuestions),
+		Questions:      outputQuestions, // 发送所有错题给前端
+		Seed:           seed,            // 本轮使用的随机种子
+	}, nil
 }
 
 // SubmitIncorrectReviewAnswerHandler 处理用户在错题回顾中提交的答案。
@@ -656,176 +842,204 @@ func IncorrectQuestionsReviewStartHandler(ctx context.Context, c *app.RequestCon
 func SubmitIncorrectReviewAnswerHandler(ctx context.Context, c *app.RequestContext) {
 	var req SubmitAnswerRequest // 复用 SubmitAnswerRequest 结构
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
+	c.JSON(consts.StatusOK, submitIncorrectReviewAnswer(ctx, req))
+}
 
+// submitIncorrectReviewAnswer 记录错题回顾中的一次作答（仅日志和学习日历），返回拆解与出处
+func submitIncorrectReviewAnswer(ctx context.Context, req SubmitAnswerRequest) AnswerFeedback {
 	// 此处不修改会话状态或持久化数据，因为前端已包含答案并进行校验。
 	// 主要用于服务端日志记录，了解用户对错题的再次作答情况。
-	log.Printf("用户 %s 错题回顾提交: QID %s, 用户答案 %s, 是否正确 (前端判断): %t. (仅记录日志)",
-		req.UserID, req.QuizQuestionID, req.UserAnswer, req.WasCorrect)
+	slog.DebugContext(ctx, "错题回顾作答", logKeyUserID, req.UserID, "question_id", req.QuizQuestionID, "answer", req.UserAnswer, "correct", req.WasCorrect)
+
+	// 错题回顾同样计入学习日历
+	if err := recordStudyActivity(req.UserID, time.Now(), 0, "", false); err != nil {
+		slog.WarnContext(ctx, "记录学习日历失败", logKeyUserID, req.UserID, "error", err)
+	}
 
 	// 未来可以考虑：如果用户在回顾中答对了错题，是否从错题本中移除或标记。
 	// 这需要更复杂的逻辑，例如解析 QuizQuestionID 找到原始错题记录并更新。
 
-	c.JSON(consts.StatusOK, utils.H{
-		"message": "错题回顾答案已由服务器记录(日志), 由前端校验正确性。",
-	})
+	// 答题后返回拆解与出处（如果能找到原始题目）
+	const message = "错题回顾答案已由服务器记录(日志), 由前端校验正确性。"
+	if course, chapterKey, questionNumber, err := parseIncorrectQuestionID(req.QuizQuestionID); err == nil {
+		countAnswerSubmitted(course)
+		if q, ok := findQuestionByNumber(course, chapterKey, questionNumber); ok {
+			return answerFeedback(q, message)
+		}
+	}
+	return AnswerFeedback{Message: message}
 }
 
 // DeleteIncorrectQuestionHandler 处理从错题本中删除特定题目的请求
 func DeleteIncorrectQuestionHandler(ctx context.Context, c *app.RequestContext) {
 	var req DeleteIncorrectQuestionRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
 
-	// 从 session 获取当前课程
-	session := getOrCreateUserSession(req.UserID)
-	session.mu.Lock()
-	currentCourse := session.CurrentCourse
-	session.mu.Unlock()
-
-	if currentCourse == "" {
-		log.Printf("警告: 用户 %s 的 session 中没有课程信息，默认使用毛概", req.UserID)
-		currentCourse = "maogai"
-	}
-
-	// 加载课程特定的错题文件
-	incorrectFileName := getIncorrectQuestionsFileName(currentCourse)
-	userIncorrect := []UserIncorrectQuestion{}
-	if err := loadUserJSONData(req.UserID, incorrectFileName, &userIncorrect); err != nil {
-		log.Printf("错误: 用户 %s 删除错题时加载 %s 课程错题本失败: %v", req.UserID, currentCourse, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "加载用户错题本失败"})
+	if _, err := deleteIncorrectQuestion(ctx, req.UserID, sessionCourseOrDefault(ctx, req.UserID, req.Course), req.OriginalChapter, req.OriginalQuestionNumber); err != nil {
+		writeError(c, err)
 		return
 	}
 
-	if len(userIncorrect) == 0 {
-		log.Printf("信息: 用户 %s 请求删除错题，但错题本已为空。", req.UserID)
-		c.Status(consts.StatusNoContent)
-		return
+	// 按照要求，成功处理后不返回任何内容体
+	c.Status(consts.StatusNoContent)
+}
+
+// sessionCourseOrDefault 优先使用请求中的课程，其次使用会话中的当前课程，都没有时默认毛概
+func sessionCourseOrDefault(ctx context.Context, userID, course string) string {
+	if course != "" {
+		return course
+	}
+	session := getOrCreateUserSession(userID)
+	session.mu.Lock()
+	course = session.CurrentCourse
+	session.mu.Unlock()
+	if course == "" {
+		slog.WarnContext(ctx, "会话中没有课程信息，默认使用毛概", logKeyUserID, userID)
+		course = "maogai"
 	}
+	return course
+}
 
-	var updatedIncorrect []UserIncorrectQuestion
-	var foundAndDeleted bool
+// deleteIncorrectQuestion 从用户错题本中删除一道题并记录到删除历史，返回是否找到该题。
+// course 可以是虚拟课程，此时在各成员课程的错题本中查找；几门成员课程都有该章节和题号的错题时
+// 无法确定要删除哪一道，返回 ambiguous_question，需要改用实体课程。
+func deleteIncorrectQuestion(ctx context.Context, userID, course, chapterKey, questionNumber string) (bool, error) {
 	var deletedQuestion UserIncorrectQuestion
-
-	// 遍历现有错题，找出要删除的题目
-	for _, iq := range userIncorrect {
-		if iq.OriginalChapter == req.OriginalChapter && iq.QuestionNumber == req.OriginalQuestionNumber {
-			foundAndDeleted = true
-			deletedQuestion = iq
-			// 保留原始答错时间
-			// 新增删除时间标记
-			deletedQuestion.DeletedAt = time.Now() // 记录删除时间
-			log.Printf("信息: 用户 %s 从错题本中删除题目: 章节 %s, 题号 %s", req.UserID, req.OriginalChapter, req.OriginalQuestionNumber)
-		} else {
-			updatedIncorrect = append(updatedIncorrect, iq)
+	var updatedIncorrect []UserIncorrectQuestion
+	incorrectFileName := ""
+	foundCourse := ""
+
+	// 虚拟课程会展开为多个实体课程，在各自的错题本中查找
+	var matchedCourses []string
+	for _, member := range resolveCourseMembers(course) {
+		// 加载课程特定的错题文件
+		fileName := getIncorrectQuestionsFileName(member.Course)
+		userIncorrect := []UserIncorrectQuestion{}
+		if err := loadUserJSONData(userID, fileName, &userIncorrect); err != nil {
+			slog.ErrorContext(ctx, "删除错题时加载错题本失败", logKeyUserID, userID, "course", member.Course, "error", err)
+			return false, &userDataError{Message: "加载用户错题本失败", Err: err}
 		}
-	}
 
-	// 仅当确实删除了题目时才更新文件
-	if foundAndDeleted {
-		// 保存更新后的课程特定错题本
-		if err := saveUserJSONData(req.UserID, incorrectFileName, updatedIncorrect); err != nil {
-			log.Printf("错误: 用户 %s 保存更新后的 %s 课程错题本失败: %v", req.UserID, currentCourse, err)
-			c.JSON(consts.StatusInternalServerError, utils.H{"error": "保存更新后的错题本失败"})
-			return
+		// 遍历现有错题，找出要删除的题目
+		var remaining []UserIncorrectQuestion
+		found := false
+		for _, iq := range userIncorrect {
+			if !found && iq.OriginalChapter == chapterKey && iq.QuestionNumber == questionNumber {
+				found = true
+				if incorrectFileName != "" {
+					continue // 已在前一门成员课程中找到，只记录有歧义
+				}
+				deletedQuestion = iq
+				if deletedQuestion.OriginalCourse == "" {
+					deletedQuestion.OriginalCourse = member.Course
+				}
+				// 保留原始答错时间
+				// 新增删除时间标记
+				deletedQuestion.DeletedAt = time.Now() // 记录删除时间
+			} else {
+				remaining = append(remaining, iq)
+			}
 		}
-
-		// 加载已删除错题历史
-		deletedIncorrect := []UserIncorrectQuestion{}
-		if err := loadUserJSONData(req.UserID, deleteIncorrectQuestionsFile, &deletedIncorrect); err != nil {
-			log.Printf("警告: 用户 %s 加载已删除错题历史失败: %v", req.UserID, err)
-			// 继续执行，可能是首次删除，文件不存在
+		if !found {
+			continue
 		}
+		matchedCourses = append(matchedCourses, member.Course)
+		if incorrectFileName == "" {
+			incorrectFileName = fileName
+			foundCourse = member.Course
+			updatedIncorrect = remaining
+		}
+	}
+	if len(matchedCourses) > 1 {
+		return false, newAppError(codeAmbiguousQuestion, course, chapterKey, questionNumber, strings.Join(matchedCourses, ", "))
+	}
 
-		// 将新删除的题目添加到历史记录中
-		deletedIncorrect = append(deletedIncorrect, deletedQuestion)
+	if incorrectFileName == "" {
+		slog.WarnContext(ctx, "要删除的错题不在错题本中", logKeyUserID, userID, "chapter", chapterKey, "question_number", questionNumber)
+		return false, nil
+	}
 
-		// 保存更新后的删除历史
-		if err := saveUserJSONData(req.UserID, deleteIncorrectQuestionsFile, deletedIncorrect); err != nil {
-			log.Printf("错误: 用户 %s 保存已删除错题历史失败: %v", req.UserID, err)
-			// 不阻止主流程，因为主要操作（从错题本删除）已成功
-		} else {
-			log.Printf("信息: 用户 %s 的已删除错题已记录到历史文件中，删除时间为: %v", req.UserID, deletedQuestion.DeletedAt)
-		}
-	} else {
-		log.Printf("警告: 用户 %s 请求删除错题 (章节 %s, 题号 %s)，但在错题本中未找到该题。", req.UserID, req.OriginalChapter, req.OriginalQuestionNumber)
+	// 先记入已删除错题历史，再从错题本中移除，避免历史读写失败时题目既不在错题本也不在历史中
+	deletedIncorrect := []UserIncorrectQuestion{}
+	if err := loadUserJSONData(userID, deleteIncorrectQuestionsFile, &deletedIncorrect); err != nil {
+		slog.ErrorContext(ctx, "加载已删除错题历史失败", logKeyUserID, userID, "error", err)
+		return false, &userDataError{Message: "加载已删除错题历史失败", Err: err}
+	}
+	deletedIncorrect = append(deletedIncorrect, deletedQuestion)
+	if err := saveUserJSONData(userID, deleteIncorrectQuestionsFile, deletedIncorrect); err != nil {
+		slog.ErrorContext(ctx, "保存已删除错题历史失败", logKeyUserID, userID, "error", err)
+		return false, &userDataError{Message: "保存已删除错题历史失败", Err: err}
 	}
 
-	// 按照要求，成功处理后不返回任何内容体
-	c.Status(consts.StatusNoContent)
+	// 保存更新后的课程特定错题本
+	if err := saveUserJSONData(userID, incorrectFileName, updatedIncorrect); err != nil {
+		slog.ErrorContext(ctx, "保存错题本失败", logKeyUserID, userID, "course", foundCourse, "error", err)
+		return false, &userDataError{Message: "保存更新后的错题本失败", Err: err}
+	}
+	slog.InfoContext(ctx, "已从错题本删除题目并记录到已删除错题历史", logKeyUserID, userID, "course", foundCourse, "chapter", chapterKey, "question_number", questionNumber)
+	return true, nil
 }
 
 // UserDataClearHandler 处理清除用户数据的请求（错题本和统计数据）。
 func UserDataClearHandler(ctx context.Context, c *app.RequestContext) {
-	var req GetNextQuestionRequest // 仅为了获取 UserID
+	var req ClearUserDataRequest
 	if err := c.BindAndValidate(&req); err != nil {
-		c.JSON(consts.StatusBadRequest, utils.H{"error": "无效请求: " + err.Error()})
+		writeError(c, invalidRequest(err))
 		return
 	}
+	if err := clearUserData(ctx, req.UserID); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, MessageResponse{Message: "用户数据（错题本和统计）已成功清理。"})
+}
 
-	userID := req.UserID
-	log.Printf("用户 %s 请求清理其数据...", userID)
+// clearUserData 备份并清理用户的错题本、统计和学习记录，并清除内存中的会话
+func clearUserData(ctx context.Context, userID string) error {
+	slog.InfoContext(ctx, "清理用户数据", logKeyUserID, userID)
 
-	// 清理毛概错题文件
-	maogaiIncorrectPath := getUserDataPath(userID, maogaiIncorrectQuestionsFile)
-	if _, err := os.Stat(maogaiIncorrectPath); err == nil { // 文件存在
-		if err := os.Rename(maogaiIncorrectPath, maogaiIncorrectPath+time.Now().Format(".2006_01_02_15_04_05.bak")); err != nil {
-			log.Printf("错误: 用户 %s 清理毛概错题文件 %s 失败: %v", userID, maogaiIncorrectPath, err)
-		} else {
-			log.Printf("信息: 用户 %s 的毛概错题文件 %s 已清理。", userID, maogaiIncorrectPath)
-		}
-	} else if !os.IsNotExist(err) {
-		log.Printf("错误: 检查毛概错题文件 %s 时发生错误: %v", maogaiIncorrectPath, err)
-	}
+	// 各项清理互不影响，全部尝试后再报告第一个失败
+	var clearErr error
 
-	// 清理习概相关的错题文件（支持李老师和杨老师的独立错题簿，同时兼容旧的统一文件）
-	xigaiFiles := []string{xigaiLiIncorrectQuestionsFile, xigaiYangIncorrectQuestionsFile, xigaiIncorrectQuestionsFile}
-	for _, fname := range xigaiFiles {
-		path := getUserDataPath(userID, fname)
-		if _, err := os.Stat(path); err == nil { // 文件存在
-			if err := os.Rename(path, path+time.Now().Format(".2006_01_02_15_04_05.bak")); err != nil {
-				log.Printf("错误: 用户 %s 清理习概错题文件 %s 失败: %v", userID, path, err)
-			} else {
-				log.Printf("信息: 用户 %s 的习概错题文件 %s 已清理。", userID, path)
-			}
-		} else if !os.IsNotExist(err) {
-			log.Printf("错误: 检查习概错题文件 %s 时发生错误: %v", path, err)
-		}
+	// 清理各课程的错题文件（包括李老师和杨老师的独立错题簿，同时兼容旧的统一文件），单个文件失败不影响其余文件
+	if _, err := clearUserIncorrect(userID, ""); err != nil {
+		slog.ErrorContext(ctx, "清理错题本失败", logKeyUserID, userID, "error", err)
+		clearErr = &userDataError{Message: "清理用户错题本时发生部分或全部失败", Err: err}
 	}
 
 	// 清理统计文件
-	statsPath := getUserDataPath(userID, questionStatsFile)
-	if _, err := os.Stat(statsPath); err == nil { // 文件存在
-		if err := os.Rename(statsPath, statsPath+time.Now().Format(".2006_01_02_15_04_05.bak")); err != nil {
-			log.Printf("错误: 用户 %s 清理统计文件 %s 失败: %v", userID, statsPath, err)
-			c.JSON(consts.StatusInternalServerError, utils.H{"error": "清理用户统计数据时发生部分或全部失败"})
-			return // 如果统计文件清理失败，可能需要报告更严重的错误
-		} else {
-			log.Printf("信息: 用户 %s 的统计文件 %s 已清理。", userID, statsPath)
+	if _, err := backupUserFile(userID, questionStatsFile); err != nil {
+		slog.ErrorContext(ctx, "清理统计数据失败", logKeyUserID, userID, "error", err)
+		if clearErr == nil {
+			clearErr = &userDataError{Message: "清理用户统计数据时发生部分或全部失败", Err: err}
+		}
+	}
+	// 学习记录由作答产生，随统计一起清理（每日目标保留）
+	if _, err := backupUserFile(userID, studyActivityFile); err != nil {
+		slog.ErrorContext(ctx, "清理学习记录失败", logKeyUserID, userID, "error", err)
+		if clearErr == nil {
+			clearErr = &userDataError{Message: "清理用户学习记录失败", Err: err}
 		}
-	} else if !os.IsNotExist(err) { // 其他错误
-		log.Printf("错误: 检查统计文件 %s 时发生错误: %v", statsPath, err)
-		c.JSON(consts.StatusInternalServerError, utils.H{"error": "检查用户统计数据时出错"})
-		return
 	}
 
 	// 可选：从内存会话中清除用户会话，如果用户当前有活动会话
 	sessionsMu.Lock()
 	delete(userSessions, userID)
 	sessionsMu.Unlock()
-	log.Printf("信息: 用户 %s 的内存会话（如果存在）已清除。", userID)
+	slog.DebugContext(ctx, "已清除内存会话", logKeyUserID, userID)
 
 	// 也可以考虑删除用户的主目录，但这取决于是否还有其他类型的数据
 	// userDirPath := filepath.Join(userDataBaseDir, userID)
 	// if err := os.RemoveAll(userDirPath); err != nil {
-	//    log.Printf("警告: 用户 %s 清理主数据目录 %s 失败: %v", userID, userDirPath, err)
+	//    slog.WarnContext(ctx, "清理用户数据目录失败", logKeyUserID, userID, "error", err)
 	// } else {
-	//    log.Printf("信息: 用户 %s 的主数据目录 %s 已清理。", userID, userDirPath)
+	//    slog.InfoContext(ctx, "已清理用户数据目录", logKeyUserID, userID)
 	// }
-
-	c.JSON(consts.StatusOK, utils.H{"message": "用户数据（错题本和统计）已成功清理。"})
+	return clearErr
 }
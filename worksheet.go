@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// --- 打印试卷 ---
+// 按与刷题相同的章节选择出题，生成可直接打印的 HTML 试卷（题目页 + 单独的参考答案页）。
+// 随机顺序使用试卷编号作为随机种子，相同的选择和编号总能生成同一份试卷。
+
+const (
+	worksheetKeyAppend = "append" // 试卷后附参考答案（另起一页）
+	worksheetKeyNone   = "none"   // 只有题目
+	worksheetKeyOnly   = "only"   // 只有参考答案
+)
+
+// worksheetTypeOrder 试卷中各题型大题的顺序，未列出的题型排在最后
+var worksheetTypeOrder = []string{"单选题", "多选题", "不定项", "判断题"}
+
+// worksheetAnswerColumns 参考答案表每行的题数
+const worksheetAnswerColumns = 10
+
+var worksheetTemplate = template.Must(template.ParseFS(embeddedFS, "worksheet.html"))
+
+// worksheet 渲染试卷模板所需的数据
+type worksheet struct {
+	Title         string
+	Subtitle      string
+	Seed          int64
+	Total         int
+	Sections      []worksheetSection
+	ShowQuestions bool
+	ShowAnswerKey bool
+}
+
+// worksheetSection 试卷中的一个大题（同一题型）
+type worksheetSection struct {
+	Heading    string
+	Questions  []worksheetQuestion
+	AnswerRows [][]worksheetQuestion // 参考答案表，每行 worksheetAnswerColumns 道题，不足时补空
+}
+
+// worksheetQuestion 试卷中的一道题
+type worksheetQuestion struct {
+	Number      int
+	Text        string
+	Options     []worksheetOption
+	LongOptions bool // 选项较长时每行只排一个
+	Answer      string
+}
+
+// worksheetOption 题目的一个选项
+type worksheetOption struct {
+	Letter string
+	Text   string
+}
+
+// buildWorksheet 按请求选出题目并编排试卷：按题型筛选，随机顺序时用试卷编号打乱，截取题数，
+// 再按题型分成大题并连续编号。未指定编号时生成一个新的编号。
+func buildWorksheet(req WorksheetExportRequest) (worksheet, error) {
+	switch req.AnswerKey {
+	case "":
+		req.AnswerKey = worksheetKeyAppend
+	case worksheetKeyAppend, worksheetKeyNone, worksheetKeyOnly:
+	default:
+		return worksheet{}, newOptionError("answer_key", req.AnswerKey, "append", "none", "only")
+	}
+	if req.Count < 0 {
+		return worksheet{}, newAppError(codeNegativeValue, "题数", req.Count)
+	}
+	rng, seed := newRunRNG(req.Seed)
+
+	questions, err := selectExportQuestions(req.ExportSelection)
+	if err != nil {
+		return worksheet{}, err
+	}
+	questions = filterQuestionTypes(questions, req.QuestionTypes)
+	if len(questions) == 0 {
+		return worksheet{}, newAppError(codeEmptySelection)
+	}
+	if isRandomOrder(req.OrderChoice) {
+		rng.Shuffle(len(questions), func(i, j int) {
+			questions[i], questions[j] = questions[j], questions[i]
+		})
+	}
+	if req.Count > 0 && req.Count < len(questions) {
+		questions = questions[:req.Count]
+	}
+
+	ws := worksheet{
+		Title:         req.Title,
+		Subtitle:      worksheetSubtitle(req.ExportSelection),
+		Seed:          seed,
+		Total:         len(questions),
+		ShowQuestions: req.AnswerKey != worksheetKeyOnly,
+		ShowAnswerKey: req.AnswerKey != worksheetKeyNone,
+	}
+	if ws.Title == "" {
+		ws.Title = "政治选择题练习卷"
+		if req.UserID != "" {
+			ws.Title = req.UserID + " 的错题练习卷"
+		}
+	}
+
+	byType := make(map[string][]Question)
+	typeOrder := append([]string{}, worksheetTypeOrder...)
+	for _, q := range questions {
+		if _, ok := byType[q.QuestionType]; !ok && !slices.Contains(typeOrder, q.QuestionType) {
+			typeOrder = append(typeOrder, q.QuestionType)
+		}
+		byType[q.QuestionType] = append(byType[q.QuestionType], q)
+	}
+	number := 0
+	for _, questionType := range typeOrder {
+		group := byType[questionType]
+		if len(group) == 0 {
+			continue
+		}
+		section := worksheetSection{
+			Heading: fmt.Sprintf("%s、%s（共 %d 题）", chineseSectionNumber(len(ws.Sections)+1), questionType, len(group)),
+		}
+		for _, q := range group {
+			number++
+			wq := worksheetQuestion{Number: number, Text: strings.TrimSpace(q.QuestionText), Answer: normalizeAnswer(q.CorrectAnswer)}
+			for _, letter := range sortedOptionLetters(q.Options) {
+				text := strings.TrimSpace(q.Options[letter])
+				wq.Options = append(wq.Options, worksheetOption{Letter: letter, Text: text})
+				if utf8.RuneCountInString(text) > 18 {
+					wq.LongOptions = true
+				}
+			}
+			section.Questions = append(section.Questions, wq)
+		}
+		for start := 0; start < len(section.Questions); start += worksheetAnswerColumns {
+			row := make([]worksheetQuestion, worksheetAnswerColumns)
+			copy(row, section.Questions[start:min(start+worksheetAnswerColumns, len(section.Questions))])
+			section.AnswerRows = append(section.AnswerRows, row)
+		}
+		ws.Sections = append(ws.Sections, section)
+	}
+	return ws, nil
+}
+
+// filterQuestionTypes 只保留指定题型的题目，题型为空时不筛选
+func filterQuestionTypes(questions []Question, questionTypes []string) []Question {
+	if len(questionTypes) == 0 {
+		return questions
+	}
+	wanted := make(map[string]bool)
+	for _, questionType := range questionTypes {
+		wanted[questionType] = true
+	}
+	filtered := []Question{}
+	for _, q := range questions {
+		if wanted[q.QuestionType] {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
+
+// worksheetSubtitle 描述试卷的出题范围
+func worksheetSubtitle(sel ExportSelection) string {
+	scope := "全部课程"
+	if meta, ok := courseCatalog[sel.Course]; ok {
+		scope = meta.Title
+	}
+	if len(sel.ChapterChoice) > 0 {
+		chapters, err := resolveChapterChoices(sel.Course, sel.ChapterChoice)
+		if err == nil && len(chapters) < len(courseCatalog[sel.Course].Chapters) {
+			titles := make([]string, len(chapters))
+			for i, chapter := range chapters {
+				titles[i] = chapter.Title
+			}
+			scope += "：" + strings.Join(titles, "、")
+		}
+	}
+	if sel.UserID != "" {
+		scope += "（错题本）"
+	}
+	return scope
+}
+
+// chineseSectionNumber 返回大题序号 一、二、三……（大题数量不会超过十个）
+func chineseSectionNumber(n int) string {
+	numerals := []string{"一", "二", "三", "四", "五", "六", "七", "八", "九", "十"}
+	if n >= 1 && n <= len(numerals) {
+		return numerals[n-1]
+	}
+	return strconv.Itoa(n)
+}
+
+// writeWorksheet 渲染试卷 HTML
+func writeWorksheet(w io.Writer, ws worksheet) error {
+	return worksheetTemplate.Execute(w, ws)
+}
+
+// WorksheetExportHandler 生成可打印的 HTML 试卷
+// (?course=&chapter_choice=&user_id=&question_type=&count=&order_choice=&seed=&title=&answer_key=)
+func WorksheetExportHandler(ctx context.Context, c *app.RequestContext) {
+	var req WorksheetExportRequest
+	if !bindExportSelection(c, &req, &req.ExportSelection) {
+		return
+	}
+	req.QuestionTypes = splitListParams(req.QuestionTypes)
+	ws, err := buildWorksheet(req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := writeWorksheet(&buf, ws); err != nil {
+		writeError(c, &userDataError{Message: "生成试卷失败", Err: err})
+		return
+	}
+	c.Header("X-Worksheet-Seed", strconv.FormatInt(ws.Seed, 10))
+	c.Data(consts.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}